@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/symptom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rulesPath  string
+	format     string
+	outputPath string
+	timezone   string
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "analyze <bundle>",
+	Short: "Print a triage report of a symptom bundle",
+	Long: `Open a symptom bundle (.tar.gz or an extracted directory) without any cluster
+and print a triage report: events grouped by rule and pod, cores with their
+time, the pybot test cases the events occurred in and a merged timeline of
+every source. The html and markdown formats render the same report as the
+collection run writes into the bundle.
+
+With --rules, the collected log files are classified again with the rules and
+multi-line settings of the given configuration file, which validates new error
+rules against old bundles.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch format {
+		case "text", "json", "html", "markdown":
+		default:
+			return fmt.Errorf("unknown format %q, want text, json, html or markdown", format)
+		}
+
+		var opts symptom.AnalyzeOptions
+		if rulesPath != "" {
+			cfg, err := config.Load(rulesPath)
+			if err != nil {
+				return fmt.Errorf("failed to load rules: %w", err)
+			}
+			if opts, err = symptom.AnalyzeOptionsFromConfig(cfg); err != nil {
+				return err
+			}
+		}
+		if timezone != "" {
+			loc, err := time.LoadLocation(timezone)
+			if err != nil {
+				return fmt.Errorf("invalid timezone: %w", err)
+			}
+			opts.Location = loc
+		}
+
+		bundle, err := symptom.OpenBundle(args[0])
+		if err != nil {
+			return err
+		}
+		defer bundle.Close()
+
+		analysis, err := bundle.Analyze(opts)
+		if err != nil {
+			return fmt.Errorf("failed to analyze bundle: %w", err)
+		}
+
+		var out io.Writer = os.Stdout
+		if outputPath != "" {
+			file, err := os.Create(outputPath)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		switch format {
+		case "json":
+			err = analysis.WriteJSON(out)
+		case "html":
+			err = symptom.NewReport(bundle.Manifest, analysis).WriteHTML(out)
+		case "markdown":
+			err = symptom.NewReport(bundle.Manifest, analysis).WriteMarkdown(out)
+		default:
+			err = analysis.WriteText(out)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.Flags().StringVarP(&rulesPath, "rules", "r", "", "Configuration file whose symptom.rules and symptom.multiline reclassify the logs (default: keep the recorded events)")
+	rootCmd.Flags().StringVarP(&format, "format", "f", "text", "Report format: text, json, html or markdown")
+	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write the report to a file instead of stdout")
+	rootCmd.Flags().StringVar(&timezone, "timezone", "", "Time zone of log timestamps without one (default: UTC)")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -4,57 +4,25 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/internal/logger"
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/patch"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 var (
-	patchPath  string
-	serviceName string
-	configPath string
+	patchPath      string
+	serviceName    string
+	namespace      string
+	configPath     string
+	kubeconfigPath string
+	kubeContext    string
+	skipPreflight  bool
 )
 
-// KubernetesServiceRestarter implements ServiceRestarter using Kubernetes API
-type KubernetesServiceRestarter struct {
-	logger *zap.Logger
-}
-
-func (r *KubernetesServiceRestarter) RestartService(ctx context.Context, serviceName string) error {
-	r.logger.Info("Restarting service", zap.String("service", serviceName))
-	// In real implementation, this would:
-	// 1. Login to mcc container
-	// 2. Kill the service process
-	// 3. Wait for it to restart
-	time.Sleep(2 * time.Second) // Simulate restart time
-	return nil
-}
-
-func (r *KubernetesServiceRestarter) MonitorHealth(ctx context.Context, serviceName string, timeout time.Duration) error {
-	r.logger.Info("Monitoring service health", zap.String("service", serviceName), zap.Duration("timeout", timeout))
-	
-	checkInterval := 2 * time.Second
-	startTime := time.Now()
-	
-	for time.Since(startTime) < timeout {
-		// In real implementation, check if all processes are up
-		r.logger.Debug("Health check", zap.String("service", serviceName))
-		time.Sleep(checkInterval)
-		
-		// Simulate success after a short time
-		if time.Since(startTime) > 5*time.Second {
-			r.logger.Info("Service is healthy", zap.String("service", serviceName))
-			return nil
-		}
-	}
-	
-	return fmt.Errorf("service health check timed out after %v", timeout)
-}
-
 var rootCmd = &cobra.Command{
 	Use:   "apply-patch",
 	Short: "Apply a patch to a Kubernetes service",
@@ -62,8 +30,8 @@ var rootCmd = &cobra.Command{
 1. Validate patch file (MD5 checksum)
 2. Copy patch to /tcnVol
 3. Link library files to /opt/SMAW/INTP/lib64
-4. Restart service processes
-5. Monitor process health
+4. Restart the service's deployment, like kubectl rollout restart
+5. Wait until the rollout completes and its pods are ready
 6. Log success/failure status`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if patchPath == "" {
@@ -90,14 +58,33 @@ var rootCmd = &cobra.Command{
 			zap.String("service", serviceName),
 		)
 
+		ns := namespace
+		if ns == "" {
+			ns = cfg.Kubernetes.Namespace
+		}
+		mode, err := kubernetes.ParseMatchMode(cfg.Kubernetes.MatchMode)
+		if err != nil {
+			return fmt.Errorf("invalid match mode: %w", err)
+		}
+
+		ctx := context.Background()
+		k8sClient, err := newClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if !skipPreflight {
+			if err := runPreflight(ctx, k8sClient, ns); err != nil {
+				return err
+			}
+		}
+
 		// Create service restarter
-		restarter := &KubernetesServiceRestarter{logger: logger.Logger}
+		restarter := patch.NewClusterRestarter(k8sClient, ns, mode, logger.Logger)
 
 		// Create patch manager
 		manager := patch.NewManager(cfg, logger.Logger, restarter)
 
 		// Apply patch
-		ctx := context.Background()
 		if err := manager.ApplyPatch(ctx, patchPath, serviceName); err != nil {
 			logger.Logger.Error("Patch application failed", zap.Error(err))
 			return fmt.Errorf("patch application failed: %w", err)
@@ -108,10 +95,47 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// newClient connects to the cluster selected by the config and flags
+func newClient(ctx context.Context, cfg *config.Config) (*kubernetes.Client, error) {
+	clientOpts := kubernetes.ClientOptionsFromConfig(cfg.Kubernetes)
+	if kubeconfigPath != "" {
+		clientOpts.KubeconfigPath = kubeconfigPath
+	}
+	if kubeContext != "" {
+		clientOpts.ContextName = kubeContext
+	}
+	k8sClient, err := kubernetes.NewClient(ctx, clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return k8sClient, nil
+}
+
+// runPreflight checks the permissions needed to patch before anything is changed
+func runPreflight(ctx context.Context, k8sClient *kubernetes.Client, ns string) error {
+	report, err := k8sClient.Preflight(ctx, kubernetes.WorkflowPatch, ns)
+	if err != nil {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+	if !report.Allowed() {
+		fmt.Fprintf(os.Stderr, "\n%s\n", report.Table())
+		return report.Err()
+	}
+	logger.Logger.Info("Preflight access checks passed",
+		zap.String("context", k8sClient.ContextName),
+		zap.String("namespace", ns),
+	)
+	return nil
+}
+
 func init() {
 	rootCmd.Flags().StringVarP(&patchPath, "patch", "p", "", "Absolute path to patch file (required)")
 	rootCmd.Flags().StringVarP(&serviceName, "service", "s", "", "Service name (required)")
+	rootCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (default: from config)")
 	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
+	rootCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to kubeconfig file (default: from config, $KUBECONFIG or ~/.kube/config)")
+	rootCmd.Flags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (default: from config or current context)")
+	rootCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Skip the RBAC permission check")
 	
 	rootCmd.MarkFlagRequired("patch")
 	rootCmd.MarkFlagRequired("service")
@@ -14,8 +14,10 @@ import (
 )
 
 var (
-	namespace string
-	configPath string
+	namespace      string
+	configPath     string
+	kubeconfigPath string
+	kubeContext    string
 )
 
 var rootCmd = &cobra.Command{
@@ -45,10 +47,21 @@ var rootCmd = &cobra.Command{
 
 		// Create Kubernetes client
 		ctx := context.Background()
-		k8sClient, err := kubernetes.NewClient(ctx)
+		clientOpts := kubernetes.ClientOptionsFromConfig(cfg.Kubernetes)
+		if kubeconfigPath != "" {
+			clientOpts.KubeconfigPath = kubeconfigPath
+		}
+		if kubeContext != "" {
+			clientOpts.ContextName = kubeContext
+		}
+		k8sClient, err := kubernetes.NewClient(ctx, clientOpts)
 		if err != nil {
 			return fmt.Errorf("failed to create Kubernetes client: %w", err)
 		}
+		logger.Logger.Info("Connected to Kubernetes cluster",
+			zap.String("context", k8sClient.ContextName),
+			zap.String("host", k8sClient.Host),
+		)
 
 		// Get deployments
 		deployments, err := k8sClient.GetDeployments(ns)
@@ -71,6 +84,7 @@ var rootCmd = &cobra.Command{
 			}
 			
 			fmt.Printf("   Replicas: %d/%d\n", deployment.Status.ReadyReplicas, replicas)
+			fmt.Printf("   Rollout: %s\n", kubernetes.EvaluateRollout(&deployment).Message)
 			fmt.Printf("   Age: %s\n", utils.GetAge(deployment.CreationTimestamp.Time))
 			
 			if len(deployment.Spec.Template.Spec.Containers) > 0 {
@@ -87,11 +101,16 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (default: from config)")
 	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
+	rootCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to kubeconfig file (default: from config, $KUBECONFIG or ~/.kube/config)")
+	rootCmd.Flags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (default: from config or current context)")
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if hint := kubernetes.Hint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
 		os.Exit(1)
 	}
 }
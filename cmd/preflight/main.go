@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/internal/logger"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	namespace      string
+	workflows      []string
+	configPath     string
+	kubeconfigPath string
+	kubeContext    string
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check RBAC permissions needed by symptom collection and patching",
+	Long: `Check, using SelfSubjectAccessReview, that the current credentials hold every
+permission needed by the selected workflows and print a table of what is
+allowed and denied. Exits non-zero if any permission is missing.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Load configuration
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		// Initialize logger
+		if err := logger.InitDevelopment(cfg.Logging.Level); err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer logger.Sync()
+
+		// Use namespace from flag or config
+		ns := namespace
+		if ns == "" {
+			ns = cfg.Kubernetes.Namespace
+		}
+
+		// Create Kubernetes client
+		ctx := context.Background()
+		clientOpts := kubernetes.ClientOptionsFromConfig(cfg.Kubernetes)
+		if kubeconfigPath != "" {
+			clientOpts.KubeconfigPath = kubeconfigPath
+		}
+		if kubeContext != "" {
+			clientOpts.ContextName = kubeContext
+		}
+		k8sClient, err := kubernetes.NewClient(ctx, clientOpts)
+		if err != nil {
+			return fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		logger.Logger.Info("Connected to Kubernetes cluster",
+			zap.String("context", k8sClient.ContextName),
+			zap.String("host", k8sClient.Host),
+		)
+
+		failed := false
+		for _, workflow := range workflows {
+			checks, err := kubernetes.WorkflowChecks(workflow)
+			if err != nil {
+				return err
+			}
+			if workflow == kubernetes.WorkflowSymptomCollection {
+				// Pcap and pybot settings add checks
+				checks = kubernetes.SymptomCollectionChecksFor(cfg, ns)
+			}
+			report, err := k8sClient.PreflightChecks(ctx, workflow, ns, checks)
+			if err != nil {
+				return fmt.Errorf("preflight for %s failed: %w", workflow, err)
+			}
+
+			fmt.Printf("\n%s in namespace '%s':\n\n%s", workflow, ns, report.Table())
+			for _, result := range report.Results {
+				if result.Err != nil {
+					fmt.Printf("  %s %s: %v\n", result.Verb, result.AccessCheck, result.Err)
+				}
+			}
+			if !report.Allowed() {
+				failed = true
+			}
+		}
+		fmt.Println()
+
+		if failed {
+			return fmt.Errorf("missing permissions, see table above")
+		}
+		fmt.Println("All required permissions are granted")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (default: from config)")
+	rootCmd.Flags().StringSliceVarP(&workflows, "workflow", "w",
+		[]string{kubernetes.WorkflowSymptomCollection, kubernetes.WorkflowPatch},
+		"Workflows to check: symptom-collection, apply-patch")
+	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
+	rootCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to kubeconfig file (default: from config, $KUBECONFIG or ~/.kube/config)")
+	rootCmd.Flags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (default: from config or current context)")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if hint := kubernetes.Hint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
+		os.Exit(1)
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/internal/logger"
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
@@ -15,11 +18,56 @@ import (
 )
 
 var (
-	namespace string
-	pods      string
-	configPath string
+	namespace      string
+	pods           string
+	matchMode      string
+	configPath     string
+	kubeconfigPath string
+	kubeContext    string
+	failOn         string
+	failOnRoutines bool
+	failOnTests    bool
 )
 
+// Exit statuses of symptom-collection
+const (
+	exitCollectionFailed = 1
+	exitPolicyViolated   = 2
+	// exitTimedOut follows timeout(1)
+	exitTimedOut = 124
+	// exitSignalBase plus the signal number is the shell's status for a
+	// process killed by a signal: 130 for SIGINT, 143 for SIGTERM
+	exitSignalBase = 128
+)
+
+// exitError is an error that exits with a status other than exitCollectionFailed
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// signalError is the cause of a collection stopped by a signal
+type signalError struct {
+	signal syscall.Signal
+}
+
+func (e *signalError) Error() string {
+	return fmt.Sprintf("received %s", e.signal)
+}
+
+// Is lets the cause match context.Canceled like a plain cancellation
+func (e *signalError) Is(target error) bool {
+	return target == context.Canceled
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "symptom-collection",
 	Short: "Start symptom collection for Kubernetes pods",
@@ -28,11 +76,24 @@ var rootCmd = &cobra.Command{
 - Enables pcap capture
 - Executes test commands
 - Monitors log files for errors
-- Collects and stores traces for analysis`,
+- Collects and stores traces for analysis
+
+Exits with status 1 when the collection fails, 2 when it completes but fails
+the policy set with --fail-on, --fail-on-routine-errors and
+--fail-on-test-failures, 124 when symptom.collection_timeout stopped pybot,
+130 when it was interrupted by SIGINT and 143 when it was stopped by SIGTERM.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if pods == "" {
 			return fmt.Errorf("pod names (-p) are required")
 		}
+		policy := symptom.ExitPolicy{Routines: failOnRoutines, Tests: failOnTests}
+		if failOn != "" {
+			severity, err := symptom.ParseSeverity(failOn)
+			if err != nil {
+				return fmt.Errorf("invalid --fail-on: %w", err)
+			}
+			policy.FailOn = severity
+		}
 
 		// Load configuration
 		cfg, err := config.Load(configPath)
@@ -51,6 +112,9 @@ var rootCmd = &cobra.Command{
 		if ns == "" {
 			ns = cfg.Kubernetes.Namespace
 		}
+		if matchMode != "" {
+			cfg.Kubernetes.MatchMode = matchMode
+		}
 
 		// Parse pod names
 		podList := strings.Fields(pods)
@@ -60,22 +124,97 @@ var rootCmd = &cobra.Command{
 			zap.Strings("pods", podList),
 		)
 
+		// SIGINT and SIGTERM stop the collection, which still disables what it
+		// enabled and writes the bundle. A second signal exits at once.
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			select {
+			case sig := <-signals:
+				signal.Stop(signals)
+				logger.Logger.Warn("Interrupted, disabling traces and captures; interrupt again to exit at once",
+					zap.String("signal", sig.String()))
+				cancel(&signalError{signal: sig.(syscall.Signal)})
+			case <-ctx.Done():
+			}
+		}()
+
 		// Create Kubernetes client
-		ctx := context.Background()
-		k8sClient, err := kubernetes.NewClient(ctx)
+		clientOpts := kubernetes.ClientOptionsFromConfig(cfg.Kubernetes)
+		if kubeconfigPath != "" {
+			clientOpts.KubeconfigPath = kubeconfigPath
+		}
+		if kubeContext != "" {
+			clientOpts.ContextName = kubeContext
+		}
+		// The client's requests are not cancelled by the interrupt, which only
+		// stops the collection, so that the cleanup still reaches the cluster
+		k8sClient, err := kubernetes.NewClient(context.WithoutCancel(ctx), clientOpts)
 		if err != nil {
 			return fmt.Errorf("failed to create Kubernetes client: %w", err)
 		}
+		logger.Logger.Info("Connected to Kubernetes cluster",
+			zap.String("context", k8sClient.ContextName),
+			zap.String("host", k8sClient.Host),
+		)
 
 		// Create collector
 		collector := symptom.NewCollector(cfg, k8sClient, logger.Logger)
 
 		// Start collection
-		if err := collector.StartCollection(ctx, ns, podList); err != nil {
+		result, err := collector.StartCollection(ctx, ns, podList)
+		if err != nil {
+			if result != nil && result.BundlePath != "" {
+				logger.Logger.Info("Partial symptom bundle written", zap.String("bundle", result.BundlePath))
+			}
+			var sigErr *signalError
+			switch {
+			case errors.As(err, &sigErr):
+				return &exitError{code: exitSignalBase + int(sigErr.signal), err: err}
+			case errors.Is(err, symptom.ErrCollectionTimeout):
+				return &exitError{code: exitTimedOut, err: err}
+			}
 			return fmt.Errorf("symptom collection failed: %w", err)
 		}
 
-		logger.Logger.Info("Symptom collection completed successfully")
+		logger.Logger.Info("Symptom collection finished",
+			zap.Duration("duration", result.Duration()),
+			zap.String("pybot_status", string(result.Pybot.Status)),
+			zap.Int("pybot_failed_tests", result.Pybot.FailedTests),
+			zap.Int("events", result.Stats.Recorded),
+			zap.Int("events_received", result.Stats.Received),
+			zap.Int("events_rate_limited", result.Stats.RateLimited),
+			zap.Int("events_dropped", result.Stats.Dropped),
+			zap.Any("events_by_severity", result.Totals.BySeverity),
+			zap.String("bundle", result.BundlePath),
+		)
+		for _, sink := range result.Sinks {
+			if sink.Failed > 0 || sink.Dropped > 0 {
+				logger.Logger.Warn("Event sink lost events",
+					zap.String("sink", sink.Name),
+					zap.Int("written", sink.Written),
+					zap.Int("failed", sink.Failed),
+					zap.Int("dropped", sink.Dropped),
+				)
+			}
+		}
+		for _, routine := range result.FailedRoutines() {
+			logger.Logger.Warn("Collection routine failed",
+				zap.String("routine", routine.Name),
+				zap.Strings("errors", routine.Errors),
+			)
+		}
+
+		if violations := policy.Violations(result); len(violations) > 0 {
+			return &exitError{
+				code: exitPolicyViolated,
+				err:  fmt.Errorf("symptom collection failed the exit policy: %s", strings.Join(violations, "; ")),
+			}
+		}
+		logger.Logger.Info("Symptom collection completed successfully", zap.String("bundle", result.BundlePath))
 		return nil
 	},
 }
@@ -83,7 +222,13 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (default: from config)")
 	rootCmd.Flags().StringVarP(&pods, "pods", "p", "", "Space-separated pod names (required)")
+	rootCmd.Flags().StringVarP(&matchMode, "match", "m", "", "How pod names match deployments: exact, prefix or regex (default: from config)")
 	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
+	rootCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to kubeconfig file (default: from config, $KUBECONFIG or ~/.kube/config)")
+	rootCmd.Flags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (default: from config or current context)")
+	rootCmd.Flags().StringVar(&failOn, "fail-on", "", "Exit with status 2 on any event of this severity or higher: info, warning, error or critical (default: never)")
+	rootCmd.Flags().BoolVar(&failOnRoutines, "fail-on-routine-errors", false, "Exit with status 2 when a collection routine failed, e.g. a capture or trace")
+	rootCmd.Flags().BoolVar(&failOnTests, "fail-on-test-failures", false, "Exit with status 2 when pybot reported failed tests")
 	
 	rootCmd.MarkFlagRequired("pods")
 }
@@ -92,7 +237,15 @@ func main() {
 	if err := rootCmd.Execute(); err != nil {
 		logger.Logger.Error("Command failed", zap.Error(err))
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if hint := kubernetes.Hint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
+		code := exitCollectionFailed
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		os.Exit(code)
 	}
 }
 
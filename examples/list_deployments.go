@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
 )
@@ -13,7 +14,9 @@ func main() {
 	ctx := context.Background()
 
 	// Create Kubernetes client
-	client, err := kubernetes.NewClient(ctx)
+	client, err := kubernetes.NewClient(ctx, kubernetes.ClientOptions{
+		Timeout: 30 * time.Second,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
@@ -25,14 +28,17 @@ func main() {
 	}
 
 	// Print deployment information
+	fmt.Printf("Connected to context %s (%s)\n", client.ContextName, client.Host)
 	fmt.Printf("Found %d deployment(s):\n\n", len(deployments))
 	for i, deployment := range deployments {
 		fmt.Printf("%d. %s\n", i+1, deployment.Name)
 		fmt.Printf("   Namespace: %s\n", deployment.Namespace)
+		status := kubernetes.EvaluateRollout(&deployment)
 		fmt.Printf("   Replicas: %d/%d\n",
-			deployment.Status.ReadyReplicas,
-			*deployment.Spec.Replicas,
+			status.Ready,
+			status.Desired,
 		)
+		fmt.Printf("   Rollout: %s (%s)\n", status.Reason, status.Message)
 		fmt.Println()
 	}
 }
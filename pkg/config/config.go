@@ -9,46 +9,217 @@ import (
 	"github.com/spf13/viper"
 )
 
-// Config holds the application configuration
+// Config holds the application configuration. Its JSON form, recorded in
+// symptom bundles, uses the same keys as the configuration file.
 type Config struct {
-	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
-	Paths      PathsConfig      `mapstructure:"paths"`
-	Logging    LoggingConfig    `mapstructure:"logging"`
-	Symptom    SymptomConfig    `mapstructure:"symptom"`
-	Patch      PatchConfig      `mapstructure:"patch"`
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes" json:"kubernetes"`
+	Paths      PathsConfig      `mapstructure:"paths" json:"paths"`
+	Logging    LoggingConfig    `mapstructure:"logging" json:"logging"`
+	Symptom    SymptomConfig    `mapstructure:"symptom" json:"symptom"`
+	Pybot      PybotConfig      `mapstructure:"pybot" json:"pybot"`
+	Patch      PatchConfig      `mapstructure:"patch" json:"patch"`
 }
 
 // KubernetesConfig holds Kubernetes-related configuration
 type KubernetesConfig struct {
-	Namespace      string        `mapstructure:"namespace"`
-	KubeconfigPath string        `mapstructure:"kubeconfig_path"`
-	Timeout        time.Duration `mapstructure:"timeout"`
+	Namespace      string        `mapstructure:"namespace" json:"namespace"`
+	KubeconfigPath string        `mapstructure:"kubeconfig_path" json:"kubeconfig_path"`
+	Context        string        `mapstructure:"context" json:"context"`
+	Timeout        time.Duration `mapstructure:"timeout" json:"timeout"`
+	QPS            float32       `mapstructure:"qps" json:"qps"`
+	Burst          int           `mapstructure:"burst" json:"burst"`
+	MatchMode      string        `mapstructure:"match_mode" json:"match_mode"`
 }
 
 // PathsConfig holds path-related configuration
 type PathsConfig struct {
-	TcnVolPath string `mapstructure:"tcn_vol_path"`
-	Lib64Path  string `mapstructure:"lib64_path"`
-	LogPaths   []string `mapstructure:"log_paths"`
+	TcnVolPath string   `mapstructure:"tcn_vol_path" json:"tcn_vol_path"`
+	Lib64Path  string   `mapstructure:"lib64_path" json:"lib64_path"`
+	LogPaths   []string `mapstructure:"log_paths" json:"log_paths"`
 }
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
+	Level  string `mapstructure:"level" json:"level"`
+	Format string `mapstructure:"format" json:"format"`
 }
 
 // SymptomConfig holds symptom collection configuration
 type SymptomConfig struct {
-	ErrorKeywords    []string      `mapstructure:"error_keywords"`
-	CheckInterval    time.Duration `mapstructure:"check_interval"`
-	CollectionTimeout time.Duration `mapstructure:"collection_timeout"`
+	// ErrorKeywords are matched as case-insensitive words when no rules are set
+	ErrorKeywords     []string      `mapstructure:"error_keywords" json:"error_keywords"`
+	CheckInterval     time.Duration `mapstructure:"check_interval" json:"check_interval"`
+	CollectionTimeout time.Duration `mapstructure:"collection_timeout" json:"collection_timeout"`
+	Container         string        `mapstructure:"container" json:"container"`
+	// OutputDir receives a directory per collection run
+	OutputDir string `mapstructure:"output_dir" json:"output_dir"`
+	// CoreDirs are polled for new core files instead of being tailed
+	CoreDirs []string `mapstructure:"core_dirs" json:"core_dirs"`
+	// CoreNamePattern extracts exe, pid and sig named groups from core file names
+	CoreNamePattern string `mapstructure:"core_name_pattern" json:"core_name_pattern"`
+	// MaxCoreSize skips copying cores larger than this many bytes (0 means no limit)
+	MaxCoreSize int64 `mapstructure:"max_core_size" json:"max_core_size"`
+	// Sources selects the watchers; empty watches log_paths, core_dirs, events and restarts
+	Sources []SourceConfig `mapstructure:"sources" json:"sources"`
+	// Rules classify collected log lines; the first matching rule wins
+	Rules []RuleConfig `mapstructure:"rules" json:"rules"`
+	// Multiline groups multi-line records before they are matched; the first
+	// entry whose source matches applies
+	Multiline []MultilineConfig `mapstructure:"multiline" json:"multiline"`
+	// RateLimit is the number of new events per second kept from each source,
+	// with bursts of up to RateBurst
+	RateLimit float64 `mapstructure:"rate_limit" json:"rate_limit"`
+	RateBurst int     `mapstructure:"rate_burst" json:"rate_burst"`
+	// MaxEvents bounds the distinct events kept in a collection result
+	MaxEvents int `mapstructure:"max_events" json:"max_events"`
+	// Sinks receive every new event as it is detected; empty writes events.jsonl
+	// in the run directory
+	Sinks []SinkConfig `mapstructure:"sinks" json:"sinks"`
+	// Bundle controls the archive written at the end of every run
+	Bundle BundleConfig `mapstructure:"bundle" json:"bundle"`
+	// Pcap controls the packet capture in every target pod
+	Pcap PcapConfig `mapstructure:"pcap" json:"pcap"`
+	// Trace controls the tracing of processes in every target container
+	Trace TraceConfig `mapstructure:"trace" json:"trace"`
+}
+
+// TraceConfig controls the process tracing enabled in every target container
+// for the duration of a collection
+type TraceConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	// Driver is a registered trace driver: shell or a custom one
+	Driver string `mapstructure:"driver" json:"driver"`
+	// Processes are the process types traced in each target container
+	Processes []TraceProcessConfig `mapstructure:"processes" json:"processes"`
+}
+
+// TraceProcessConfig selects the processes of one type and the commands that
+// switch their tracing
+type TraceProcessConfig struct {
+	// Name identifies the process type, e.g. "uecm"
+	Name string `mapstructure:"name" json:"name"`
+	// Match is a regular expression matched against the process command line
+	Match string `mapstructure:"match" json:"match"`
+	// Enable and Disable are shell commands run in the container for every
+	// matching process; {pid}, {process}, {pod}, {container} and {namespace}
+	// are replaced
+	Enable  string `mapstructure:"enable" json:"enable"`
+	Disable string `mapstructure:"disable" json:"disable"`
+}
+
+// PcapConfig controls the packet capture run in every target pod for the
+// duration of a collection
+type PcapConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	// Mode is exec (the default), auto (tcpdump in the target container if
+	// present, otherwise an ephemeral container) or ephemeral
+	Mode      string `mapstructure:"mode" json:"mode"`
+	Interface string `mapstructure:"interface" json:"interface"`
+	// Filter is a BPF expression, e.g. "port 8080 or sctp"
+	Filter string `mapstructure:"filter" json:"filter"`
+	// Snaplen is the number of bytes captured per packet (0 uses the tcpdump default)
+	Snaplen int `mapstructure:"snaplen" json:"snaplen"`
+	// RotateSize starts a new file after this many megabytes (0 disables rotation);
+	// RotateCount keeps at most that many files (0 keeps all)
+	RotateSize  int `mapstructure:"rotate_size" json:"rotate_size"`
+	RotateCount int `mapstructure:"rotate_count" json:"rotate_count"`
+	// Image is the ephemeral container image, which needs sh, tcpdump and tar
+	Image string `mapstructure:"image" json:"image"`
+	// Dir holds the capture files inside the container until they are copied
+	Dir string `mapstructure:"dir" json:"dir"`
+}
+
+// BundleConfig controls the symptom bundle archive
+type BundleConfig struct {
+	// Dir receives the bundles; empty uses symptom.output_dir
+	Dir string `mapstructure:"dir" json:"dir"`
+	// Name is the archive name, in which {namespace} and {timestamp} are replaced
+	Name string `mapstructure:"name" json:"name"`
+}
+
+// SinkConfig selects and tunes an event sink
+type SinkConfig struct {
+	// Type is a registered sink type: jsonl, stdout, webhook or a custom one
+	Type string `mapstructure:"type" json:"type"`
+	// Path is the jsonl file, relative to the run directory unless absolute
+	Path string `mapstructure:"path" json:"path"`
+	// URL, Headers and Timeout configure the webhook requests
+	URL     string            `mapstructure:"url" json:"url"`
+	Headers map[string]string `mapstructure:"headers" json:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout" json:"timeout"`
+	// Events are written in batches of up to BatchSize, at least every FlushInterval
+	BatchSize     int           `mapstructure:"batch_size" json:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval" json:"flush_interval"`
+	// QueueSize bounds the events waiting for the sink; further events are dropped
+	QueueSize int `mapstructure:"queue_size" json:"queue_size"`
+	// Failed batches are retried MaxRetries times (negative disables retries),
+	// waiting RetryBackoff and then twice as long before each further attempt
+	MaxRetries   int           `mapstructure:"max_retries" json:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff" json:"retry_backoff"`
+	// Options are passed to custom sinks
+	Options map[string]string `mapstructure:"options" json:"options"`
+}
+
+// RuleConfig describes an error rule matched against collected log lines
+type RuleConfig struct {
+	Name    string `mapstructure:"name" json:"name"`
+	Pattern string `mapstructure:"pattern" json:"pattern"`
+	// Exclude drops lines that match Pattern but also match this regexp
+	Exclude string `mapstructure:"exclude" json:"exclude"`
+	// Severity is info, warning, error or critical; empty means error
+	Severity string `mapstructure:"severity" json:"severity"`
+	// Source is a glob on the log path, e.g. "/Envoy*"; empty matches every source
+	Source string   `mapstructure:"source" json:"source"`
+	Tags   []string `mapstructure:"tags" json:"tags"`
+}
+
+// MultilineConfig describes how the lines of a multi-line record are grouped
+type MultilineConfig struct {
+	// Source is a glob on the log path; empty applies to every source
+	Source string `mapstructure:"source" json:"source"`
+	// Start matches the first line of a record
+	Start string `mapstructure:"start" json:"start"`
+	// Continuation matches the following lines; empty means every line not matching Start
+	Continuation string        `mapstructure:"continuation" json:"continuation"`
+	MaxLines     int           `mapstructure:"max_lines" json:"max_lines"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout" json:"flush_timeout"`
+}
+
+// SourceConfig selects a watcher for one kind of symptom source
+type SourceConfig struct {
+	// Type is a registered watcher type: logfile, cores, events, restarts, podlogs
+	// or a custom one
+	Type  string   `mapstructure:"type" json:"type"`
+	Paths []string `mapstructure:"paths" json:"paths"`
+	// Options are passed to custom watchers
+	Options map[string]string `mapstructure:"options" json:"options"`
+}
+
+// PybotConfig holds the Robot Framework run executed on the testclient pod
+type PybotConfig struct {
+	// Namespace of the testclient pod; empty uses the collection namespace
+	Namespace string `mapstructure:"namespace" json:"namespace"`
+	Pod       string `mapstructure:"pod" json:"pod"`
+	// MatchMode matches Pod against deployment names: exact, prefix or regex.
+	// It is independent of kubernetes.match_mode, which matches the targets.
+	MatchMode string   `mapstructure:"match_mode" json:"match_mode"`
+	Container string   `mapstructure:"container" json:"container"`
+	Command   []string `mapstructure:"command" json:"command"`
+	WorkDir   string   `mapstructure:"work_dir" json:"work_dir"`
+	OutputDir string   `mapstructure:"output_dir" json:"output_dir"`
+	Suites    []string `mapstructure:"suites" json:"suites"`
+	Tests     []string `mapstructure:"tests" json:"tests"`
+	// Variables are passed as --variable NAME:VALUE
+	Variables []string `mapstructure:"variables" json:"variables"`
+	ExtraArgs []string `mapstructure:"extra_args" json:"extra_args"`
+	// Timezone of the testclient pod, used to read output.xml timestamps
+	Timezone string `mapstructure:"timezone" json:"timezone"`
 }
 
 // PatchConfig holds patch application configuration
 type PatchConfig struct {
-	BackupEnabled bool          `mapstructure:"backup_enabled"`
-	HealthTimeout time.Duration `mapstructure:"health_timeout"`
+	BackupEnabled bool          `mapstructure:"backup_enabled" json:"backup_enabled"`
+	HealthTimeout time.Duration `mapstructure:"health_timeout" json:"health_timeout"`
 }
 
 // Load loads configuration from file and environment variables
@@ -92,13 +263,15 @@ func setDefaults() {
 	// Kubernetes defaults
 	viper.SetDefault("kubernetes.namespace", "default")
 	viper.SetDefault("kubernetes.timeout", "30s")
+	viper.SetDefault("kubernetes.qps", 20)
+	viper.SetDefault("kubernetes.burst", 40)
+	viper.SetDefault("kubernetes.match_mode", "prefix")
 
 	// Path defaults
 	viper.SetDefault("paths.tcn_vol_path", "/tcnVol")
 	viper.SetDefault("paths.lib64_path", "/opt/SMAW/INTP/lib64")
 	viper.SetDefault("paths.log_paths", []string{
 		"/cmconfig.log",
-		"/logstore/TspCore",
 		"/RTPTraceError",
 		"/Envoy",
 		"/dumplog",
@@ -109,11 +282,31 @@ func setDefaults() {
 	viper.SetDefault("logging.format", "json")
 
 	// Symptom defaults
-	viper.SetDefault("symptom.error_keywords", []string{
-		"error", "ERROR", "fatal", "FATAL", "exception", "EXCEPTION", "panic", "PANIC",
-	})
 	viper.SetDefault("symptom.check_interval", "1s")
 	viper.SetDefault("symptom.collection_timeout", "10m")
+	viper.SetDefault("symptom.output_dir", "./symptoms")
+	viper.SetDefault("symptom.core_dirs", []string{"/logstore/TspCore"})
+	viper.SetDefault("symptom.rate_limit", 10)
+	viper.SetDefault("symptom.rate_burst", 50)
+	viper.SetDefault("symptom.max_events", 10000)
+	viper.SetDefault("symptom.bundle.name", "{namespace}-{timestamp}.tar.gz")
+	viper.SetDefault("symptom.pcap.enabled", true)
+	// exec never changes the pod spec; auto and ephemeral add an ephemeral
+	// container, which cannot be removed again
+	viper.SetDefault("symptom.pcap.mode", "exec")
+	viper.SetDefault("symptom.pcap.interface", "any")
+	viper.SetDefault("symptom.pcap.rotate_size", 100)
+	viper.SetDefault("symptom.pcap.rotate_count", 10)
+	viper.SetDefault("symptom.pcap.image", "nicolaka/netshoot:v0.13")
+	viper.SetDefault("symptom.pcap.dir", "/tmp/symptom-pcap")
+	viper.SetDefault("symptom.trace.driver", "shell")
+
+	// Pybot defaults
+	viper.SetDefault("pybot.pod", "testclient")
+	viper.SetDefault("pybot.match_mode", "prefix")
+	viper.SetDefault("pybot.command", []string{"pybot"})
+	viper.SetDefault("pybot.output_dir", "/tmp/pybot")
+	viper.SetDefault("pybot.timezone", "UTC")
 
 	// Patch defaults
 	viper.SetDefault("patch.backup_enabled", true)
@@ -128,4 +321,3 @@ func GetHomeDir() (string, error) {
 	}
 	return filepath.Join(home, ".miniumd"), nil
 }
-
@@ -2,109 +2,237 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 // Client wraps Kubernetes clientset and provides high-level operations
 type Client struct {
-	Clientset *kubernetes.Clientset
-	Context   context.Context
+	Clientset  kubernetes.Interface
+	RESTConfig *rest.Config
+	Executor   Executor
+	// Context supplies the values of the requests made without a context of
+	// their own. Its cancellation is not inherited, so cleanup after an
+	// interrupt still reaches the cluster.
+	Context context.Context
+
+	// Timeout bounds each non-streaming API request (0 means no timeout)
+	Timeout time.Duration
+
+	// ContextName and Host identify the cluster the client is connected to.
+	// ContextName is empty when running with in-cluster configuration.
+	ContextName string
+	Host        string
 }
 
-// NewClient creates a new Kubernetes client
-func NewClient(ctx context.Context) (*Client, error) {
-	config, err := ctrl.GetConfig()
+// ClientOptions configures how NewClient connects to the cluster
+type ClientOptions struct {
+	// KubeconfigPath overrides the default loading rules ($KUBECONFIG, ~/.kube/config)
+	KubeconfigPath string
+	// ContextName selects a kubeconfig context other than the current one
+	ContextName string
+	// Timeout bounds each API request (0 means no timeout)
+	Timeout time.Duration
+	// QPS and Burst tune client-side rate limiting (0 keeps client-go defaults)
+	QPS   float32
+	Burst int
+}
+
+// ClientOptionsFromConfig builds client options from the kubernetes configuration section
+func ClientOptionsFromConfig(cfg config.KubernetesConfig) ClientOptions {
+	return ClientOptions{
+		KubeconfigPath: cfg.KubeconfigPath,
+		ContextName:    cfg.Context,
+		Timeout:        cfg.Timeout,
+		QPS:            cfg.QPS,
+		Burst:          cfg.Burst,
+	}
+}
+
+// NewClient creates a new Kubernetes client.
+// Without a kubeconfig the in-cluster service account configuration is used.
+// The timeout is applied per request so that exec and log streams are not cut short.
+// Cancelling ctx does not cancel the client's requests; see Client.Context.
+func NewClient(ctx context.Context, opts ClientOptions) (*Client, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.KubeconfigPath
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.ContextName}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
+	contextName := opts.ContextName
+	if raw, err := clientConfig.RawConfig(); err == nil && contextName == "" {
+		contextName = raw.CurrentContext
+	}
+
 	return &Client{
-		Clientset: clientset,
-		Context:   ctx,
+		Clientset:   clientset,
+		RESTConfig:  config,
+		Executor:    NewSPDYExecutor(clientset, config),
+		Context:     ctx,
+		Timeout:     opts.Timeout,
+		ContextName: contextName,
+		Host:        config.Host,
 	}, nil
 }
 
+// ClusterInfo identifies the cluster a client is connected to
+type ClusterInfo struct {
+	// Context is the kubeconfig context, empty with in-cluster configuration
+	Context string `json:"context,omitempty"`
+	// Host is the API server URL
+	Host string `json:"host"`
+}
+
+// Cluster returns the kubeconfig context and API server the client uses
+func (c *Client) Cluster() ClusterInfo {
+	return ClusterInfo{Context: c.ContextName, Host: c.Host}
+}
+
+// requestContext returns a context bounded by the client's request timeout.
+// It is not cancelled with c.Context.
+func (c *Client) requestContext() (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if c.Context != nil {
+		parent = context.WithoutCancel(c.Context)
+	}
+	if c.Timeout > 0 {
+		return context.WithTimeout(parent, c.Timeout)
+	}
+	return context.WithCancel(parent)
+}
+
 // GetDeployments retrieves all deployments in a namespace
 func (c *Client) GetDeployments(namespace string) ([]v1.Deployment, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	list, err := c.Clientset.AppsV1().Deployments(namespace).
-		List(c.Context, metav1.ListOptions{})
+		List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list deployments: %w", err)
+		return nil, wrapAPIError(err, "list", "deployments", namespace, "")
 	}
 	return list.Items, nil
 }
 
 // GetDeployment retrieves a specific deployment by name
 func (c *Client) GetDeployment(namespace, name string) (*v1.Deployment, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	deployment, err := c.Clientset.AppsV1().Deployments(namespace).
-		Get(c.Context, name, metav1.GetOptions{})
+		Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get deployment %s: %w", name, err)
+		return nil, wrapAPIError(err, "get", "deployment", namespace, name)
 	}
 	return deployment, nil
 }
 
 // GetNamespace retrieves a namespace by name
 func (c *Client) GetNamespace(name string) (*corev1.Namespace, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	namespace, err := c.Clientset.CoreV1().Namespaces().
-		Get(c.Context, name, metav1.GetOptions{})
+		Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get namespace %s: %w", name, err)
+		return nil, wrapAPIError(err, "get", "namespace", "", name)
 	}
 	return namespace, nil
 }
 
-// NamespaceExists checks if a namespace exists
+// NamespaceExists checks if a namespace exists.
+// Only a not-found response yields false; other API failures are returned as errors.
 func (c *Client) NamespaceExists(name string) (bool, error) {
 	_, err := c.GetNamespace(name)
-	if err != nil {
+	if errors.Is(err, ErrNotFound) {
 		return false, nil
 	}
+	if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
 // GetPods retrieves all pods in a namespace
 func (c *Client) GetPods(namespace string) ([]corev1.Pod, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	list, err := c.Clientset.CoreV1().Pods(namespace).
-		List(c.Context, metav1.ListOptions{})
+		List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %w", err)
+		return nil, wrapAPIError(err, "list", "pods", namespace, "")
 	}
 	return list.Items, nil
 }
 
 // GetPod retrieves a specific pod by name
 func (c *Client) GetPod(namespace, name string) (*corev1.Pod, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	pod, err := c.Clientset.CoreV1().Pods(namespace).
-		Get(c.Context, name, metav1.GetOptions{})
+		Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
+		return nil, wrapAPIError(err, "get", "pod", namespace, name)
 	}
 	return pod, nil
 }
 
-// IsDeploymentReady checks if a deployment is ready
+// IsDeploymentReady checks if a deployment has completed its rollout
 func (c *Client) IsDeploymentReady(namespace, name string) (bool, error) {
-	deployment, err := c.GetDeployment(namespace, name)
+	status, err := c.GetRolloutStatus(namespace, name)
 	if err != nil {
 		return false, err
 	}
+	return status.Done, nil
+}
 
-	if deployment.Status.ReadyReplicas < *deployment.Spec.Replicas {
-		return false, nil
+// StreamPodLogs opens a stream of container logs for a pod
+func (c *Client) StreamPodLogs(ctx context.Context, namespace, pod string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		return nil, wrapAPIError(err, "stream logs of", "pod", namespace, pod)
 	}
-
-	return true, nil
+	return stream, nil
 }
 
+// GetEvents retrieves the events in a namespace matching a field selector
+// such as "involvedObject.name=uecm-0" (an empty selector returns all events)
+func (c *Client) GetEvents(namespace, fieldSelector string) ([]corev1.Event, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	list, err := c.Clientset.CoreV1().Events(namespace).
+		List(ctx, metav1.ListOptions{FieldSelector: fieldSelector})
+	if err != nil {
+		return nil, wrapAPIError(err, "list", "events", namespace, "")
+	}
+	return list.Items, nil
+}
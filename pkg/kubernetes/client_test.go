@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: lab-a
+clusters:
+- name: lab-a
+  cluster:
+    server: https://lab-a.example:6443
+- name: lab-b
+  cluster:
+    server: https://lab-b.example:6443
+contexts:
+- name: lab-a
+  context:
+    cluster: lab-a
+    user: tester
+- name: lab-b
+  context:
+    cluster: lab-b
+    user: tester
+users:
+- name: tester
+  user:
+    token: secret
+`
+
+func TestNewClient(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		opts        ClientOptions
+		wantContext string
+		wantHost    string
+		wantErr     bool
+	}{
+		{
+			name:        "current context",
+			opts:        ClientOptions{KubeconfigPath: kubeconfig},
+			wantContext: "lab-a",
+			wantHost:    "https://lab-a.example:6443",
+		},
+		{
+			name:        "selected context with limits",
+			opts:        ClientOptions{KubeconfigPath: kubeconfig, ContextName: "lab-b", Timeout: 5 * time.Second, QPS: 50, Burst: 100},
+			wantContext: "lab-b",
+			wantHost:    "https://lab-b.example:6443",
+		},
+		{
+			name:    "unknown context",
+			opts:    ClientOptions{KubeconfigPath: kubeconfig, ContextName: "lab-c"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if client.ContextName != tt.wantContext {
+				t.Errorf("ContextName = %s, want %s", client.ContextName, tt.wantContext)
+			}
+			if client.Host != tt.wantHost {
+				t.Errorf("Host = %s, want %s", client.Host, tt.wantHost)
+			}
+			if client.Timeout != tt.opts.Timeout {
+				t.Errorf("Timeout = %v, want %v", client.Timeout, tt.opts.Timeout)
+			}
+			if tt.opts.QPS > 0 && client.RESTConfig.QPS != tt.opts.QPS {
+				t.Errorf("QPS = %v, want %v", client.RESTConfig.QPS, tt.opts.QPS)
+			}
+			if tt.opts.Burst > 0 && client.RESTConfig.Burst != tt.opts.Burst {
+				t.Errorf("Burst = %d, want %d", client.RESTConfig.Burst, tt.opts.Burst)
+			}
+		})
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "run"))
+	client, err := NewClient(ctx, ClientOptions{KubeconfigPath: kubeconfig, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	// An interrupt cancels the run, but the cleanup that follows still needs the cluster
+	cancel()
+
+	reqCtx, reqCancel := client.requestContext()
+	defer reqCancel()
+	if err := reqCtx.Err(); err != nil {
+		t.Errorf("requestContext() error = %v, want a live context", err)
+	}
+	if _, ok := reqCtx.Deadline(); !ok {
+		t.Error("requestContext() has no deadline, want the client timeout")
+	}
+	if got := reqCtx.Value(key{}); got != "run" {
+		t.Errorf("requestContext() value = %v, want run", got)
+	}
+}
@@ -0,0 +1,408 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CopyFromPodOptions configures a copy of files out of a container
+type CopyFromPodOptions struct {
+	Namespace string
+	Pod       string
+	Container string
+	// Paths are absolute remote paths; shell glob patterns are expanded in the container
+	Paths []string
+	// LocalDir receives the files, keeping their remote directory layout
+	LocalDir string
+	// MaxFileSize skips files larger than this many bytes (0 means no limit)
+	MaxFileSize int64
+	// MaxTotalSize aborts the copy once this many bytes were written (0 means no limit)
+	MaxTotalSize int64
+	Progress     ProgressFunc
+}
+
+// CopyToPodOptions configures a copy of local files into a container
+type CopyToPodOptions struct {
+	Namespace string
+	Pod       string
+	Container string
+	// Paths are local files or directories; glob patterns are expanded locally
+	Paths []string
+	// RemoteDir is created if missing and receives the files by base name
+	RemoteDir string
+	// MaxFileSize skips files larger than this many bytes (0 means no limit)
+	MaxFileSize int64
+	Progress    ProgressFunc
+}
+
+// CopyProgress reports the transfer state of a single file
+type CopyProgress struct {
+	Path  string
+	Bytes int64
+	Total int64
+	Done  bool
+}
+
+// ProgressFunc is called as files are transferred
+type ProgressFunc func(CopyProgress)
+
+// CopiedFile describes a file transferred between the local host and a container
+type CopiedFile struct {
+	RemotePath string
+	LocalPath  string
+	Size       int64
+	Mode       os.FileMode
+	ModTime    time.Time
+}
+
+// CopyResult summarises a file transfer
+type CopyResult struct {
+	Files   []CopiedFile
+	Skipped []string
+	Bytes   int64
+}
+
+// errTotalSizeExceeded stops a transfer that hit MaxTotalSize
+var errTotalSizeExceeded = errors.New("total size limit exceeded")
+
+// progressChunk is the number of bytes between progress callbacks
+const progressChunk = 1 << 20
+
+// CopyFromPod streams a tar archive of the requested paths out of a container
+// and extracts it below LocalDir, preserving file modes and modification times.
+func (c *Client) CopyFromPod(ctx context.Context, opts CopyFromPodOptions) (*CopyResult, error) {
+	if len(opts.Paths) == 0 {
+		return nil, fmt.Errorf("no paths to copy from pod %s", opts.Pod)
+	}
+	if err := os.MkdirAll(opts.LocalDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local directory: %w", err)
+	}
+
+	quoted := make([]string, 0, len(opts.Paths))
+	for _, p := range opts.Paths {
+		quoted = append(quoted, quoteGlob(p))
+	}
+	script := "tar cf - " + strings.Join(quoted, " ")
+
+	copyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	var stderr strings.Builder
+	type execOutcome struct {
+		result *ExecResult
+		err    error
+	}
+	done := make(chan execOutcome, 1)
+	go func() {
+		result, err := c.Exec(copyCtx, ExecOptions{
+			Namespace: opts.Namespace,
+			Pod:       opts.Pod,
+			Container: opts.Container,
+			Command:   []string{"sh", "-c", script},
+			Stdout:    writer,
+			Stderr:    &stderr,
+		})
+		writer.CloseWithError(err)
+		done <- execOutcome{result: result, err: err}
+	}()
+
+	result, extractErr := extractTar(reader, opts)
+	if extractErr != nil {
+		cancel()
+		reader.CloseWithError(extractErr)
+	} else {
+		// Drain any trailing padding so the remote tar can exit
+		io.Copy(io.Discard, reader)
+	}
+	outcome := <-done
+
+	if extractErr != nil {
+		return result, fmt.Errorf("failed to extract files from pod %s: %w", opts.Pod, extractErr)
+	}
+	if outcome.err != nil {
+		return result, outcome.err
+	}
+	if outcome.result.ExitCode != 0 && len(result.Files) == 0 {
+		return result, fmt.Errorf("tar in pod %s exited with code %d: %s",
+			opts.Pod, outcome.result.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return result, nil
+}
+
+// extractTar writes the archive entries below opts.LocalDir
+func extractTar(r io.Reader, opts CopyFromPodOptions) (*CopyResult, error) {
+	result := &CopyResult{}
+	type dirTime struct {
+		path    string
+		modTime time.Time
+	}
+	var dirs []dirTime
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		localPath, err := safeJoin(opts.LocalDir, name)
+		if err != nil {
+			return result, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(localPath, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return result, fmt.Errorf("failed to create directory: %w", err)
+			}
+			dirs = append(dirs, dirTime{path: localPath, modTime: hdr.ModTime})
+		case tar.TypeReg:
+			if opts.MaxFileSize > 0 && hdr.Size > opts.MaxFileSize {
+				result.Skipped = append(result.Skipped, name)
+				continue
+			}
+			if opts.MaxTotalSize > 0 && result.Bytes+hdr.Size > opts.MaxTotalSize {
+				result.Skipped = append(result.Skipped, name)
+				return result, errTotalSizeExceeded
+			}
+			if err := writeFile(tr, localPath, hdr, name, opts.Progress); err != nil {
+				return result, err
+			}
+			result.Bytes += hdr.Size
+			result.Files = append(result.Files, CopiedFile{
+				RemotePath: name,
+				LocalPath:  localPath,
+				Size:       hdr.Size,
+				Mode:       hdr.FileInfo().Mode(),
+				ModTime:    hdr.ModTime,
+			})
+		default:
+			// Links and special files are not copied
+			result.Skipped = append(result.Skipped, name)
+		}
+	}
+
+	// Directory times are set last because writing files updates them
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+	return result, nil
+}
+
+// writeFile extracts a single regular file and restores its mode and mtime
+func writeFile(r io.Reader, localPath string, hdr *tar.Header, name string, progress ProgressFunc) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	mode := hdr.FileInfo().Mode().Perm()
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	_, err = io.Copy(file, &progressReader{r: r, path: name, total: hdr.Size, progress: progress})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	if err := os.Chmod(localPath, mode); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", name, err)
+	}
+	if err := os.Chtimes(localPath, hdr.ModTime, hdr.ModTime); err != nil {
+		return fmt.Errorf("failed to set mtime of %s: %w", name, err)
+	}
+	if progress != nil {
+		progress(CopyProgress{Path: name, Bytes: hdr.Size, Total: hdr.Size, Done: true})
+	}
+	return nil
+}
+
+// CopyToPod streams a tar archive of local files into RemoteDir inside a container,
+// preserving file modes and modification times.
+func (c *Client) CopyToPod(ctx context.Context, opts CopyToPodOptions) (*CopyResult, error) {
+	if opts.RemoteDir == "" {
+		return nil, fmt.Errorf("remote directory is required")
+	}
+
+	var sources []string
+	for _, pattern := range opts.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no local files match %s", pattern)
+		}
+		sources = append(sources, matches...)
+	}
+
+	copyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	result := &CopyResult{}
+	archiveErr := make(chan error, 1)
+	go func() {
+		err := writeTar(writer, sources, opts, result)
+		writer.CloseWithError(err)
+		archiveErr <- err
+	}()
+
+	var stderr strings.Builder
+	remoteDir := path.Clean(opts.RemoteDir)
+	execResult, err := c.Exec(copyCtx, ExecOptions{
+		Namespace: opts.Namespace,
+		Pod:       opts.Pod,
+		Container: opts.Container,
+		Command:   []string{"sh", "-c", `mkdir -p "$1" && tar xf - -C "$1"`, "sh", remoteDir},
+		Stdin:     reader,
+		Stderr:    &stderr,
+	})
+	reader.Close()
+	if tarErr := <-archiveErr; tarErr != nil && !errors.Is(tarErr, io.ErrClosedPipe) {
+		return result, fmt.Errorf("failed to archive local files: %w", tarErr)
+	}
+	if err != nil {
+		return result, err
+	}
+	if execResult.ExitCode != 0 {
+		return result, fmt.Errorf("tar in pod %s exited with code %d: %s",
+			opts.Pod, execResult.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	for i := range result.Files {
+		result.Files[i].RemotePath = path.Join(remoteDir, result.Files[i].RemotePath)
+	}
+	return result, nil
+}
+
+// writeTar archives each source path under its base name
+func writeTar(w io.Writer, sources []string, opts CopyToPodOptions, result *CopyResult) error {
+	tw := tar.NewWriter(w)
+	for _, source := range sources {
+		base := filepath.Dir(source)
+		err := filepath.Walk(source, func(localPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, localPath)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				result.Skipped = append(result.Skipped, localPath)
+				return nil
+			}
+			if info.Mode().IsRegular() && opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+				result.Skipped = append(result.Skipped, localPath)
+				return nil
+			}
+
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			file, err := os.Open(localPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(tw, &progressReader{r: file, path: localPath, total: info.Size(), progress: opts.Progress}); err != nil {
+				return err
+			}
+			if opts.Progress != nil {
+				opts.Progress(CopyProgress{Path: localPath, Bytes: info.Size(), Total: info.Size(), Done: true})
+			}
+
+			result.Bytes += info.Size()
+			result.Files = append(result.Files, CopiedFile{
+				RemotePath: name,
+				LocalPath:  localPath,
+				Size:       info.Size(),
+				Mode:       info.Mode(),
+				ModTime:    info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// progressReader reports progress roughly every progressChunk bytes
+type progressReader struct {
+	r        io.Reader
+	path     string
+	total    int64
+	read     int64
+	reported int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	if p.progress != nil && p.read-p.reported >= progressChunk && p.read < p.total {
+		p.reported = p.read
+		p.progress(CopyProgress{Path: p.path, Bytes: p.read, Total: p.total})
+	}
+	return n, err
+}
+
+// safeJoin joins an archive entry name to dir, rejecting names that escape it
+func safeJoin(dir, name string) (string, error) {
+	joined := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %s escapes destination", name)
+	}
+	return joined, nil
+}
+
+// quoteGlob escapes a path for sh while leaving glob metacharacters active
+func quoteGlob(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		switch {
+		case r == '*' || r == '?' || r == '[' || r == ']':
+			b.WriteRune(r)
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '/' || r == '.' || r == '_' || r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,199 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// execFunc adapts a function to the Executor interface
+type execFunc func(ctx context.Context, opts ExecOptions) (*ExecResult, error)
+
+func (f execFunc) Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	return f(ctx, opts)
+}
+
+type tarEntry struct {
+	name    string
+	body    string
+	mode    int64
+	modTime time.Time
+	dir     bool
+}
+
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, ModTime: e.modTime, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if !e.dir {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatalf("Failed to write tar body: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCopyFromPod(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := buildTar(t, []tarEntry{
+		{name: "logstore/TspCore/", mode: 0755, modTime: modTime, dir: true},
+		{name: "logstore/TspCore/core.uecm.123", body: "core data", mode: 0600, modTime: modTime},
+		{name: "logstore/TspCore/core.huge.456", body: "0123456789abcdef", mode: 0600, modTime: modTime},
+	})
+
+	var command []string
+	client := &Client{Executor: execFunc(func(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+		command = opts.Command
+		opts.Stdout.Write(archive)
+		return &ExecResult{}, nil
+	})}
+
+	localDir := t.TempDir()
+	var progress []CopyProgress
+	result, err := client.CopyFromPod(context.Background(), CopyFromPodOptions{
+		Namespace:   "miniudm",
+		Pod:         "uecm-0",
+		Container:   "mcc",
+		Paths:       []string{"/logstore/TspCore/core.*"},
+		LocalDir:    localDir,
+		MaxFileSize: 10,
+		Progress:    func(p CopyProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("CopyFromPod() error = %v", err)
+	}
+
+	if got := command[len(command)-1]; got != "tar cf - /logstore/TspCore/core.*" {
+		t.Errorf("remote command = %q", got)
+	}
+	if len(result.Files) != 1 || len(result.Skipped) != 1 {
+		t.Fatalf("copied %d files, skipped %d; want 1 and 1", len(result.Files), len(result.Skipped))
+	}
+
+	localPath := filepath.Join(localDir, "logstore", "TspCore", "core.uecm.123")
+	if result.Files[0].LocalPath != localPath {
+		t.Errorf("LocalPath = %s, want %s", result.Files[0].LocalPath, localPath)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		t.Fatalf("Failed to stat copied file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), modTime)
+	}
+	if len(progress) != 1 || !progress[0].Done {
+		t.Errorf("progress = %+v, want one completed entry", progress)
+	}
+}
+
+func TestCopyFromPodRejectsTraversal(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "../../etc/passwd", body: "x", mode: 0644, modTime: time.Now()},
+	})
+	client := &Client{Executor: execFunc(func(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+		opts.Stdout.Write(archive)
+		return &ExecResult{}, nil
+	})}
+
+	localDir := t.TempDir()
+	result, err := client.CopyFromPod(context.Background(), CopyFromPodOptions{
+		Namespace: "miniudm",
+		Pod:       "uecm-0",
+		Paths:     []string{"/tmp"},
+		LocalDir:  localDir,
+	})
+	if err != nil {
+		t.Fatalf("CopyFromPod() error = %v", err)
+	}
+	// Cleaning the rooted name keeps the entry inside the destination
+	if len(result.Files) != 1 || result.Files[0].LocalPath != filepath.Join(localDir, "etc", "passwd") {
+		t.Errorf("Files = %+v", result.Files)
+	}
+}
+
+func TestCopyToPod(t *testing.T) {
+	srcDir := t.TempDir()
+	libPath := filepath.Join(srcDir, "libuecm.so")
+	if err := os.WriteFile(libPath, []byte("library"), 0755); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	received := map[string]int64{}
+	var command []string
+	client := &Client{Executor: execFunc(func(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+		command = opts.Command
+		tr := tar.NewReader(opts.Stdin)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			received[hdr.Name] = hdr.Mode
+		}
+		return &ExecResult{}, nil
+	})}
+
+	result, err := client.CopyToPod(context.Background(), CopyToPodOptions{
+		Namespace: "miniudm",
+		Pod:       "uecm-0",
+		Container: "mcc",
+		Paths:     []string{filepath.Join(srcDir, "*.so")},
+		RemoteDir: "/tcnVol/uecm",
+	})
+	if err != nil {
+		t.Fatalf("CopyToPod() error = %v", err)
+	}
+
+	if mode, ok := received["libuecm.so"]; !ok || mode&0777 != 0755 {
+		t.Errorf("received = %v, want libuecm.so with mode 0755", received)
+	}
+	if command[len(command)-1] != "/tcnVol/uecm" {
+		t.Errorf("remote dir argument = %q", command[len(command)-1])
+	}
+	if len(result.Files) != 1 || result.Files[0].RemotePath != "/tcnVol/uecm/libuecm.so" {
+		t.Errorf("Files = %+v", result.Files)
+	}
+}
+
+func TestQuoteGlob(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/logstore/TspCore/core.*", expected: "/logstore/TspCore/core.*"},
+		{path: "/tmp/my file", expected: `/tmp/my\ file`},
+		{path: "/tmp/$(reboot)", expected: `/tmp/\$\(reboot\)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := quoteGlob(tt.path); got != tt.expected {
+				t.Errorf("quoteGlob() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
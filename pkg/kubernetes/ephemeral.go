@@ -0,0 +1,85 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ephemeralPollInterval is how often RunEphemeralContainer checks whether the container runs
+const ephemeralPollInterval = 1 * time.Second
+
+// ephemeralFailureReasons are waiting reasons an ephemeral container does not recover from
+var ephemeralFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerError":       true,
+	"CreateContainerConfigError": true,
+}
+
+// RunEphemeralContainer adds an ephemeral container to a running pod and waits
+// until it runs or ctx ends. The container shares the pod's network namespace.
+// Ephemeral containers cannot be removed; they stop when their command exits.
+func (c *Client) RunEphemeralContainer(ctx context.Context, namespace, pod string, container corev1.EphemeralContainer) error {
+	current, err := c.GetPod(namespace, pod)
+	if err != nil {
+		return err
+	}
+	updated := current.DeepCopy()
+	updated.Spec.EphemeralContainers = append(updated.Spec.EphemeralContainers, container)
+
+	reqCtx, cancel := c.requestContext()
+	_, err = c.Clientset.CoreV1().Pods(namespace).
+		UpdateEphemeralContainers(reqCtx, pod, updated, metav1.UpdateOptions{})
+	cancel()
+	if err != nil {
+		return wrapAPIError(err, "update", "pods/ephemeralcontainers", namespace, pod)
+	}
+
+	ticker := time.NewTicker(ephemeralPollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := c.GetPod(namespace, pod)
+		if err != nil {
+			return err
+		}
+		if err := ephemeralState(current, container.Name); err != errEphemeralPending {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ephemeral container %s in pod %s did not start: %w", container.Name, pod, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// errEphemeralPending reports an ephemeral container that is still starting
+var errEphemeralPending = errors.New("ephemeral container pending")
+
+// ephemeralState returns nil once the named ephemeral container runs,
+// errEphemeralPending while it starts and an error if it cannot run
+func ephemeralState(pod *corev1.Pod, name string) error {
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if status.Name != name {
+			continue
+		}
+		switch state := status.State; {
+		case state.Running != nil:
+			return nil
+		case state.Terminated != nil:
+			return fmt.Errorf("ephemeral container %s in pod %s exited with code %d (%s)",
+				name, pod.Name, state.Terminated.ExitCode, state.Terminated.Reason)
+		case state.Waiting != nil && ephemeralFailureReasons[state.Waiting.Reason]:
+			return fmt.Errorf("ephemeral container %s in pod %s cannot start (%s): %s",
+				name, pod.Name, state.Waiting.Reason, state.Waiting.Message)
+		}
+	}
+	return errEphemeralPending
+}
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEphemeralState(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   corev1.ContainerState
+		wantErr string
+	}{
+		{name: "running", state: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{name: "creating", state: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}, wantErr: "pending"},
+		{name: "image pull failure", state: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"}}, wantErr: "cannot start (ErrImagePull)"},
+		{name: "exited", state: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}}, wantErr: "exited with code 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "uecm-a"},
+				Status: corev1.PodStatus{EphemeralContainerStatuses: []corev1.ContainerStatus{
+					{Name: "other", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+					{Name: "pcap", State: tt.state},
+				}},
+			}
+			err := ephemeralState(pod, "pcap")
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ephemeralState() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ephemeralState() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ephemeralState(&corev1.Pod{}, "pcap"); err != errEphemeralPending {
+		t.Errorf("ephemeralState() without status error = %v, want pending", err)
+	}
+}
+
+func TestRunEphemeralContainer(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "uecm-a", Namespace: "miniudm"},
+	})
+	// The kubelet reports the new container as running
+	clientset.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		pod := action.(k8stesting.UpdateAction).GetObject().(*corev1.Pod)
+		for _, container := range pod.Spec.EphemeralContainers {
+			pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+				Name:  container.Name,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			})
+		}
+		return false, nil, nil
+	})
+	client := &Client{Clientset: clientset, Context: context.Background()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	container := corev1.EphemeralContainer{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "pcap", Image: "netshoot"}}
+	if err := client.RunEphemeralContainer(ctx, "miniudm", "uecm-a", container); err != nil {
+		t.Fatalf("RunEphemeralContainer() error = %v", err)
+	}
+
+	pod, err := client.GetPod("miniudm", "uecm-a")
+	if err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if len(pod.Spec.EphemeralContainers) != 1 || pod.Spec.EphemeralContainers[0].Image != "netshoot" {
+		t.Errorf("RunEphemeralContainer() pod containers = %+v, want the pcap container", pod.Spec.EphemeralContainers)
+	}
+
+	if err := client.RunEphemeralContainer(ctx, "miniudm", "missing", container); err == nil {
+		t.Error("RunEphemeralContainer() on a missing pod should return error")
+	}
+}
@@ -0,0 +1,125 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Sentinel errors classifying Kubernetes API failures. Use errors.Is to test
+// errors returned by Client methods against them.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrTimeout      = errors.New("timed out")
+	ErrUnreachable  = errors.New("API server unreachable")
+)
+
+// APIError describes a failed Kubernetes API request
+type APIError struct {
+	// Kind is one of the sentinel errors, or nil if the failure is unclassified
+	Kind      error
+	Verb      string
+	Resource  string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *APIError) Error() string {
+	target := e.Resource
+	if e.Name != "" {
+		target = fmt.Sprintf("%s %s", e.Resource, e.Name)
+	}
+	if e.Namespace != "" {
+		target = fmt.Sprintf("%s in namespace %s", target, e.Namespace)
+	}
+	return fmt.Sprintf("failed to %s %s: %v", e.Verb, target, e.Err)
+}
+
+// Unwrap exposes both the classification and the underlying error
+func (e *APIError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Hint returns an actionable suggestion for the failure, or "" if there is none
+func (e *APIError) Hint() string {
+	switch e.Kind {
+	case ErrNotFound:
+		return fmt.Sprintf("check the %s name and the namespace (-n); list-deployments shows what exists", e.Resource)
+	case ErrForbidden:
+		return fmt.Sprintf("the current credentials may not %s %s; ask a cluster admin for the RBAC permission or switch --context", e.Verb, e.Resource)
+	case ErrUnauthorized:
+		return "the API server rejected the credentials; refresh the token in your kubeconfig (log in again) or check --kubeconfig/--context"
+	case ErrTimeout:
+		return "the API server did not answer in time; check cluster load or raise kubernetes.timeout in the config"
+	case ErrUnreachable:
+		return "the API server could not be reached; check VPN/network access and that --kubeconfig/--context point at the right cluster"
+	}
+	return ""
+}
+
+// Hint returns an actionable suggestion for an error returned by this package,
+// or "" if the error is not a classified API failure
+func Hint(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Hint()
+	}
+	return ""
+}
+
+// wrapAPIError classifies err and wraps it with the request it belongs to
+func wrapAPIError(err error, verb, resource, namespace, name string) error {
+	return &APIError{
+		Kind:      classify(err),
+		Verb:      verb,
+		Resource:  resource,
+		Namespace: namespace,
+		Name:      name,
+		Err:       err,
+	}
+}
+
+// classify maps an API or transport error onto a sentinel error
+func classify(err error) error {
+	switch {
+	case apierrors.IsNotFound(err):
+		return ErrNotFound
+	case apierrors.IsForbidden(err):
+		return ErrForbidden
+	case apierrors.IsUnauthorized(err):
+		return ErrUnauthorized
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded), isNetworkTimeout(err):
+		return ErrTimeout
+	case apierrors.IsServiceUnavailable(err), isNetworkError(err):
+		return ErrUnreachable
+	}
+	return nil
+}
+
+// isNetworkTimeout reports whether err is a transport-level timeout
+func isNetworkTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isNetworkError reports whether err comes from failing to reach the server
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	return errors.As(err, &opErr) ||
+		errors.As(err, &dnsErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		(errors.As(err, &urlErr) && urlErr.Op != "")
+}
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var namespacesResource = schema.GroupResource{Resource: "namespaces"}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "not found", err: apierrors.NewNotFound(namespacesResource, "miniudm"), expected: ErrNotFound},
+		{name: "forbidden", err: apierrors.NewForbidden(namespacesResource, "miniudm", errors.New("rbac")), expected: ErrForbidden},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("token expired"), expected: ErrUnauthorized},
+		{name: "server timeout", err: apierrors.NewTimeoutError("slow", 5), expected: ErrTimeout},
+		{name: "context deadline", err: context.DeadlineExceeded, expected: ErrTimeout},
+		{
+			name:     "connection refused",
+			err:      &url.Error{Op: "Get", URL: "https://10.0.0.1:6443", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+			expected: ErrUnreachable,
+		},
+		{name: "unclassified", err: apierrors.NewBadRequest("bad"), expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.err); got != tt.expected {
+				t.Errorf("classify() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNamespaceExists(t *testing.T) {
+	tests := []struct {
+		name     string
+		reaction error
+		expected bool
+		wantKind error
+	}{
+		{name: "exists", expected: true},
+		{name: "not found", reaction: apierrors.NewNotFound(namespacesResource, "miniudm"), expected: false},
+		{name: "forbidden", reaction: apierrors.NewForbidden(namespacesResource, "miniudm", errors.New("rbac")), wantKind: ErrForbidden},
+		{name: "unauthorized", reaction: apierrors.NewUnauthorized("token expired"), wantKind: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "miniudm"}})
+			if tt.reaction != nil {
+				clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.reaction
+				})
+			}
+			client := &Client{Clientset: clientset, Context: context.Background()}
+
+			exists, err := client.NamespaceExists("miniudm")
+			if tt.wantKind != nil {
+				if !errors.Is(err, tt.wantKind) {
+					t.Fatalf("NamespaceExists() error = %v, want %v", err, tt.wantKind)
+				}
+				if Hint(err) == "" {
+					t.Error("Hint() returned empty hint for classified error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NamespaceExists() error = %v", err)
+			}
+			if exists != tt.expected {
+				t.Errorf("NamespaceExists() = %v, want %v", exists, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecOptions describes a command to run inside a container
+type ExecOptions struct {
+	Namespace string
+	Pod       string
+	Container string
+	Command   []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	TTY       bool
+}
+
+// ExecResult holds the outcome of a command run inside a container.
+// Stdout and Stderr are only populated by ExecOutput.
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// Executor runs commands inside containers.
+// Client uses an SPDYExecutor by default; tests can substitute a stand-in.
+type Executor interface {
+	Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error)
+}
+
+// SPDYExecutor implements Executor using the pods/exec subresource over SPDY
+type SPDYExecutor struct {
+	Clientset  kubernetes.Interface
+	RESTConfig *rest.Config
+}
+
+// NewSPDYExecutor creates an executor for the given clientset and REST config
+func NewSPDYExecutor(clientset kubernetes.Interface, config *rest.Config) *SPDYExecutor {
+	return &SPDYExecutor{
+		Clientset:  clientset,
+		RESTConfig: config,
+	}
+}
+
+// Exec runs the command and streams its output. A non-zero exit status is
+// reported through ExecResult.ExitCode rather than as an error.
+func (e *SPDYExecutor) Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	req := e.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(opts.Namespace).
+		Name(opts.Pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil && !opts.TTY,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(e.RESTConfig, "POST", req.URL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor for pod %s: %w", opts.Pod, err)
+	}
+
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Stderr: opts.Stderr,
+		Tty:    opts.TTY,
+	})
+	return exitResult(err)
+}
+
+// exitResult converts a stream error into an exit code, keeping transport failures as errors
+func exitResult(err error) (*ExecResult, error) {
+	if err == nil {
+		return &ExecResult{ExitCode: 0}, nil
+	}
+
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return &ExecResult{ExitCode: exitErr.ExitStatus()}, nil
+	}
+	return nil, err
+}
+
+// Exec runs a command inside a container using the client's executor.
+// The call blocks until the command exits or ctx is cancelled.
+func (c *Client) Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	if c.Executor == nil {
+		return nil, fmt.Errorf("no executor configured")
+	}
+	if opts.Namespace == "" || opts.Pod == "" {
+		return nil, fmt.Errorf("namespace and pod are required for exec")
+	}
+	if len(opts.Command) == 0 {
+		return nil, fmt.Errorf("command is required for exec")
+	}
+
+	result, err := c.Executor.Exec(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("exec in pod %s/%s failed: %w", opts.Pod, opts.Container, err)
+	}
+	return result, nil
+}
+
+// ExecOutput runs a command and captures its stdout and stderr in the result.
+// Any Stdout or Stderr writers in opts are replaced.
+func (c *Client) ExecOutput(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	var stdout, stderr bytes.Buffer
+	opts.Stdout = &stdout
+	opts.Stderr = &stderr
+
+	result, err := c.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result, nil
+}
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// stubExecutor echoes stdin to stdout and returns a fixed exit code
+type stubExecutor struct {
+	exitCode int
+	err      error
+	last     ExecOptions
+}
+
+func (s *stubExecutor) Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	s.last = opts
+	if s.err != nil {
+		return nil, s.err
+	}
+	if opts.Stdin != nil && opts.Stdout != nil {
+		if _, err := io.Copy(opts.Stdout, opts.Stdin); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Stderr != nil {
+		io.WriteString(opts.Stderr, "warning")
+	}
+	return &ExecResult{ExitCode: s.exitCode}, nil
+}
+
+func TestExecOutput(t *testing.T) {
+	stub := &stubExecutor{exitCode: 3}
+	client := &Client{Executor: stub}
+
+	result, err := client.ExecOutput(context.Background(), ExecOptions{
+		Namespace: "miniudm",
+		Pod:       "uecm-0",
+		Container: "mcc",
+		Command:   []string{"cat"},
+		Stdin:     strings.NewReader("hello"),
+	})
+	if err != nil {
+		t.Fatalf("ExecOutput() error = %v", err)
+	}
+
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+	if result.Stdout != "hello" {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, "hello")
+	}
+	if result.Stderr != "warning" {
+		t.Errorf("Stderr = %q, want %q", result.Stderr, "warning")
+	}
+	if stub.last.Container != "mcc" {
+		t.Errorf("Container = %q, want %q", stub.last.Container, "mcc")
+	}
+}
+
+func TestExecValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *Client
+		opts   ExecOptions
+	}{
+		{
+			name:   "no executor",
+			client: &Client{},
+			opts:   ExecOptions{Namespace: "ns", Pod: "pod", Command: []string{"true"}},
+		},
+		{
+			name:   "missing pod",
+			client: &Client{Executor: &stubExecutor{}},
+			opts:   ExecOptions{Namespace: "ns", Command: []string{"true"}},
+		},
+		{
+			name:   "missing command",
+			client: &Client{Executor: &stubExecutor{}},
+			opts:   ExecOptions{Namespace: "ns", Pod: "pod"},
+		},
+		{
+			name:   "executor failure",
+			client: &Client{Executor: &stubExecutor{err: errors.New("connection refused")}},
+			opts:   ExecOptions{Namespace: "ns", Pod: "pod", Command: []string{"true"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.client.Exec(context.Background(), tt.opts); err == nil {
+				t.Error("Exec() should return error")
+			}
+		})
+	}
+}
+
+func TestExitResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantErr  bool
+	}{
+		{
+			name:     "success",
+			err:      nil,
+			wantCode: 0,
+		},
+		{
+			name:     "non-zero exit",
+			err:      utilexec.CodeExitError{Err: errors.New("command terminated with exit code 2"), Code: 2},
+			wantCode: 2,
+		},
+		{
+			name:    "transport error",
+			err:     errors.New("unable to upgrade connection"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := exitResult(tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exitResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && result.ExitCode != tt.wantCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package fake provides an in-memory kubernetes.ClusterClient for tests.
+// Cluster objects are served by client-go's fake clientset and commands run
+// through exec are answered by a scriptable Executor.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// NewClient creates a kubernetes.Client backed by a fake clientset seeded with
+// objects and by a new scriptable Executor, which is also returned.
+// Access reviews are allowed unless denied with Deny.
+func NewClient(objects ...runtime.Object) (*kubernetes.Client, *Executor) {
+	clientset := k8sfake.NewSimpleClientset(objects...)
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", reviewReactor(true))
+
+	executor := &Executor{}
+	client := &kubernetes.Client{
+		Clientset: clientset,
+		Executor:  executor,
+		Context:   context.Background(),
+	}
+	return client, executor
+}
+
+// Deny makes access reviews for verb on resource (e.g. "pods/exec") fail
+// for a client created by NewClient
+func Deny(client *kubernetes.Client, verb, resource string) {
+	clientset := client.Clientset.(*k8sfake.Clientset)
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		name := attrs.Resource
+		if attrs.Subresource != "" {
+			name += "/" + attrs.Subresource
+		}
+		if attrs.Verb != verb || name != resource {
+			return false, nil, nil
+		}
+		return reviewReactor(false)(action)
+	})
+}
+
+// reviewReactor answers access reviews with a fixed decision
+func reviewReactor(allowed bool) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		review.Status.Allowed = allowed
+		if !allowed {
+			review.Status.Reason = "denied by fake"
+		}
+		return true, review, nil
+	}
+}
+
+// Handler answers a command run through the fake Executor
+type Handler func(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error)
+
+// rule pairs a command prefix with its handler
+type rule struct {
+	pod     string
+	prefix  string
+	handler Handler
+}
+
+// Executor is a scriptable kubernetes.Executor. Commands are matched against
+// registered rules in order; unmatched commands fail.
+type Executor struct {
+	mu    sync.Mutex
+	rules []rule
+	calls []kubernetes.ExecOptions
+}
+
+// On registers a handler for commands whose space-joined form starts with prefix.
+// An empty pod matches every pod.
+func (e *Executor) On(pod, prefix string, handler Handler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule{pod: pod, prefix: prefix, handler: handler})
+}
+
+// Exec implements kubernetes.Executor
+func (e *Executor) Exec(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error) {
+	command := strings.Join(opts.Command, " ")
+
+	e.mu.Lock()
+	recorded := opts
+	recorded.Stdin, recorded.Stdout, recorded.Stderr = nil, nil, nil
+	e.calls = append(e.calls, recorded)
+	var handler Handler
+	for _, r := range e.rules {
+		if (r.pod == "" || r.pod == opts.Pod) && strings.HasPrefix(command, r.prefix) {
+			handler = r.handler
+			break
+		}
+	}
+	e.mu.Unlock()
+
+	if handler == nil {
+		return nil, fmt.Errorf("fake executor: no handler for %q in pod %s", command, opts.Pod)
+	}
+	return handler(ctx, opts)
+}
+
+// Calls returns the commands executed so far, without their streams
+func (e *Executor) Calls() []kubernetes.ExecOptions {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	calls := make([]kubernetes.ExecOptions, len(e.calls))
+	copy(calls, e.calls)
+	return calls
+}
+
+// Reply returns a handler that writes stdout and exits with exitCode.
+// Like a real exec, it fails when ctx is already cancelled.
+func Reply(stdout string, exitCode int) Handler {
+	return func(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if opts.Stdout != nil {
+			if _, err := opts.Stdout.Write([]byte(stdout)); err != nil {
+				return nil, err
+			}
+		}
+		return &kubernetes.ExecResult{ExitCode: exitCode}, nil
+	}
+}
+
+// Stream returns a handler that writes lines to stdout and then blocks until
+// ctx is cancelled, like a long-running tail
+func Stream(lines ...string) Handler {
+	return func(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error) {
+		for _, line := range lines {
+			if _, err := opts.Stdout.Write([]byte(line + "\n")); err != nil {
+				return nil, err
+			}
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
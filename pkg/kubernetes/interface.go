@@ -0,0 +1,47 @@
+package kubernetes
+
+import (
+	"context"
+	"io"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ClusterClient is the set of cluster operations used by the symptom collector
+// and patch workflows. Client implements it against a real cluster; the fake
+// package provides an in-memory implementation for tests.
+type ClusterClient interface {
+	GetNamespace(name string) (*corev1.Namespace, error)
+	NamespaceExists(name string) (bool, error)
+
+	GetDeployments(namespace string) ([]v1.Deployment, error)
+	GetDeployment(namespace, name string) (*v1.Deployment, error)
+	IsDeploymentReady(namespace, name string) (bool, error)
+	GetRolloutStatus(namespace, name string) (*RolloutStatus, error)
+	RestartDeployment(namespace, name string) error
+
+	GetPods(namespace string) ([]corev1.Pod, error)
+	GetPod(namespace, name string) (*corev1.Pod, error)
+	GetPodsBySelector(namespace string, selector labels.Selector) ([]corev1.Pod, error)
+	GetPodHealth(namespace, name string) (*PodHealth, error)
+	ResolveTargets(namespace string, names []string, mode MatchMode) ([]ResolvedTarget, error)
+
+	Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error)
+	ExecOutput(ctx context.Context, opts ExecOptions) (*ExecResult, error)
+	CopyFromPod(ctx context.Context, opts CopyFromPodOptions) (*CopyResult, error)
+	CopyToPod(ctx context.Context, opts CopyToPodOptions) (*CopyResult, error)
+
+	RunEphemeralContainer(ctx context.Context, namespace, pod string, container corev1.EphemeralContainer) error
+
+	StreamPodLogs(ctx context.Context, namespace, pod string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+	GetEvents(namespace, fieldSelector string) ([]corev1.Event, error)
+
+	Preflight(ctx context.Context, workflow, namespace string) (*PreflightReport, error)
+	PreflightChecks(ctx context.Context, workflow, namespace string, checks []AccessCheck) (*PreflightReport, error)
+
+	Cluster() ClusterInfo
+}
+
+var _ ClusterClient = (*Client)(nil)
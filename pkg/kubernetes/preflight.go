@@ -0,0 +1,228 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessCheck is a single permission a workflow needs
+type AccessCheck struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	// ClusterScoped checks are evaluated without a namespace
+	ClusterScoped bool
+	// Namespace, if set, is checked instead of the namespace of the report
+	Namespace string
+	// Purpose explains why the workflow needs the permission
+	Purpose string
+}
+
+// String formats the checked resource like kubectl auth can-i
+func (a AccessCheck) String() string {
+	resource := a.Resource
+	if a.Group != "" {
+		resource = resource + "." + a.Group
+	}
+	if a.Subresource != "" {
+		resource = resource + "/" + a.Subresource
+	}
+	return resource
+}
+
+// AccessResult is the outcome of one access check
+type AccessResult struct {
+	AccessCheck
+	Namespace string
+	Allowed   bool
+	Reason    string
+	Err       error
+}
+
+// PreflightReport collects the access results for a workflow
+type PreflightReport struct {
+	Workflow  string
+	Namespace string
+	Results   []AccessResult
+}
+
+// Workflows supported by Preflight
+const (
+	WorkflowSymptomCollection = "symptom-collection"
+	WorkflowPatch             = "apply-patch"
+)
+
+// SymptomCollectionChecks are the permissions needed by symptom collection
+var SymptomCollectionChecks = []AccessCheck{
+	{Verb: "get", Resource: "namespaces", ClusterScoped: true, Purpose: "validate the namespace"},
+	{Verb: "list", Group: "apps", Resource: "deployments", Purpose: "resolve pod names"},
+	{Verb: "get", Group: "apps", Resource: "deployments", Purpose: "check rollout readiness"},
+	{Verb: "list", Resource: "pods", Purpose: "find running replicas"},
+	{Verb: "get", Resource: "pods", Purpose: "check container readiness"},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Purpose: "tail logs, enable traces, run pybot"},
+	{Verb: "get", Resource: "pods", Subresource: "log", Purpose: "collect container logs"},
+	{Verb: "list", Resource: "events", Purpose: "collect Kubernetes events"},
+}
+
+// EphemeralContainerCheck is needed to capture packets from an ephemeral container
+var EphemeralContainerCheck = AccessCheck{
+	Verb: "update", Resource: "pods", Subresource: "ephemeralcontainers", Purpose: "capture packets from an ephemeral container",
+}
+
+// SymptomCollectionChecksFor returns the permissions symptom collection needs
+// with cfg in namespace: SymptomCollectionChecks, plus ephemeral containers
+// when packets may be captured from one and access to the testclient when
+// pybot runs in another namespace
+func SymptomCollectionChecksFor(cfg *config.Config, namespace string) []AccessCheck {
+	checks := append([]AccessCheck(nil), SymptomCollectionChecks...)
+	if pcap := cfg.Symptom.Pcap; pcap.Enabled && (pcap.Mode == "auto" || pcap.Mode == "ephemeral") {
+		checks = append(checks, EphemeralContainerCheck)
+	}
+	if pybot := cfg.Pybot; len(pybot.Suites) > 0 && pybot.Namespace != "" && pybot.Namespace != namespace {
+		checks = append(checks,
+			AccessCheck{Verb: "list", Group: "apps", Resource: "deployments", Namespace: pybot.Namespace, Purpose: "find the testclient"},
+			AccessCheck{Verb: "list", Resource: "pods", Namespace: pybot.Namespace, Purpose: "find the testclient pod"},
+			AccessCheck{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: pybot.Namespace, Purpose: "run pybot and copy its output"},
+		)
+	}
+	return checks
+}
+
+// PatchChecks are the permissions needed to apply a patch
+var PatchChecks = []AccessCheck{
+	{Verb: "list", Group: "apps", Resource: "deployments", Purpose: "resolve the service"},
+	{Verb: "patch", Group: "apps", Resource: "deployments", Purpose: "restart the service"},
+	{Verb: "get", Group: "apps", Resource: "deployments", Purpose: "wait for the restart to roll out"},
+	{Verb: "list", Resource: "pods", Purpose: "find running replicas"},
+	{Verb: "get", Resource: "pods", Purpose: "monitor process health"},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Purpose: "copy the patch and restart the service"},
+}
+
+// WorkflowChecks returns the access checks for a named workflow
+func WorkflowChecks(workflow string) ([]AccessCheck, error) {
+	switch workflow {
+	case WorkflowSymptomCollection:
+		return SymptomCollectionChecks, nil
+	case WorkflowPatch:
+		return PatchChecks, nil
+	default:
+		return nil, fmt.Errorf("unknown workflow %q (expected %s or %s)", workflow, WorkflowSymptomCollection, WorkflowPatch)
+	}
+}
+
+// Preflight asks the API server, via SelfSubjectAccessReview, whether the current
+// credentials hold each permission. Failed reviews are recorded per check rather
+// than aborting the whole report.
+func (c *Client) Preflight(ctx context.Context, workflow, namespace string) (*PreflightReport, error) {
+	checks, err := WorkflowChecks(workflow)
+	if err != nil {
+		return nil, err
+	}
+	return c.PreflightChecks(ctx, workflow, namespace, checks)
+}
+
+// PreflightChecks is Preflight for the given checks, e.g. those of
+// SymptomCollectionChecksFor
+func (c *Client) PreflightChecks(ctx context.Context, workflow, namespace string, checks []AccessCheck) (*PreflightReport, error) {
+	report := &PreflightReport{Workflow: workflow, Namespace: namespace}
+	for _, check := range checks {
+		result := AccessResult{AccessCheck: check}
+		switch {
+		case check.ClusterScoped:
+		case check.Namespace != "":
+			result.Namespace = check.Namespace
+		default:
+			result.Namespace = namespace
+		}
+
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   result.Namespace,
+					Verb:        check.Verb,
+					Group:       check.Group,
+					Resource:    check.Resource,
+					Subresource: check.Subresource,
+				},
+			},
+		}
+
+		reqCtx, cancel := c.requestContext()
+		response, err := c.Clientset.AuthorizationV1().SelfSubjectAccessReviews().
+			Create(reqCtx, review, metav1.CreateOptions{})
+		cancel()
+		if err != nil {
+			result.Err = wrapAPIError(err, "create", "selfsubjectaccessreviews", "", "")
+		} else {
+			result.Allowed = response.Status.Allowed
+			result.Reason = response.Status.Reason
+			if response.Status.EvaluationError != "" && result.Reason == "" {
+				result.Reason = response.Status.EvaluationError
+			}
+		}
+		report.Results = append(report.Results, result)
+
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+	}
+	return report, nil
+}
+
+// Allowed reports whether every check passed
+func (r *PreflightReport) Allowed() bool {
+	return len(r.Denied()) == 0
+}
+
+// Denied returns the checks that were denied or could not be evaluated
+func (r *PreflightReport) Denied() []AccessResult {
+	var denied []AccessResult
+	for _, result := range r.Results {
+		if !result.Allowed {
+			denied = append(denied, result)
+		}
+	}
+	return denied
+}
+
+// Table renders the report as an aligned text table
+func (r *PreflightReport) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERB\tRESOURCE\tNAMESPACE\tALLOWED\tPURPOSE")
+	for _, result := range r.Results {
+		namespace := result.Namespace
+		if namespace == "" {
+			namespace = "*"
+		}
+		allowed := "yes"
+		if result.Err != nil {
+			allowed = "ERROR"
+		} else if !result.Allowed {
+			allowed = "NO"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Verb, result.AccessCheck, namespace, allowed, result.Purpose)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Err returns an error listing the denied permissions, or nil if all were allowed
+func (r *PreflightReport) Err() error {
+	denied := r.Denied()
+	if len(denied) == 0 {
+		return nil
+	}
+	missing := make([]string, 0, len(denied))
+	for _, result := range denied {
+		missing = append(missing, fmt.Sprintf("%s %s", result.Verb, result.AccessCheck))
+	}
+	return fmt.Errorf("%s preflight failed, missing permissions: %s", r.Workflow, strings.Join(missing, ", "))
+}
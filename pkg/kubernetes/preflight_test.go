@@ -0,0 +1,120 @@
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPreflight(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = attrs.Subresource != "exec"
+		return true, review, nil
+	})
+	client := &Client{Clientset: clientset, Context: context.Background()}
+
+	report, err := client.Preflight(context.Background(), WorkflowPatch, "miniudm")
+	if err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+
+	if len(report.Results) != len(PatchChecks) {
+		t.Fatalf("Preflight() returned %d results, want %d", len(report.Results), len(PatchChecks))
+	}
+	if report.Allowed() {
+		t.Error("Allowed() = true, want false with pods/exec denied")
+	}
+	denied := report.Denied()
+	if len(denied) != 1 || denied[0].AccessCheck.String() != "pods/exec" {
+		t.Errorf("Denied() = %+v, want only pods/exec", denied)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "create pods/exec") {
+		t.Errorf("Err() = %v, want missing create pods/exec", err)
+	}
+
+	table := report.Table()
+	if !strings.Contains(table, "deployments.apps") || !strings.Contains(table, "NO") {
+		t.Errorf("Table() missing expected rows:\n%s", table)
+	}
+}
+
+func TestSymptomCollectionChecksFor(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{name: "defaults", cfg: config.Config{Symptom: config.SymptomConfig{Pcap: config.PcapConfig{Enabled: true, Mode: "exec"}}}},
+		{
+			name: "ephemeral capture",
+			cfg:  config.Config{Symptom: config.SymptomConfig{Pcap: config.PcapConfig{Enabled: true, Mode: "auto"}}},
+			want: []string{"update pods/ephemeralcontainers in "},
+		},
+		{name: "capture disabled", cfg: config.Config{Symptom: config.SymptomConfig{Pcap: config.PcapConfig{Mode: "ephemeral"}}}},
+		{name: "pybot in the target namespace", cfg: config.Config{Pybot: config.PybotConfig{Namespace: "miniudm", Suites: []string{"suites/uecm"}}}},
+		{
+			name: "pybot in another namespace",
+			cfg:  config.Config{Pybot: config.PybotConfig{Namespace: "testclient", Suites: []string{"suites/uecm"}}},
+			want: []string{"list deployments.apps in testclient", "list pods in testclient", "create pods/exec in testclient"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := SymptomCollectionChecksFor(&tt.cfg, "miniudm")
+			var got []string
+			for _, check := range checks[len(SymptomCollectionChecks):] {
+				got = append(got, check.Verb+" "+check.String()+" in "+check.Namespace)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("SymptomCollectionChecksFor() added %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreflightChecksNamespace(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset()
+	var namespaces []string
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		namespaces = append(namespaces, review.Spec.ResourceAttributes.Namespace)
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+	client := &Client{Clientset: clientset, Context: context.Background()}
+
+	checks := []AccessCheck{
+		{Verb: "get", Resource: "namespaces", ClusterScoped: true},
+		{Verb: "list", Resource: "pods"},
+		{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "testclient"},
+	}
+	report, err := client.PreflightChecks(context.Background(), WorkflowSymptomCollection, "miniudm", checks)
+	if err != nil {
+		t.Fatalf("PreflightChecks() error = %v", err)
+	}
+	if want := []string{"", "miniudm", "testclient"}; strings.Join(namespaces, ",") != strings.Join(want, ",") {
+		t.Errorf("PreflightChecks() reviewed namespaces %q, want %q", namespaces, want)
+	}
+	if !strings.Contains(report.Table(), "testclient") {
+		t.Errorf("Table() missing the testclient namespace:\n%s", report.Table())
+	}
+}
+
+func TestWorkflowChecks(t *testing.T) {
+	if _, err := WorkflowChecks(WorkflowSymptomCollection); err != nil {
+		t.Errorf("WorkflowChecks(%s) error = %v", WorkflowSymptomCollection, err)
+	}
+	if _, err := WorkflowChecks("rollback"); err == nil {
+		t.Error("WorkflowChecks(\"rollback\") should return error")
+	}
+}
@@ -0,0 +1,204 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MatchMode controls how a logical pod name is matched against deployment names
+type MatchMode string
+
+const (
+	// MatchExact requires the deployment name to equal the logical name
+	MatchExact MatchMode = "exact"
+	// MatchPrefix accepts the logical name followed by a "-" separated suffix,
+	// preferring an exact match when one exists
+	MatchPrefix MatchMode = "prefix"
+	// MatchRegex treats the logical name as a regular expression
+	MatchRegex MatchMode = "regex"
+)
+
+// ParseMatchMode converts a string into a MatchMode, defaulting to prefix
+func ParseMatchMode(mode string) (MatchMode, error) {
+	switch MatchMode(strings.ToLower(mode)) {
+	case "", MatchPrefix:
+		return MatchPrefix, nil
+	case MatchExact:
+		return MatchExact, nil
+	case MatchRegex:
+		return MatchRegex, nil
+	default:
+		return "", fmt.Errorf("unknown match mode %q (expected exact, prefix or regex)", mode)
+	}
+}
+
+// ResolvedPod is a running replica of a resolved deployment
+type ResolvedPod struct {
+	Name       string
+	Namespace  string
+	Node       string
+	Containers []string
+}
+
+// ResolvedTarget links a logical pod name to its deployment and running pods
+type ResolvedTarget struct {
+	Name       string
+	Deployment *v1.Deployment
+	Selector   labels.Selector
+	Pods       []ResolvedPod
+}
+
+// AmbiguousMatchError is returned when a logical name matches several deployments
+type AmbiguousMatchError struct {
+	Name       string
+	Mode       MatchMode
+	Candidates []string
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	return fmt.Sprintf("%q matches %d deployments in %s mode: %s",
+		e.Name, len(e.Candidates), e.Mode, strings.Join(e.Candidates, ", "))
+}
+
+// GetPodsBySelector retrieves the pods in a namespace matching a label selector
+func (c *Client) GetPodsBySelector(namespace string, selector labels.Selector) ([]corev1.Pod, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	list, err := c.Clientset.CoreV1().Pods(namespace).
+		List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, wrapAPIError(err, "list", "pods", namespace, "")
+	}
+	return list.Items, nil
+}
+
+// ResolveTargets resolves each logical name to its deployment and running pods
+func (c *Client) ResolveTargets(namespace string, names []string, mode MatchMode) ([]ResolvedTarget, error) {
+	deployments, err := c.GetDeployments(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]ResolvedTarget, 0, len(names))
+	for _, name := range names {
+		deployment, err := matchDeployment(deployments, name, mode)
+		if err != nil {
+			return nil, err
+		}
+
+		target, err := c.resolveDeployment(namespace, name, deployment)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *target)
+	}
+	return targets, nil
+}
+
+// ResolveTarget resolves a single logical name to its deployment and running pods
+func (c *Client) ResolveTarget(namespace, name string, mode MatchMode) (*ResolvedTarget, error) {
+	targets, err := c.ResolveTargets(namespace, []string{name}, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &targets[0], nil
+}
+
+// resolveDeployment lists the running pods selected by a deployment
+func (c *Client) resolveDeployment(namespace, name string, deployment *v1.Deployment) (*ResolvedTarget, error) {
+	if deployment.Spec.Selector == nil {
+		return nil, fmt.Errorf("deployment %s has no selector", deployment.Name)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector on deployment %s: %w", deployment.Name, err)
+	}
+
+	pods, err := c.GetPodsBySelector(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &ResolvedTarget{
+		Name:       name,
+		Deployment: deployment,
+		Selector:   selector,
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		containers := make([]string, 0, len(pod.Spec.Containers))
+		for _, container := range pod.Spec.Containers {
+			containers = append(containers, container.Name)
+		}
+		target.Pods = append(target.Pods, ResolvedPod{
+			Name:       pod.Name,
+			Namespace:  pod.Namespace,
+			Node:       pod.Spec.NodeName,
+			Containers: containers,
+		})
+	}
+	sort.Slice(target.Pods, func(i, j int) bool { return target.Pods[i].Name < target.Pods[j].Name })
+
+	if len(target.Pods) == 0 {
+		return nil, fmt.Errorf("deployment %s has no running pods", deployment.Name)
+	}
+	return target, nil
+}
+
+// matchDeployment selects the single deployment matching name under mode
+func matchDeployment(deployments []v1.Deployment, name string, mode MatchMode) (*v1.Deployment, error) {
+	var pattern *regexp.Regexp
+	if mode == MatchRegex {
+		var err error
+		pattern, err = regexp.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod name pattern %q: %w", name, err)
+		}
+	}
+
+	var candidates []*v1.Deployment
+	for i := range deployments {
+		dep := &deployments[i]
+		switch mode {
+		case MatchExact:
+			if dep.Name == name {
+				return dep, nil
+			}
+		case MatchRegex:
+			if pattern.MatchString(dep.Name) {
+				candidates = append(candidates, dep)
+			}
+		default:
+			if dep.Name == name {
+				return dep, nil
+			}
+			if strings.HasPrefix(dep.Name, name+"-") {
+				candidates = append(candidates, dep)
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("deployment for pod %s not found", name)
+	case 1:
+		return candidates[0], nil
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, dep := range candidates {
+		names = append(names, dep.Name)
+	}
+	sort.Strings(names)
+	return nil, &AmbiguousMatchError{Name: name, Mode: mode, Candidates: names}
+}
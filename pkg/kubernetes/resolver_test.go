@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMatchDeployment(t *testing.T) {
+	deployments := []v1.Deployment{
+		{ObjectMeta: metav1.ObjectMeta{Name: "uecm"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "uecm-proxy"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nim-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nim-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "testclient-main"}},
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		mode      MatchMode
+		expected  string
+		ambiguous bool
+		wantErr   bool
+	}{
+		{name: "exact", input: "uecm", mode: MatchExact, expected: "uecm"},
+		{name: "exact miss", input: "testclient", mode: MatchExact, wantErr: true},
+		{name: "prefix prefers exact", input: "uecm", mode: MatchPrefix, expected: "uecm"},
+		{name: "prefix unique", input: "testclient", mode: MatchPrefix, expected: "testclient-main"},
+		{name: "prefix ambiguous", input: "nim", mode: MatchPrefix, ambiguous: true, wantErr: true},
+		{name: "prefix needs separator", input: "uec", mode: MatchPrefix, wantErr: true},
+		{name: "regex", input: "^uecm-p", mode: MatchRegex, expected: "uecm-proxy"},
+		{name: "regex ambiguous", input: "^uecm", mode: MatchRegex, ambiguous: true, wantErr: true},
+		{name: "regex invalid", input: "(", mode: MatchRegex, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dep, err := matchDeployment(deployments, tt.input, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchDeployment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var ambiguousErr *AmbiguousMatchError
+			if errors.As(err, &ambiguousErr) != tt.ambiguous {
+				t.Errorf("matchDeployment() ambiguous = %v, want %v", !tt.ambiguous, tt.ambiguous)
+			}
+			if err == nil && dep.Name != tt.expected {
+				t.Errorf("matchDeployment() = %s, want %s", dep.Name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMatchMode(t *testing.T) {
+	if mode, err := ParseMatchMode(""); err != nil || mode != MatchPrefix {
+		t.Errorf("ParseMatchMode(\"\") = %v, %v; want prefix", mode, err)
+	}
+	if mode, err := ParseMatchMode("REGEX"); err != nil || mode != MatchRegex {
+		t.Errorf("ParseMatchMode(\"REGEX\") = %v, %v; want regex", mode, err)
+	}
+	if _, err := ParseMatchMode("contains"); err == nil {
+		t.Error("ParseMatchMode(\"contains\") should return error")
+	}
+}
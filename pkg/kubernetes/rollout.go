@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Rollout reasons reported by EvaluateRollout
+const (
+	RolloutComplete                 = "Complete"
+	RolloutGenerationNotObserved    = "GenerationNotObserved"
+	RolloutProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	RolloutUpdatingReplicas         = "UpdatingReplicas"
+	RolloutOldReplicasPending       = "OldReplicasPending"
+	RolloutUnavailableReplicas      = "UnavailableReplicas"
+)
+
+// RolloutStatus describes how far a deployment rollout has progressed
+type RolloutStatus struct {
+	Done    bool
+	Reason  string
+	Message string
+
+	Desired   int32
+	Updated   int32
+	Ready     int32
+	Available int32
+}
+
+// EvaluateRollout reports whether a deployment has finished rolling out, using
+// the same rules as kubectl rollout status
+func EvaluateRollout(deployment *v1.Deployment) RolloutStatus {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	status := RolloutStatus{
+		Desired:   desired,
+		Updated:   deployment.Status.UpdatedReplicas,
+		Ready:     deployment.Status.ReadyReplicas,
+		Available: deployment.Status.AvailableReplicas,
+	}
+
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status.Reason = RolloutGenerationNotObserved
+		status.Message = fmt.Sprintf("waiting for deployment %s spec update to be observed", deployment.Name)
+		return status
+	}
+
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == v1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			status.Reason = RolloutProgressDeadlineExceeded
+			status.Message = fmt.Sprintf("deployment %s exceeded its progress deadline", deployment.Name)
+			return status
+		}
+	}
+
+	switch {
+	case status.Updated < desired:
+		status.Reason = RolloutUpdatingReplicas
+		status.Message = fmt.Sprintf("%d out of %d new replicas have been updated", status.Updated, desired)
+	case deployment.Status.Replicas > status.Updated:
+		status.Reason = RolloutOldReplicasPending
+		status.Message = fmt.Sprintf("%d old replicas are pending termination", deployment.Status.Replicas-status.Updated)
+	case status.Available < desired:
+		status.Reason = RolloutUnavailableReplicas
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available", status.Available, desired)
+	default:
+		status.Done = true
+		status.Reason = RolloutComplete
+		status.Message = fmt.Sprintf("deployment %s successfully rolled out", deployment.Name)
+	}
+	return status
+}
+
+// GetRolloutStatus fetches a deployment and evaluates its rollout
+func (c *Client) GetRolloutStatus(namespace, name string) (*RolloutStatus, error) {
+	deployment, err := c.GetDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	status := EvaluateRollout(deployment)
+	return &status, nil
+}
+
+// RestartedAtAnnotation is the pod template annotation kubectl rollout restart sets
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// RestartDeployment replaces the pods of a deployment the way kubectl rollout
+// restart does, by stamping the pod template with the current time
+func (c *Client) RestartDeployment(namespace, name string) error {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		RestartedAtAnnotation, time.Now().Format(time.RFC3339))
+	_, err := c.Clientset.AppsV1().Deployments(namespace).
+		Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return wrapAPIError(err, "patch", "deployment", namespace, name)
+	}
+	return nil
+}
+
+// ContainerHealth is the readiness and restart state of one container
+type ContainerHealth struct {
+	Name         string
+	Ready        bool
+	RestartCount int32
+	// State is "running", "waiting" or "terminated"
+	State string
+	// Reason explains a waiting or terminated state, e.g. CrashLoopBackOff
+	Reason string
+	// LastTerminationReason is why the previous instance exited, e.g. OOMKilled
+	LastTerminationReason string
+}
+
+// PodHealth is the readiness state of a pod and its containers
+type PodHealth struct {
+	Name       string
+	Phase      corev1.PodPhase
+	Ready      bool
+	Restarts   int32
+	Containers []ContainerHealth
+}
+
+// EvaluatePod summarises the readiness and restart counts of a pod
+func EvaluatePod(pod *corev1.Pod) PodHealth {
+	health := PodHealth{
+		Name:  pod.Name,
+		Phase: pod.Status.Phase,
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			health.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		container := ContainerHealth{
+			Name:         cs.Name,
+			Ready:        cs.Ready,
+			RestartCount: cs.RestartCount,
+		}
+		switch {
+		case cs.State.Running != nil:
+			container.State = "running"
+		case cs.State.Waiting != nil:
+			container.State = "waiting"
+			container.Reason = cs.State.Waiting.Reason
+		case cs.State.Terminated != nil:
+			container.State = "terminated"
+			container.Reason = cs.State.Terminated.Reason
+		}
+		if cs.LastTerminationState.Terminated != nil {
+			container.LastTerminationReason = cs.LastTerminationState.Terminated.Reason
+		}
+		health.Restarts += cs.RestartCount
+		health.Containers = append(health.Containers, container)
+	}
+	return health
+}
+
+// GetPodHealth fetches a pod and evaluates its readiness
+func (c *Client) GetPodHealth(namespace, name string) (*PodHealth, error) {
+	pod, err := c.GetPod(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	health := EvaluatePod(pod)
+	return &health, nil
+}
@@ -0,0 +1,144 @@
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestEvaluateRollout(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployment v1.Deployment
+		wantDone   bool
+		wantReason string
+	}{
+		{
+			name: "complete",
+			deployment: v1.Deployment{
+				Spec:   v1.DeploymentSpec{Replicas: int32Ptr(2)},
+				Status: v1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2},
+			},
+			wantDone:   true,
+			wantReason: RolloutComplete,
+		},
+		{
+			name: "nil replicas defaults to one",
+			deployment: v1.Deployment{
+				Status: v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1},
+			},
+			wantDone:   true,
+			wantReason: RolloutComplete,
+		},
+		{
+			name: "scaled to zero",
+			deployment: v1.Deployment{
+				Spec: v1.DeploymentSpec{Replicas: int32Ptr(0)},
+			},
+			wantDone:   true,
+			wantReason: RolloutComplete,
+		},
+		{
+			name: "generation not observed",
+			deployment: v1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 3},
+				Spec:       v1.DeploymentSpec{Replicas: int32Ptr(1)},
+				Status:     v1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			wantReason: RolloutGenerationNotObserved,
+		},
+		{
+			name: "progress deadline exceeded",
+			deployment: v1.Deployment{
+				Spec: v1.DeploymentSpec{Replicas: int32Ptr(1)},
+				Status: v1.DeploymentStatus{
+					Conditions: []v1.DeploymentCondition{{
+						Type:   v1.DeploymentProgressing,
+						Status: corev1.ConditionFalse,
+						Reason: "ProgressDeadlineExceeded",
+					}},
+				},
+			},
+			wantReason: RolloutProgressDeadlineExceeded,
+		},
+		{
+			name: "updating replicas",
+			deployment: v1.Deployment{
+				Spec:   v1.DeploymentSpec{Replicas: int32Ptr(3)},
+				Status: v1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3},
+			},
+			wantReason: RolloutUpdatingReplicas,
+		},
+		{
+			name: "old replicas pending termination",
+			deployment: v1.Deployment{
+				Spec:   v1.DeploymentSpec{Replicas: int32Ptr(2)},
+				Status: v1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+			},
+			wantReason: RolloutOldReplicasPending,
+		},
+		{
+			name: "updated replicas unavailable",
+			deployment: v1.Deployment{
+				Spec:   v1.DeploymentSpec{Replicas: int32Ptr(2)},
+				Status: v1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 1},
+			},
+			wantReason: RolloutUnavailableReplicas,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateRollout(&tt.deployment)
+			if got.Done != tt.wantDone || got.Reason != tt.wantReason {
+				t.Errorf("EvaluateRollout() = %v/%s (%s), want %v/%s", got.Done, got.Reason, got.Message, tt.wantDone, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestEvaluatePod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "uecm-a"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "mcc",
+					Ready: true,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+				{
+					Name:         "sidecar",
+					RestartCount: 4,
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+					},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"},
+					},
+				},
+			},
+		},
+	}
+
+	got := EvaluatePod(pod)
+	if got.Ready {
+		t.Errorf("EvaluatePod() Ready = true, want false")
+	}
+	if got.Restarts != 4 {
+		t.Errorf("EvaluatePod() Restarts = %d, want 4", got.Restarts)
+	}
+	if len(got.Containers) != 2 {
+		t.Fatalf("EvaluatePod() returned %d containers, want 2", len(got.Containers))
+	}
+	sidecar := got.Containers[1]
+	if sidecar.Ready || sidecar.State != "waiting" || sidecar.Reason != "CrashLoopBackOff" || sidecar.LastTerminationReason != "OOMKilled" {
+		t.Errorf("EvaluatePod() sidecar = %+v", sidecar)
+	}
+}
@@ -0,0 +1,114 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+)
+
+// healthPollInterval is how often MonitorHealth checks the rollout and pods
+const healthPollInterval = 2 * time.Second
+
+// ClusterRestarter restarts a service by rolling out its deployment again, like
+// kubectl rollout restart, and reports it healthy once the rollout completed and
+// every pod is ready
+type ClusterRestarter struct {
+	client    kubernetes.ClusterClient
+	namespace string
+	mode      kubernetes.MatchMode
+	logger    *zap.Logger
+	// interval overrides healthPollInterval in tests
+	interval time.Duration
+}
+
+// NewClusterRestarter creates a restarter for the services of a namespace.
+// Service names are matched to deployments under mode.
+func NewClusterRestarter(client kubernetes.ClusterClient, namespace string, mode kubernetes.MatchMode, logger *zap.Logger) *ClusterRestarter {
+	return &ClusterRestarter{
+		client:    client,
+		namespace: namespace,
+		mode:      mode,
+		logger:    logger,
+		interval:  healthPollInterval,
+	}
+}
+
+// RestartService replaces the pods of the service's deployment so that they
+// load the patched library
+func (r *ClusterRestarter) RestartService(ctx context.Context, serviceName string) error {
+	targets, err := r.client.ResolveTargets(r.namespace, []string{serviceName}, r.mode)
+	if err != nil {
+		return fmt.Errorf("failed to find service %s: %w", serviceName, err)
+	}
+
+	target := targets[0]
+	deployment := target.Deployment.Name
+	r.logger.Info("Restarting deployment",
+		zap.String("service", serviceName),
+		zap.String("deployment", deployment),
+		zap.Int("pods", len(target.Pods)),
+	)
+	return r.client.RestartDeployment(r.namespace, deployment)
+}
+
+// MonitorHealth waits until the service's deployment has rolled out and all of
+// its running pods are ready, or fails once timeout or ctx ends
+func (r *ClusterRestarter) MonitorHealth(ctx context.Context, serviceName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		pending, err := r.checkHealth(serviceName)
+		if err != nil {
+			return err
+		}
+		if pending == "" {
+			r.logger.Info("Service is healthy", zap.String("service", serviceName))
+			return nil
+		}
+		r.logger.Debug("Waiting for service", zap.String("service", serviceName), zap.String("status", pending))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s not healthy after %v: %s", serviceName, timeout, pending)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth returns why the service is not healthy yet, or "" once it is
+func (r *ClusterRestarter) checkHealth(serviceName string) (string, error) {
+	targets, err := r.client.ResolveTargets(r.namespace, []string{serviceName}, r.mode)
+	if err != nil {
+		// The old pods may be gone before the new ones run
+		return err.Error(), nil
+	}
+	target := targets[0]
+
+	rollout, err := r.client.GetRolloutStatus(r.namespace, target.Deployment.Name)
+	if err != nil {
+		return "", err
+	}
+	if rollout.Reason == kubernetes.RolloutProgressDeadlineExceeded {
+		return "", fmt.Errorf("rollout of service %s failed: %s", serviceName, rollout.Message)
+	}
+	if !rollout.Done {
+		return rollout.Message, nil
+	}
+
+	for _, pod := range target.Pods {
+		health, err := r.client.GetPodHealth(r.namespace, pod.Name)
+		if err != nil {
+			return "", err
+		}
+		if !health.Ready {
+			return fmt.Sprintf("pod %s is not ready", pod.Name), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,100 @@
+package patch
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newDeployment(name string, replicas, available int32) *v1.Deployment {
+	return &v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "miniudm"},
+		Spec: v1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+		},
+		Status: v1.DeploymentStatus{
+			Replicas:          replicas,
+			UpdatedReplicas:   replicas,
+			ReadyReplicas:     available,
+			AvailableReplicas: available,
+		},
+	}
+}
+
+func newPod(name, app string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "miniudm", Labels: map[string]string{"app": app}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "mcc"}}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestRestartService(t *testing.T) {
+	client, _ := fake.NewClient(newDeployment("uecm", 1, 1), newPod("uecm-a", "uecm", true))
+	restarter := NewClusterRestarter(client, "miniudm", kubernetes.MatchPrefix, zap.NewNop())
+
+	if err := restarter.RestartService(context.Background(), "uecm"); err != nil {
+		t.Fatalf("RestartService() error = %v", err)
+	}
+	deployment, err := client.GetDeployment("miniudm", "uecm")
+	if err != nil {
+		t.Fatalf("GetDeployment() error = %v", err)
+	}
+	if _, ok := deployment.Spec.Template.Annotations[kubernetes.RestartedAtAnnotation]; !ok {
+		t.Errorf("RestartService() pod template annotations = %v, want %s", deployment.Spec.Template.Annotations, kubernetes.RestartedAtAnnotation)
+	}
+
+	if err := restarter.RestartService(context.Background(), "nim"); err == nil {
+		t.Error("RestartService() of an unknown service succeeded")
+	}
+}
+
+func TestMonitorHealth(t *testing.T) {
+	stalled := newDeployment("uecm", 1, 0)
+	stalled.Status.Conditions = []v1.DeploymentCondition{{Type: v1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"}}
+
+	tests := []struct {
+		name       string
+		deployment *v1.Deployment
+		pod        *corev1.Pod
+		wantErr    string
+	}{
+		{name: "rolled out and ready", deployment: newDeployment("uecm", 1, 1), pod: newPod("uecm-a", "uecm", true)},
+		{name: "replicas unavailable", deployment: newDeployment("uecm", 1, 0), pod: newPod("uecm-a", "uecm", false), wantErr: "0 of 1 updated replicas are available"},
+		{name: "pod not ready", deployment: newDeployment("uecm", 1, 1), pod: newPod("uecm-a", "uecm", false), wantErr: "pod uecm-a is not ready"},
+		{name: "no running pods", deployment: newDeployment("uecm", 1, 1), pod: newPod("nim-a", "nim", true), wantErr: "has no running pods"},
+		{name: "progress deadline exceeded", deployment: stalled, pod: newPod("uecm-a", "uecm", false), wantErr: "rollout of service uecm failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := fake.NewClient(tt.deployment, tt.pod)
+			restarter := NewClusterRestarter(client, "miniudm", kubernetes.MatchPrefix, zap.NewNop())
+			restarter.interval = 10 * time.Millisecond
+
+			err := restarter.MonitorHealth(context.Background(), "uecm", 100*time.Millisecond)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("MonitorHealth() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("MonitorHealth() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
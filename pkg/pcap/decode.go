@@ -0,0 +1,185 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// IP protocol numbers of the decoded transports
+const (
+	protoTCP  = 6
+	protoUDP  = 17
+	protoSCTP = 132
+)
+
+// TCP flags
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+)
+
+// segment is the transport segment of a decoded packet
+type segment struct {
+	proto    uint8
+	src, dst netip.AddrPort
+	// seq and flags are set for TCP
+	seq   uint32
+	flags uint8
+	// payload follows the transport header; for SCTP it holds the chunks
+	payload []byte
+}
+
+// decode extracts the TCP, UDP or SCTP segment of a packet. It fails for
+// other protocols, IP fragments and truncated headers.
+func decode(packet Packet) (segment, bool) {
+	data := packet.Data
+	var etherType uint16
+	switch packet.LinkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return segment{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		// 802.1Q and 802.1ad tags
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return segment{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return segment{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[0:2]), data[20:]
+	case LinkTypeNull:
+		if len(data) < 4 {
+			return segment{}, false
+		}
+		// The address family is in the capturing host's byte order
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		data = data[4:]
+		switch family {
+		case 2:
+			etherType = 0x0800
+		case 10, 24, 28, 30:
+			etherType = 0x86dd
+		}
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		if len(data) == 0 {
+			return segment{}, false
+		}
+		switch data[0] >> 4 {
+		case 4:
+			etherType = 0x0800
+		case 6:
+			etherType = 0x86dd
+		}
+	}
+
+	switch etherType {
+	case 0x0800:
+		return decodeIPv4(data)
+	case 0x86dd:
+		return decodeIPv6(data)
+	}
+	return segment{}, false
+}
+
+func decodeIPv4(data []byte) (segment, bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return segment{}, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLen < 20 || total < headerLen || len(data) < headerLen {
+		return segment{}, false
+	}
+	// Fragments are not reassembled
+	if binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+		return segment{}, false
+	}
+	src, _ := netip.AddrFromSlice(data[12:16])
+	dst, _ := netip.AddrFromSlice(data[16:20])
+	// Ethernet pads short frames beyond the IP total length
+	if total < len(data) {
+		data = data[:total]
+	}
+	return decodeTransport(data[9], src, dst, data[headerLen:])
+}
+
+func decodeIPv6(data []byte) (segment, bool) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return segment{}, false
+	}
+	src, _ := netip.AddrFromSlice(data[8:24])
+	dst, _ := netip.AddrFromSlice(data[24:40])
+	next := data[6]
+	if total := 40 + int(binary.BigEndian.Uint16(data[4:6])); total < len(data) {
+		data = data[:total]
+	}
+	data = data[40:]
+
+	for {
+		switch next {
+		case 0, 43, 60:
+			// Hop-by-hop, routing and destination options
+			if len(data) < 8 || len(data) < (int(data[1])+1)*8 {
+				return segment{}, false
+			}
+			next, data = data[0], data[(int(data[1])+1)*8:]
+		case 44:
+			// A fragment header is only accepted on unfragmented packets
+			if len(data) < 8 || binary.BigEndian.Uint16(data[2:4])&0xfff9 != 0 {
+				return segment{}, false
+			}
+			next, data = data[0], data[8:]
+		default:
+			return decodeTransport(next, src, dst, data)
+		}
+	}
+}
+
+func decodeTransport(proto uint8, src, dst netip.Addr, data []byte) (segment, bool) {
+	if len(data) < 4 {
+		return segment{}, false
+	}
+	seg := segment{
+		proto: proto,
+		src:   netip.AddrPortFrom(src.Unmap(), binary.BigEndian.Uint16(data[0:2])),
+		dst:   netip.AddrPortFrom(dst.Unmap(), binary.BigEndian.Uint16(data[2:4])),
+	}
+	switch proto {
+	case protoTCP:
+		if len(data) < 20 {
+			return segment{}, false
+		}
+		offset := int(data[12]>>4) * 4
+		if offset < 20 || offset > len(data) {
+			return segment{}, false
+		}
+		seg.seq = binary.BigEndian.Uint32(data[4:8])
+		seg.flags = data[13]
+		seg.payload = data[offset:]
+	case protoUDP:
+		if len(data) < 8 {
+			return segment{}, false
+		}
+		seg.payload = data[8:]
+	case protoSCTP:
+		if len(data) < 12 {
+			return segment{}, false
+		}
+		seg.payload = data[12:]
+	default:
+		return segment{}, false
+	}
+	return seg, true
+}
@@ -0,0 +1,280 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// diameterPort is the registered Diameter port over TCP and SCTP
+const diameterPort = 3868
+
+// diameterPPID is the SCTP payload protocol identifier of Diameter
+const diameterPPID = 46
+
+// diameterHeaderLen is the length of a Diameter message header
+const diameterHeaderLen = 20
+
+// Diameter AVP codes read from messages
+const (
+	avpSessionID              = 263
+	avpOriginHost             = 264
+	avpResultCode             = 268
+	avpExperimentalResult     = 297
+	avpExperimentalResultCode = 298
+)
+
+// diameterCommands names the Diameter commands of the base protocol and of the
+// 3GPP Cx, Sh and S6a interfaces as request and answer
+var diameterCommands = map[uint32][2]string{
+	257: {"CER", "CEA"},
+	258: {"RAR", "RAA"},
+	271: {"ACR", "ACA"},
+	272: {"CCR", "CCA"},
+	274: {"ASR", "ASA"},
+	275: {"STR", "STA"},
+	280: {"DWR", "DWA"},
+	282: {"DPR", "DPA"},
+	300: {"UAR", "UAA"},
+	301: {"SAR", "SAA"},
+	302: {"LIR", "LIA"},
+	303: {"MAR", "MAA"},
+	304: {"RTR", "RTA"},
+	305: {"PPR", "PPA"},
+	306: {"UDR", "UDA"},
+	307: {"PUR", "PUA"},
+	308: {"SNR", "SNA"},
+	309: {"PNR", "PNA"},
+	316: {"ULR", "ULA"},
+	317: {"CLR", "CLA"},
+	318: {"AIR", "AIA"},
+	319: {"IDR", "IDA"},
+	320: {"DSR", "DSA"},
+	321: {"PUR", "PUA"},
+	322: {"RSR", "RSA"},
+	323: {"NOR", "NOA"},
+}
+
+// diameterResults names the Result-Code values of the base protocol (RFC 6733)
+var diameterResults = map[uint32]string{
+	1001: "MULTI_ROUND_AUTH",
+	2001: "SUCCESS",
+	2002: "LIMITED_SUCCESS",
+	3001: "COMMAND_UNSUPPORTED",
+	3002: "UNABLE_TO_DELIVER",
+	3003: "REALM_NOT_SERVED",
+	3004: "TOO_BUSY",
+	3005: "LOOP_DETECTED",
+	3006: "REDIRECT_INDICATION",
+	3007: "APPLICATION_UNSUPPORTED",
+	3008: "INVALID_HDR_BITS",
+	3009: "INVALID_AVP_BITS",
+	3010: "UNKNOWN_PEER",
+	4001: "AUTHENTICATION_REJECTED",
+	4002: "OUT_OF_SPACE",
+	4003: "ELECTION_LOST",
+	5001: "AVP_UNSUPPORTED",
+	5002: "UNKNOWN_SESSION_ID",
+	5003: "AUTHORIZATION_REJECTED",
+	5004: "INVALID_AVP_VALUE",
+	5005: "MISSING_AVP",
+	5006: "RESOURCES_EXCEEDED",
+	5007: "CONTRADICTING_AVPS",
+	5008: "AVP_NOT_ALLOWED",
+	5009: "AVP_OCCURS_TOO_MANY_TIMES",
+	5010: "NO_COMMON_APPLICATION",
+	5011: "UNSUPPORTED_VERSION",
+	5012: "UNABLE_TO_COMPLY",
+	5013: "INVALID_BIT_IN_HEADER",
+	5014: "INVALID_AVP_LENGTH",
+	5015: "INVALID_MESSAGE_LENGTH",
+	5016: "INVALID_AVP_BIT_COMBO",
+	5017: "NO_COMMON_SECURITY",
+}
+
+// diameterExperimentalResults names the 3GPP Experimental-Result-Code values
+// of Cx/Sh (TS 29.229) and S6a (TS 29.272)
+var diameterExperimentalResults = map[uint32]string{
+	2001: "FIRST_REGISTRATION",
+	2002: "SUBSEQUENT_REGISTRATION",
+	2003: "UNREGISTERED_SERVICE",
+	2004: "SUCCESS_SERVER_NAME_NOT_STORED",
+	4100: "USER_DATA_NOT_AVAILABLE",
+	4101: "PRIOR_UPDATE_IN_PROGRESS",
+	4181: "AUTHENTICATION_DATA_UNAVAILABLE",
+	5001: "ERROR_USER_UNKNOWN",
+	5002: "ERROR_IDENTITIES_DONT_MATCH",
+	5003: "ERROR_IDENTITY_NOT_REGISTERED",
+	5004: "ERROR_ROAMING_NOT_ALLOWED",
+	5005: "ERROR_IDENTITY_ALREADY_REGISTERED",
+	5006: "ERROR_AUTH_SCHEME_NOT_SUPPORTED",
+	5007: "ERROR_IN_ASSIGNMENT_TYPE",
+	5008: "ERROR_TOO_MUCH_DATA",
+	5009: "ERROR_NOT_SUPPORTED_USER_DATA",
+	5011: "ERROR_FEATURE_UNSUPPORTED",
+	5100: "ERROR_USER_DATA_NOT_RECOGNIZED",
+	5101: "ERROR_OPERATION_NOT_ALLOWED",
+	5420: "ERROR_UNKNOWN_EPS_SUBSCRIPTION",
+	5421: "ERROR_RAT_NOT_ALLOWED",
+	5422: "ERROR_EQUIPMENT_UNKNOWN",
+	5423: "ERROR_UNKNOWN_SERVING_NODE",
+}
+
+// DiameterMessage is a Diameter request or answer
+type DiameterMessage struct {
+	Time          time.Time      `json:"time"`
+	Transport     string         `json:"transport"`
+	Src           netip.AddrPort `json:"src"`
+	Dst           netip.AddrPort `json:"dst"`
+	Command       uint32         `json:"command"`
+	Request       bool           `json:"request"`
+	ApplicationID uint32         `json:"application_id"`
+	HopByHop      uint32         `json:"hop_by_hop"`
+	EndToEnd      uint32         `json:"end_to_end"`
+	SessionID     string         `json:"session_id,omitempty"`
+	OriginHost    string         `json:"origin_host,omitempty"`
+	// ResultCode and ExperimentalResultCode are set on answers
+	ResultCode             uint32 `json:"result_code,omitempty"`
+	ExperimentalResultCode uint32 `json:"experimental_result_code,omitempty"`
+	// Latency is the time since the matching request, set on answers whose
+	// request was captured
+	Latency time.Duration `json:"latency,omitempty"`
+}
+
+// Name returns the command abbreviation, e.g. ULR or ULA
+func (m DiameterMessage) Name() string {
+	names, ok := diameterCommands[m.Command]
+	switch {
+	case ok && m.Request:
+		return names[0]
+	case ok:
+		return names[1]
+	case m.Request:
+		return fmt.Sprintf("%d-R", m.Command)
+	default:
+		return fmt.Sprintf("%d-A", m.Command)
+	}
+}
+
+// RequestName returns the abbreviation of the request a message belongs to
+func (m DiameterMessage) RequestName() string {
+	m.Request = true
+	return m.Name()
+}
+
+// Failed reports an answer with a protocol, transient or permanent failure
+func (m DiameterMessage) Failed() bool {
+	return m.ResultCode >= 3000 || m.ExperimentalResultCode >= 3000
+}
+
+// Result names the result of an answer, e.g. DIAMETER_ERROR_USER_UNKNOWN (5001)
+func (m DiameterMessage) Result() string {
+	code, name := m.ResultCode, diameterResults[m.ResultCode]
+	if m.ExperimentalResultCode != 0 {
+		code, name = m.ExperimentalResultCode, diameterExperimentalResults[m.ExperimentalResultCode]
+	}
+	if code == 0 {
+		return ""
+	}
+	if name == "" {
+		return fmt.Sprintf("%d", code)
+	}
+	return fmt.Sprintf("DIAMETER_%s (%d)", name, code)
+}
+
+// parseDiameter decodes a complete Diameter message
+func parseDiameter(data []byte) (DiameterMessage, error) {
+	if len(data) < diameterHeaderLen || data[0] != 1 {
+		return DiameterMessage{}, fmt.Errorf("not a Diameter message")
+	}
+	length := int(binary.BigEndian.Uint32(data[0:4]) & 0xffffff)
+	if length < diameterHeaderLen || length > len(data) {
+		return DiameterMessage{}, fmt.Errorf("invalid Diameter message length %d", length)
+	}
+
+	msg := DiameterMessage{
+		Request:       data[4]&0x80 != 0,
+		Command:       binary.BigEndian.Uint32(data[4:8]) & 0xffffff,
+		ApplicationID: binary.BigEndian.Uint32(data[8:12]),
+		HopByHop:      binary.BigEndian.Uint32(data[12:16]),
+		EndToEnd:      binary.BigEndian.Uint32(data[16:20]),
+	}
+	walkAVPs(data[diameterHeaderLen:length], func(code uint32, value []byte) {
+		switch code {
+		case avpSessionID:
+			msg.SessionID = string(value)
+		case avpOriginHost:
+			msg.OriginHost = string(value)
+		case avpResultCode:
+			if len(value) == 4 {
+				msg.ResultCode = binary.BigEndian.Uint32(value)
+			}
+		case avpExperimentalResult:
+			walkAVPs(value, func(code uint32, value []byte) {
+				if code == avpExperimentalResultCode && len(value) == 4 {
+					msg.ExperimentalResultCode = binary.BigEndian.Uint32(value)
+				}
+			})
+		}
+	})
+	return msg, nil
+}
+
+// walkAVPs calls fn with the code and value of each AVP, stopping at a malformed one
+func walkAVPs(data []byte, fn func(code uint32, value []byte)) {
+	for len(data) >= 8 {
+		code := binary.BigEndian.Uint32(data[0:4])
+		flags := data[4]
+		length := int(binary.BigEndian.Uint32(data[4:8]) & 0xffffff)
+		header := 8
+		if flags&0x80 != 0 {
+			// Vendor-Id follows the header
+			header = 12
+		}
+		if length < header || length > len(data) {
+			return
+		}
+		fn(code, data[header:length])
+		data = data[min((length+3)&^3, len(data)):]
+	}
+}
+
+// looksLikeDiameter reports whether data starts with a plausible Diameter header
+func looksLikeDiameter(data []byte) bool {
+	if len(data) < diameterHeaderLen || data[0] != 1 {
+		return false
+	}
+	length := binary.BigEndian.Uint32(data[0:4]) & 0xffffff
+	// The reserved flag bits are zero
+	return length >= diameterHeaderLen && length%4 == 0 && length <= maxBlockSize && data[4]&0x0f == 0
+}
+
+// diameterConn decodes the Diameter messages of a TCP connection
+type diameterConn struct {
+	s      *Summarizer
+	conn   *conn
+	bufs   [2][]byte
+	broken [2]bool
+}
+
+func (d *diameterConn) feed(dir int, ts time.Time, data []byte) {
+	if d.broken[dir] {
+		return
+	}
+	buf := append(d.bufs[dir], data...)
+	for len(buf) >= 4 {
+		length := int(binary.BigEndian.Uint32(buf[0:4]) & 0xffffff)
+		if buf[0] != 1 || length < diameterHeaderLen || length > maxBlockSize {
+			d.broken[dir], d.bufs[dir] = true, nil
+			return
+		}
+		if len(buf) < length {
+			break
+		}
+		src, dst := d.conn.endpoints(dir)
+		d.s.addDiameter(buf[:length], ts, TransportTCP, src, dst)
+		buf = buf[length:]
+	}
+	d.bufs[dir] = append(d.bufs[dir][:0], buf...)
+}
@@ -0,0 +1,152 @@
+package pcap
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Transport protocol names used in flows
+const (
+	TransportTCP  = "tcp"
+	TransportUDP  = "udp"
+	TransportSCTP = "sctp"
+)
+
+// Application protocol names used in flows
+const (
+	ApplicationHTTP2    = "http2"
+	ApplicationDiameter = "diameter"
+)
+
+// maxPendingSegments bounds the out-of-order TCP segments held per direction
+const maxPendingSegments = 256
+
+// Flow is the traffic between two endpoints over one transport protocol
+type Flow struct {
+	Transport string `json:"transport"`
+	// Client opened the flow with a TCP SYN or SCTP INIT; without one seen, it
+	// is the endpoint with the higher port
+	Client netip.AddrPort `json:"client"`
+	Server netip.AddrPort `json:"server"`
+	// Application is the protocol decoded from the payload, if any
+	Application string    `json:"application,omitempty"`
+	Packets     int       `json:"packets"`
+	Bytes       int64     `json:"bytes"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	// Resets counts TCP resets
+	Resets int `json:"resets,omitempty"`
+}
+
+// flowKey identifies a flow regardless of direction
+type flowKey struct {
+	proto uint8
+	a, b  netip.AddrPort
+}
+
+// newFlowKey returns the key of the segment's flow
+func newFlowKey(seg segment) flowKey {
+	a, b := seg.src, seg.dst
+	if c := a.Addr().Compare(b.Addr()); c > 0 || c == 0 && a.Port() > b.Port() {
+		a, b = b, a
+	}
+	return flowKey{proto: seg.proto, a: a, b: b}
+}
+
+// conn is the decoding state of one flow. Direction 0 is from the client,
+// direction 1 from the server.
+type conn struct {
+	flow    *Flow
+	streams [2]tcpStream
+	// detected is set once the TCP payload was inspected for an application
+	detected bool
+	parser   parser
+	sctp     *sctpAssociation
+}
+
+// direction returns the direction of a segment of the connection
+func (c *conn) direction(seg segment) int {
+	if seg.src == c.flow.Client {
+		return 0
+	}
+	return 1
+}
+
+// endpoints returns the sender and receiver of direction dir
+func (c *conn) endpoints(dir int) (netip.AddrPort, netip.AddrPort) {
+	if dir == 0 {
+		return c.flow.Client, c.flow.Server
+	}
+	return c.flow.Server, c.flow.Client
+}
+
+// parser decodes the application payload of a TCP connection
+type parser interface {
+	// feed consumes the next contiguous payload of direction dir
+	feed(dir int, ts time.Time, data []byte)
+}
+
+// tcpStream reassembles the payload of one direction of a TCP connection
+type tcpStream struct {
+	started bool
+	next    uint32
+	// pending holds the segments received ahead of next
+	pending map[uint32][]byte
+	// lost is set once a gap was not filled; later payload is ignored
+	lost bool
+}
+
+// syn starts the stream after the initial sequence number
+func (s *tcpStream) syn(seq uint32) {
+	if !s.started {
+		s.started, s.next = true, seq+1
+	}
+}
+
+// add returns the payload that became contiguous with the segment at seq
+func (s *tcpStream) add(seq uint32, payload []byte) []byte {
+	if s.lost || len(payload) == 0 {
+		return nil
+	}
+	if !s.started {
+		s.started, s.next = true, seq
+	}
+	if int32(seq-s.next) > 0 {
+		if len(s.pending) >= maxPendingSegments {
+			s.lost, s.pending = true, nil
+			return nil
+		}
+		if s.pending == nil {
+			s.pending = make(map[uint32][]byte)
+		}
+		s.pending[seq] = payload
+		return nil
+	}
+
+	out := s.advance(nil, seq, payload)
+	for drained := true; drained && len(s.pending) > 0; {
+		drained = false
+		for seq, payload := range s.pending {
+			if int32(seq-s.next) <= 0 {
+				delete(s.pending, seq)
+				out = s.advance(out, seq, payload)
+				drained = true
+			}
+		}
+	}
+	return out
+}
+
+// advance appends the part of payload past next, which it moves
+func (s *tcpStream) advance(out []byte, seq uint32, payload []byte) []byte {
+	skip := int(s.next - seq)
+	if skip >= len(payload) {
+		return out
+	}
+	payload = payload[skip:]
+	s.next += uint32(len(payload))
+	if out == nil {
+		return payload[:len(payload):len(payload)]
+	}
+	return append(out, payload...)
+}
@@ -0,0 +1,318 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// http2Preface starts every HTTP/2 connection from the client
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// HTTP/2 frame types and flags
+const (
+	http2Data         = 0x0
+	http2Headers      = 0x1
+	http2RSTStream    = 0x3
+	http2Settings     = 0x4
+	http2PushPromise  = 0x5
+	http2Continuation = 0x9
+
+	http2EndHeaders = 0x4
+	http2Padded     = 0x8
+	http2Priority   = 0x20
+	http2Ack        = 0x1
+)
+
+// http2FrameHeaderLen is the length of an HTTP/2 frame header
+const http2FrameHeaderLen = 9
+
+// http2TableSize is the default HPACK dynamic table size
+const http2TableSize = 4096
+
+// http2ErrorCodes names the HTTP/2 error codes of RST_STREAM frames
+var http2ErrorCodes = []string{
+	"NO_ERROR", "PROTOCOL_ERROR", "INTERNAL_ERROR", "FLOW_CONTROL_ERROR",
+	"SETTINGS_TIMEOUT", "STREAM_CLOSED", "FRAME_SIZE_ERROR", "REFUSED_STREAM",
+	"CANCEL", "COMPRESSION_ERROR", "CONNECT_ERROR", "ENHANCE_YOUR_CALM",
+	"INADEQUATE_SECURITY", "HTTP_1_1_REQUIRED",
+}
+
+// HTTP2Stream is a request and its response on one HTTP/2 stream
+type HTTP2Stream struct {
+	Client    netip.AddrPort `json:"client"`
+	Server    netip.AddrPort `json:"server"`
+	StreamID  uint32         `json:"stream_id"`
+	Method    string         `json:"method,omitempty"`
+	Path      string         `json:"path,omitempty"`
+	Authority string         `json:"authority,omitempty"`
+	// Status is the final response status; 0 when no response was captured
+	Status       int       `json:"status,omitempty"`
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+	// Reset is the error code of a RST_STREAM that ended the stream
+	Reset string `json:"reset,omitempty"`
+}
+
+// Failed reports a 4xx or 5xx response
+func (s HTTP2Stream) Failed() bool {
+	return s.Status >= 400
+}
+
+// Time returns when the stream was first seen
+func (s HTTP2Stream) Time() time.Time {
+	if s.RequestTime.IsZero() {
+		return s.ResponseTime
+	}
+	return s.RequestTime
+}
+
+// http2Conn decodes the frames of an HTTP/2 connection over cleartext TCP
+type http2Conn struct {
+	s       *Summarizer
+	conn    *conn
+	sides   [2]http2Side
+	streams map[uint32]int
+}
+
+// http2Side is the decoding state of one direction of an HTTP/2 connection
+type http2Side struct {
+	buf []byte
+	// prefaced is set once the client preface was looked for
+	prefaced bool
+	broken   bool
+	decoder  *hpack.Decoder
+	fields   []hpack.HeaderField
+
+	// block accumulates a header block continued in CONTINUATION frames
+	block       []byte
+	blockStream uint32
+	blockPush   bool
+	inBlock     bool
+}
+
+func newHTTP2Conn(s *Summarizer, c *conn) *http2Conn {
+	h := &http2Conn{s: s, conn: c, streams: make(map[uint32]int)}
+	for i := range h.sides {
+		h.sides[i].resetDecoder()
+	}
+	return h
+}
+
+// resetDecoder starts decoding with an empty dynamic table
+func (s *http2Side) resetDecoder() {
+	s.decoder = hpack.NewDecoder(http2TableSize, func(field hpack.HeaderField) {
+		s.fields = append(s.fields, field)
+	})
+}
+
+func (h *http2Conn) feed(dir int, ts time.Time, data []byte) {
+	side := &h.sides[dir]
+	if side.broken {
+		return
+	}
+	side.buf = append(side.buf, data...)
+
+	if !side.prefaced {
+		n := min(len(side.buf), len(http2Preface))
+		if string(side.buf[:n]) == http2Preface[:n] {
+			if n < len(http2Preface) {
+				return
+			}
+			side.buf = side.buf[n:]
+		}
+		side.prefaced = true
+	}
+
+	buf := side.buf
+	for len(buf) >= http2FrameHeaderLen {
+		length := int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+		if len(buf) < http2FrameHeaderLen+length {
+			break
+		}
+		stream := binary.BigEndian.Uint32(buf[5:9]) & 0x7fffffff
+		if err := h.frame(dir, ts, buf[3], buf[4], stream, buf[http2FrameHeaderLen:http2FrameHeaderLen+length]); err != nil {
+			side.broken = true
+			side.buf = nil
+			return
+		}
+		buf = buf[http2FrameHeaderLen+length:]
+	}
+	side.buf = append(side.buf[:0], buf...)
+}
+
+// frame handles one frame sent in direction dir
+func (h *http2Conn) frame(dir int, ts time.Time, typ, flags byte, stream uint32, payload []byte) error {
+	side := &h.sides[dir]
+	switch typ {
+	case http2Headers, http2PushPromise:
+		block, err := http2Unpad(flags, payload)
+		if err != nil {
+			return err
+		}
+		if typ == http2Headers && flags&http2Priority != 0 {
+			if len(block) < 5 {
+				return fmt.Errorf("short HEADERS frame")
+			}
+			block = block[5:]
+		}
+		if typ == http2PushPromise {
+			if len(block) < 4 {
+				return fmt.Errorf("short PUSH_PROMISE frame")
+			}
+			block = block[4:]
+		}
+		side.block = append(side.block[:0], block...)
+		side.blockStream, side.blockPush, side.inBlock = stream, typ == http2PushPromise, true
+	case http2Continuation:
+		if !side.inBlock {
+			return nil
+		}
+		side.block = append(side.block, payload...)
+	case http2RSTStream:
+		if len(payload) == 4 {
+			h.stream(stream).Reset = http2ErrorCode(binary.BigEndian.Uint32(payload))
+		}
+		return nil
+	case http2Settings:
+		if flags&http2Ack != 0 {
+			return nil
+		}
+		// The table size the peer decodes with bounds this side's encoder
+		for p := payload; len(p) >= 6; p = p[6:] {
+			if binary.BigEndian.Uint16(p[0:2]) == 1 {
+				h.sides[1-dir].decoder.SetAllowedMaxDynamicTableSize(binary.BigEndian.Uint32(p[2:6]))
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if flags&http2EndHeaders != 0 {
+		side.inBlock = false
+		h.headers(dir, ts)
+	}
+	return nil
+}
+
+// headers decodes a complete header block
+func (h *http2Conn) headers(dir int, ts time.Time) {
+	side := &h.sides[dir]
+	side.fields = side.fields[:0]
+	_, err := side.decoder.Write(side.block)
+	if err == nil {
+		err = side.decoder.Close()
+	}
+	if err != nil {
+		// Usually the capture started after the connection and misses entries
+		// of the dynamic table. The fields decoded up to the error are kept,
+		// and later blocks still decode fields of the static table.
+		h.s.summary.HPACKErrors++
+		side.resetDecoder()
+	}
+	if side.blockPush {
+		return
+	}
+
+	var method, path, authority, status string
+	for _, field := range side.fields {
+		switch field.Name {
+		case ":method":
+			method = field.Value
+		case ":path":
+			path = field.Value
+		case ":authority":
+			authority = field.Value
+		case ":status":
+			status = field.Value
+		}
+	}
+
+	if method == "" && status == "" {
+		// Trailers
+		return
+	}
+	stream := h.stream(side.blockStream)
+	if method != "" {
+		stream.Client, stream.Server = h.conn.endpoints(dir)
+		stream.Method, stream.Path, stream.Authority = method, path, authority
+		stream.RequestTime = ts
+	}
+	if code, err := strconv.Atoi(status); err == nil && (code >= 200 || stream.Status == 0) {
+		if stream.RequestTime.IsZero() {
+			stream.Server, stream.Client = h.conn.endpoints(dir)
+		}
+		stream.Status = code
+		stream.ResponseTime = ts
+	}
+}
+
+// stream returns the summary of a stream, adding it when first seen
+func (h *http2Conn) stream(id uint32) *HTTP2Stream {
+	if i, ok := h.streams[id]; ok {
+		return &h.s.summary.HTTP2[i]
+	}
+	h.streams[id] = len(h.s.summary.HTTP2)
+	h.s.summary.HTTP2 = append(h.s.summary.HTTP2, HTTP2Stream{
+		Client:   h.conn.flow.Client,
+		Server:   h.conn.flow.Server,
+		StreamID: id,
+	})
+	return &h.s.summary.HTTP2[len(h.s.summary.HTTP2)-1]
+}
+
+// http2Unpad strips the padding of a padded frame
+func http2Unpad(flags byte, payload []byte) ([]byte, error) {
+	if flags&http2Padded == 0 {
+		return payload, nil
+	}
+	if len(payload) == 0 || int(payload[0]) >= len(payload) {
+		return nil, fmt.Errorf("invalid frame padding")
+	}
+	return payload[1 : len(payload)-int(payload[0])], nil
+}
+
+// http2ErrorCode names an HTTP/2 error code
+func http2ErrorCode(code uint32) string {
+	if int(code) < len(http2ErrorCodes) {
+		return http2ErrorCodes[code]
+	}
+	return fmt.Sprintf("0x%x", code)
+}
+
+// looksLikeHTTP2 reports whether data starts with the client preface or with
+// a run of well-formed HTTP/2 frames, as when a capture starts after the
+// connection was set up
+func looksLikeHTTP2(data []byte) bool {
+	if strings.HasPrefix(string(data), http2Preface) {
+		return true
+	}
+	frames := 0
+	for len(data) >= http2FrameHeaderLen {
+		length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+		typ, stream := data[3], binary.BigEndian.Uint32(data[5:9])
+		if typ > http2Continuation || stream&0x80000000 != 0 || length > 1<<14 {
+			return false
+		}
+		if typ == http2Settings && (stream != 0 || length%6 != 0) {
+			return false
+		}
+		if typ == http2Data || typ == http2Headers || typ == http2RSTStream || typ == http2Continuation {
+			if stream == 0 {
+				return false
+			}
+		}
+		if len(data) < http2FrameHeaderLen+length {
+			break
+		}
+		frames++
+		data = data[http2FrameHeaderLen+length:]
+	}
+	return frames > 0
+}
@@ -0,0 +1,294 @@
+// Package pcap reads packet captures in the pcap and pcapng formats and
+// summarises them offline: flows, HTTP/2 streams, Diameter messages and SCTP
+// associations. It needs no external tools such as tshark.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// LinkType identifies the link-layer header of captured packets
+type LinkType uint32
+
+// Link types written by tcpdump
+const (
+	LinkTypeNull      LinkType = 0
+	LinkTypeEthernet  LinkType = 1
+	LinkTypeRaw       LinkType = 101
+	LinkTypeLinuxSLL  LinkType = 113
+	LinkTypeIPv4      LinkType = 228
+	LinkTypeIPv6      LinkType = 229
+	LinkTypeLinuxSLL2 LinkType = 276
+)
+
+// File format magic numbers
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+	ngSectionHeader   = 0x0a0d0d0a
+	ngByteOrderMagic  = 0x1a2b3c4d
+)
+
+// pcapng block types
+const (
+	ngInterfaceDescription = 0x00000001
+	ngObsoletePacket       = 0x00000002
+	ngSimplePacket         = 0x00000003
+	ngEnhancedPacket       = 0x00000006
+)
+
+// maxBlockSize bounds a packet record or pcapng block, so a corrupt length
+// cannot exhaust memory
+const maxBlockSize = 16 << 20
+
+// ErrUnknownFormat is returned for files that are neither pcap nor pcapng
+var ErrUnknownFormat = errors.New("not a pcap or pcapng capture")
+
+// Packet is one captured packet
+type Packet struct {
+	Timestamp time.Time
+	LinkType  LinkType
+	// Length is the length on the wire; Data is cut to the capture's snaplen
+	Length int
+	Data   []byte
+}
+
+// Reader reads the packets of a pcap or pcapng capture
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+
+	// linkType and nanos describe a pcap file
+	linkType LinkType
+	nanos    bool
+
+	// interfaces are the pcapng interfaces of the current section
+	interfaces []ngInterface
+}
+
+// ngInterface is a pcapng interface description
+type ngInterface struct {
+	linkType LinkType
+	// units is the number of timestamp units per second
+	units uint64
+}
+
+// NewReader reads the file header of a capture and returns a Reader for its packets
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReaderSize(r, 64<<10)}
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+
+	if binary.BigEndian.Uint32(magic) == ngSectionHeader {
+		reader.ng = true
+		if err := reader.readSectionHeader(); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	if err := reader.readFileHeader(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// readFileHeader reads the header of a pcap file
+func (r *Reader) readFileHeader() error {
+	var header [24]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return fmt.Errorf("failed to read capture header: %w", err)
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[0:4]) {
+		case magicMicroseconds:
+			r.order = order
+		case magicNanoseconds:
+			r.order, r.nanos = order, true
+		default:
+			continue
+		}
+		// The upper bits of the link type field hold FCS information
+		r.linkType = LinkType(order.Uint32(header[20:24]) & 0x0fffffff)
+		return nil
+	}
+	return ErrUnknownFormat
+}
+
+// Next returns the next packet, or io.EOF after the last one. A capture cut
+// off in the middle of a packet, as left by a killed tcpdump, ends with
+// io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Packet, error) {
+	if r.ng {
+		return r.nextBlock()
+	}
+
+	var header [16]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return Packet{}, err
+	}
+	seconds := int64(r.order.Uint32(header[0:4]))
+	fraction := int64(r.order.Uint32(header[4:8]))
+	captured := r.order.Uint32(header[8:12])
+	if captured > maxBlockSize {
+		return Packet{}, fmt.Errorf("packet record of %d bytes is too large", captured)
+	}
+
+	data := make([]byte, captured)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Packet{}, unexpectedEOF(err)
+	}
+	if !r.nanos {
+		fraction *= int64(time.Microsecond)
+	}
+	return Packet{
+		Timestamp: time.Unix(seconds, fraction).UTC(),
+		LinkType:  r.linkType,
+		Length:    int(r.order.Uint32(header[12:16])),
+		Data:      data,
+	}, nil
+}
+
+// readSectionHeader reads a pcapng section header block, which sets the byte
+// order of the blocks following it
+func (r *Reader) readSectionHeader() error {
+	var header [12]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header[8:12]) == ngByteOrderMagic:
+		r.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[8:12]) == ngByteOrderMagic:
+		r.order = binary.BigEndian
+	default:
+		return ErrUnknownFormat
+	}
+
+	length := r.order.Uint32(header[4:8])
+	if length < 28 || length > maxBlockSize {
+		return fmt.Errorf("invalid pcapng section header length %d", length)
+	}
+	if _, err := r.r.Discard(int(length) - len(header)); err != nil {
+		return unexpectedEOF(err)
+	}
+	r.interfaces = nil
+	return nil
+}
+
+// nextBlock reads pcapng blocks up to the next packet
+func (r *Reader) nextBlock() (Packet, error) {
+	for {
+		typ, err := r.r.Peek(4)
+		if err != nil {
+			return Packet{}, err
+		}
+		if binary.BigEndian.Uint32(typ) == ngSectionHeader {
+			if err := r.readSectionHeader(); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+
+		var header [8]byte
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			return Packet{}, unexpectedEOF(err)
+		}
+		length := r.order.Uint32(header[4:8])
+		if length < 12 || length%4 != 0 || length > maxBlockSize {
+			return Packet{}, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-12)
+		if _, err := io.ReadFull(r.r, body); err != nil {
+			return Packet{}, unexpectedEOF(err)
+		}
+		if _, err := r.r.Discard(4); err != nil {
+			return Packet{}, unexpectedEOF(err)
+		}
+
+		switch r.order.Uint32(header[0:4]) {
+		case ngInterfaceDescription:
+			r.addInterface(body)
+		case ngEnhancedPacket:
+			if len(body) < 20 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet block")
+			}
+			return r.ngPacket(r.order.Uint32(body[0:4]), uint64(r.order.Uint32(body[4:8]))<<32|uint64(r.order.Uint32(body[8:12])),
+				r.order.Uint32(body[12:16]), r.order.Uint32(body[16:20]), body[20:])
+		case ngObsoletePacket:
+			if len(body) < 20 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet block")
+			}
+			return r.ngPacket(uint32(r.order.Uint16(body[0:2])), uint64(r.order.Uint32(body[4:8]))<<32|uint64(r.order.Uint32(body[8:12])),
+				r.order.Uint32(body[12:16]), r.order.Uint32(body[16:20]), body[20:])
+		case ngSimplePacket:
+			if len(body) < 4 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet block")
+			}
+			// Simple packets carry no timestamp and are captured on the first interface
+			length := r.order.Uint32(body[0:4])
+			return r.ngPacket(0, 0, min(length, uint32(len(body)-4)), length, body[4:])
+		}
+	}
+}
+
+// addInterface records an interface description block
+func (r *Reader) addInterface(body []byte) {
+	iface := ngInterface{units: 1_000_000}
+	if len(body) >= 8 {
+		iface.linkType = LinkType(r.order.Uint16(body[0:2]))
+		for options := body[8:]; len(options) >= 4; {
+			code, length := r.order.Uint16(options[0:2]), int(r.order.Uint16(options[2:4]))
+			if code == 0 || 4+length > len(options) {
+				break
+			}
+			// if_tsresol is a power of 10, or of 2 with the top bit set
+			if code == 9 && length >= 1 {
+				resolution := options[4]
+				if resolution&0x80 == 0 && resolution <= 19 {
+					iface.units = uint64(math.Pow10(int(resolution)))
+				} else if resolution&0x80 != 0 && resolution&0x7f < 64 {
+					iface.units = 1 << (resolution & 0x7f)
+				}
+			}
+			options = options[4+(length+3)&^3:]
+		}
+	}
+	r.interfaces = append(r.interfaces, iface)
+}
+
+// ngPacket builds a packet of a pcapng packet block
+func (r *Reader) ngPacket(ifaceID uint32, timestamp uint64, captured, length uint32, data []byte) (Packet, error) {
+	if int(ifaceID) >= len(r.interfaces) {
+		return Packet{}, fmt.Errorf("pcapng packet on undescribed interface %d", ifaceID)
+	}
+	if captured > uint32(len(data)) {
+		return Packet{}, fmt.Errorf("pcapng packet of %d bytes exceeds its block", captured)
+	}
+	iface := r.interfaces[ifaceID]
+	seconds := timestamp / iface.units
+	nanos := (timestamp % iface.units) * uint64(time.Second) / iface.units
+	return Packet{
+		Timestamp: time.Unix(int64(seconds), int64(nanos)).UTC(),
+		LinkType:  iface.linkType,
+		Length:    int(length),
+		Data:      data[:captured:captured],
+	}, nil
+}
+
+// unexpectedEOF reports a capture that ends inside a record
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// pcapFile encodes packets as a pcap file with microsecond timestamps
+func pcapFile(order binary.ByteOrder, linkType LinkType, packets ...Packet) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 24)
+	order.PutUint32(header[0:4], magicMicroseconds)
+	order.PutUint16(header[4:6], 2)
+	order.PutUint16(header[6:8], 4)
+	order.PutUint32(header[16:20], 65535)
+	order.PutUint32(header[20:24], uint32(linkType))
+	buf.Write(header)
+	for _, packet := range packets {
+		record := make([]byte, 16)
+		order.PutUint32(record[0:4], uint32(packet.Timestamp.Unix()))
+		order.PutUint32(record[4:8], uint32(packet.Timestamp.Nanosecond()/1000))
+		order.PutUint32(record[8:12], uint32(len(packet.Data)))
+		order.PutUint32(record[12:16], uint32(max(packet.Length, len(packet.Data))))
+		buf.Write(record)
+		buf.Write(packet.Data)
+	}
+	return buf.Bytes()
+}
+
+// ngBlock encodes a little-endian pcapng block
+func ngBlock(typ uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	block := binary.LittleEndian.AppendUint32(nil, typ)
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(body)+12))
+	block = append(block, body...)
+	return binary.LittleEndian.AppendUint32(block, uint32(len(body)+12))
+}
+
+func TestReader(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 1, 250000000, time.UTC)
+	data := []byte{0x45, 0, 0, 20}
+
+	// pcapng with an interface in nanoseconds and one in the default microseconds
+	shb := binary.LittleEndian.AppendUint32(nil, ngByteOrderMagic)
+	shb = append(shb, 1, 0, 0, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	idbNanos := []byte{byte(LinkTypeLinuxSLL), 0, 0, 0, 0, 0, 4, 0, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0}
+	idbMicros := []byte{byte(LinkTypeEthernet), 0, 0, 0, 0, 0, 4, 0}
+	epb := func(iface uint32, units uint64) []byte {
+		body := binary.LittleEndian.AppendUint32(nil, iface)
+		stamp := uint64(ts.Unix())*units + uint64(ts.Nanosecond())*units/uint64(time.Second)
+		body = binary.LittleEndian.AppendUint32(body, uint32(stamp>>32))
+		body = binary.LittleEndian.AppendUint32(body, uint32(stamp))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(data)))
+		body = binary.LittleEndian.AppendUint32(body, 60)
+		return append(body, data...)
+	}
+	var ng []byte
+	for _, block := range [][]byte{
+		ngBlock(ngSectionHeader, shb),
+		ngBlock(ngInterfaceDescription, idbNanos),
+		ngBlock(ngInterfaceDescription, idbMicros),
+		ngBlock(0x0bad, []byte("custom")),
+		ngBlock(ngEnhancedPacket, epb(0, 1_000_000_000)),
+		ngBlock(ngEnhancedPacket, epb(1, 1_000_000)),
+	} {
+		ng = append(ng, block...)
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  []LinkType
+	}{
+		{name: "pcap little endian", input: pcapFile(binary.LittleEndian, LinkTypeEthernet, Packet{Timestamp: ts, Data: data, Length: 60}), want: []LinkType{LinkTypeEthernet}},
+		{name: "pcap big endian", input: pcapFile(binary.BigEndian, LinkTypeLinuxSLL, Packet{Timestamp: ts, Data: data, Length: 60}), want: []LinkType{LinkTypeLinuxSLL}},
+		{name: "pcapng", input: ng, want: []LinkType{LinkTypeLinuxSLL, LinkTypeEthernet}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			for _, linkType := range tt.want {
+				packet, err := reader.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !packet.Timestamp.Equal(ts) || packet.LinkType != linkType || packet.Length != 60 || !bytes.Equal(packet.Data, data) {
+					t.Errorf("Next() = %+v, want link type %d at %s", packet, linkType, ts)
+				}
+			}
+			if _, err := reader.Next(); err != io.EOF {
+				t.Errorf("Next() after the last packet error = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader(make([]byte, 24))); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("NewReader() on zeros error = %v, want ErrUnknownFormat", err)
+	}
+
+	file := pcapFile(binary.LittleEndian, LinkTypeEthernet, Packet{Timestamp: time.Now(), Data: make([]byte, 40)})
+	reader, err := NewReader(bytes.NewReader(file[:len(file)-10]))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Next() on a truncated packet error = %v, want ErrUnexpectedEOF", err)
+	}
+}
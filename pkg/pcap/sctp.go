@@ -0,0 +1,140 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// SCTP chunk types
+const (
+	sctpData             = 0
+	sctpInit             = 1
+	sctpInitAck          = 2
+	sctpAbort            = 6
+	sctpShutdown         = 7
+	sctpShutdownAck      = 8
+	sctpError            = 9
+	sctpCookieEcho       = 10
+	sctpCookieAck        = 11
+	sctpShutdownComplete = 14
+)
+
+// SCTP association states, as far as the captured chunks show them
+const (
+	SCTPInit        = "init"
+	SCTPEstablished = "established"
+	SCTPShutdown    = "shutdown"
+	SCTPClosed      = "closed"
+	SCTPAborted     = "aborted"
+)
+
+// SCTPAssociation is an SCTP association between two endpoints
+type SCTPAssociation struct {
+	// Client sent the INIT; without one seen, it is the endpoint with the higher port
+	Client    netip.AddrPort `json:"client"`
+	Server    netip.AddrPort `json:"server"`
+	FirstSeen time.Time      `json:"first_seen"`
+	LastSeen  time.Time      `json:"last_seen"`
+	// State is the last state shown by the chunks, empty when only DATA was seen
+	State      string `json:"state,omitempty"`
+	DataChunks int    `json:"data_chunks"`
+	// PPIDs are the payload protocol identifiers of the DATA chunks
+	PPIDs []uint32 `json:"ppids,omitempty"`
+	// Errors counts ERROR chunks
+	Errors int `json:"errors,omitempty"`
+}
+
+// sctpAssociation is the decoding state of an SCTP association
+type sctpAssociation struct {
+	SCTPAssociation
+	ppids map[uint32]bool
+	sides [2]sctpSide
+}
+
+// sctpSide reassembles the Diameter messages of one direction
+type sctpSide struct {
+	started bool
+	// tsn is the highest TSN seen, used to skip retransmissions
+	tsn uint32
+	// fragments holds the message being reassembled per stream
+	fragments map[uint16][]byte
+}
+
+// sctp decodes the chunks of an SCTP packet in direction dir
+func (s *Summarizer) sctp(c *conn, dir int, ts time.Time, chunks []byte) {
+	assoc := c.sctp
+	for len(chunks) >= 4 {
+		typ, flags := chunks[0], chunks[1]
+		length := int(binary.BigEndian.Uint16(chunks[2:4]))
+		if length < 4 || length > len(chunks) {
+			return
+		}
+		value := chunks[4:length]
+		chunks = chunks[min((length+3)&^3, len(chunks)):]
+
+		switch typ {
+		case sctpData:
+			if len(value) < 12 {
+				continue
+			}
+			assoc.DataChunks++
+			ppid := binary.BigEndian.Uint32(value[8:12])
+			if !assoc.ppids[ppid] {
+				assoc.ppids[ppid] = true
+				assoc.PPIDs = append(assoc.PPIDs, ppid)
+				sort.Slice(assoc.PPIDs, func(i, j int) bool { return assoc.PPIDs[i] < assoc.PPIDs[j] })
+			}
+			if assoc.State == "" || assoc.State == SCTPInit {
+				assoc.State = SCTPEstablished
+			}
+			if ppid == diameterPPID || c.flow.Server.Port() == diameterPort {
+				c.flow.Application = ApplicationDiameter
+				s.sctpDiameter(c, dir, ts, flags, binary.BigEndian.Uint32(value[0:4]), binary.BigEndian.Uint16(value[4:6]), value[12:])
+			}
+		case sctpInit, sctpInitAck:
+			assoc.State = SCTPInit
+		case sctpCookieEcho, sctpCookieAck:
+			assoc.State = SCTPEstablished
+		case sctpShutdown, sctpShutdownAck:
+			assoc.State = SCTPShutdown
+		case sctpShutdownComplete:
+			assoc.State = SCTPClosed
+		case sctpAbort:
+			assoc.State = SCTPAborted
+		case sctpError:
+			assoc.Errors++
+		}
+	}
+}
+
+// sctpDiameter reassembles the Diameter message carried in a DATA chunk. The
+// B and E flags mark the first and last fragment of a message.
+func (s *Summarizer) sctpDiameter(c *conn, dir int, ts time.Time, flags byte, tsn uint32, stream uint16, data []byte) {
+	side := &c.sctp.sides[dir]
+	if side.started && int32(tsn-side.tsn) <= 0 {
+		return
+	}
+	side.started, side.tsn = true, tsn
+
+	begin, end := flags&0x02 != 0, flags&0x01 != 0
+	if !begin {
+		fragment, ok := side.fragments[stream]
+		if !ok {
+			return
+		}
+		data = append(fragment, data...)
+	}
+	if !end {
+		if side.fragments == nil {
+			side.fragments = make(map[uint16][]byte)
+		}
+		side.fragments[stream] = append([]byte(nil), data...)
+		return
+	}
+	delete(side.fragments, stream)
+
+	src, dst := c.endpoints(dir)
+	s.addDiameter(data, ts, TransportSCTP, src, dst)
+}
@@ -0,0 +1,278 @@
+package pcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"time"
+)
+
+// Summary summarises the packets of one or more captures
+type Summary struct {
+	Packets int `json:"packets"`
+	// Undecoded counts packets other than TCP, UDP or SCTP over IP, and IP fragments
+	Undecoded int       `json:"undecoded"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Truncated is set when a capture ended inside a packet
+	Truncated bool `json:"truncated,omitempty"`
+	// LostStreams counts TCP directions that were no longer decoded after a gap
+	LostStreams int `json:"lost_streams,omitempty"`
+	// HPACKErrors counts HTTP/2 header blocks that were not fully decoded
+	HPACKErrors int               `json:"hpack_errors,omitempty"`
+	Flows       []Flow            `json:"flows"`
+	HTTP2       []HTTP2Stream     `json:"http2,omitempty"`
+	Diameter    []DiameterMessage `json:"diameter,omitempty"`
+	SCTP        []SCTPAssociation `json:"sctp,omitempty"`
+}
+
+// diameterKey matches a Diameter answer to its request
+type diameterKey struct {
+	hopByHop uint32
+	src, dst netip.AddrPort
+}
+
+// Summarizer builds the summary of the packets added to it
+type Summarizer struct {
+	summary Summary
+	conns   map[flowKey]*conn
+	order   []*conn
+	// requests indexes the unanswered Diameter requests in summary.Diameter
+	requests map[diameterKey]int
+}
+
+// NewSummarizer creates an empty Summarizer
+func NewSummarizer() *Summarizer {
+	return &Summarizer{
+		conns:    make(map[flowKey]*conn),
+		requests: make(map[diameterKey]int),
+	}
+}
+
+// Add reads every packet of a capture. Captures added in turn continue each
+// other, as the files of a rotated capture do.
+func (s *Summarizer) Add(r io.Reader) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		packet, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			s.summary.Truncated = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read packet %d: %w", s.summary.Packets+1, err)
+		}
+		s.AddPacket(packet)
+	}
+}
+
+// AddPacket adds one packet
+func (s *Summarizer) AddPacket(packet Packet) {
+	s.summary.Packets++
+	if s.summary.FirstSeen.IsZero() || packet.Timestamp.Before(s.summary.FirstSeen) {
+		s.summary.FirstSeen = packet.Timestamp
+	}
+	if packet.Timestamp.After(s.summary.LastSeen) {
+		s.summary.LastSeen = packet.Timestamp
+	}
+
+	seg, ok := decode(packet)
+	if !ok {
+		s.summary.Undecoded++
+		return
+	}
+	c := s.conn(seg)
+	c.flow.Packets++
+	c.flow.Bytes += int64(packet.Length)
+	if c.flow.FirstSeen.IsZero() || packet.Timestamp.Before(c.flow.FirstSeen) {
+		c.flow.FirstSeen = packet.Timestamp
+	}
+	if packet.Timestamp.After(c.flow.LastSeen) {
+		c.flow.LastSeen = packet.Timestamp
+	}
+
+	dir := c.direction(seg)
+	switch seg.proto {
+	case protoTCP:
+		s.tcp(c, dir, packet.Timestamp, seg)
+	case protoSCTP:
+		s.sctp(c, dir, packet.Timestamp, seg.payload)
+	}
+}
+
+// conn returns the connection of a segment, adding it when first seen
+func (s *Summarizer) conn(seg segment) *conn {
+	key := newFlowKey(seg)
+	if c, ok := s.conns[key]; ok {
+		return c
+	}
+
+	client, server := seg.src, seg.dst
+	switch {
+	case seg.proto == protoTCP && seg.flags&(tcpSYN|tcpACK) == tcpSYN:
+	case seg.proto == protoTCP && seg.flags&(tcpSYN|tcpACK) == tcpSYN|tcpACK:
+		client, server = server, client
+	case seg.proto == protoSCTP && len(seg.payload) > 0 && seg.payload[0] == sctpInit:
+	case seg.proto == protoSCTP && len(seg.payload) > 0 && seg.payload[0] == sctpInitAck:
+		client, server = server, client
+	case client.Port() < server.Port():
+		// Servers usually listen on the lower port
+		client, server = server, client
+	}
+
+	c := &conn{flow: &Flow{Client: client, Server: server}}
+	switch seg.proto {
+	case protoTCP:
+		c.flow.Transport = TransportTCP
+	case protoUDP:
+		c.flow.Transport = TransportUDP
+	case protoSCTP:
+		c.flow.Transport = TransportSCTP
+		c.sctp = &sctpAssociation{ppids: make(map[uint32]bool)}
+	}
+	s.conns[key] = c
+	s.order = append(s.order, c)
+	return c
+}
+
+// tcp reassembles a TCP segment and hands new payload to the connection's parser
+func (s *Summarizer) tcp(c *conn, dir int, ts time.Time, seg segment) {
+	if seg.flags&tcpRST != 0 {
+		c.flow.Resets++
+	}
+	stream := &c.streams[dir]
+	if seg.flags&tcpSYN != 0 {
+		stream.syn(seg.seq)
+		return
+	}
+
+	lost := stream.lost
+	data := stream.add(seg.seq, seg.payload)
+	if stream.lost && !lost {
+		s.summary.LostStreams++
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if !c.detected {
+		c.detected = true
+		switch {
+		case looksLikeHTTP2(data):
+			c.flow.Application = ApplicationHTTP2
+			c.parser = newHTTP2Conn(s, c)
+		case c.flow.Server.Port() == diameterPort || looksLikeDiameter(data):
+			c.flow.Application = ApplicationDiameter
+			c.parser = &diameterConn{s: s, conn: c}
+		}
+	}
+	if c.parser != nil {
+		c.parser.feed(dir, ts, data)
+	}
+}
+
+// addDiameter records a Diameter message and matches answers to their requests
+func (s *Summarizer) addDiameter(data []byte, ts time.Time, transport string, src, dst netip.AddrPort) {
+	msg, err := parseDiameter(data)
+	if err != nil {
+		return
+	}
+	msg.Time, msg.Transport, msg.Src, msg.Dst = ts, transport, src, dst
+
+	if msg.Request {
+		s.requests[diameterKey{msg.HopByHop, src, dst}] = len(s.summary.Diameter)
+	} else if i, ok := s.requests[diameterKey{msg.HopByHop, dst, src}]; ok {
+		delete(s.requests, diameterKey{msg.HopByHop, dst, src})
+		msg.Latency = ts.Sub(s.summary.Diameter[i].Time)
+	}
+	s.summary.Diameter = append(s.summary.Diameter, msg)
+}
+
+// Summary returns the summary of the packets added so far
+func (s *Summarizer) Summary() *Summary {
+	summary := s.summary
+	summary.Flows = make([]Flow, 0, len(s.order))
+	summary.HTTP2 = append([]HTTP2Stream(nil), s.summary.HTTP2...)
+	summary.Diameter = append([]DiameterMessage(nil), s.summary.Diameter...)
+	for _, c := range s.order {
+		summary.Flows = append(summary.Flows, *c.flow)
+		if c.sctp != nil {
+			assoc := c.sctp.SCTPAssociation
+			assoc.Client, assoc.Server = c.flow.Client, c.flow.Server
+			assoc.FirstSeen, assoc.LastSeen = c.flow.FirstSeen, c.flow.LastSeen
+			summary.SCTP = append(summary.SCTP, assoc)
+		}
+	}
+
+	sort.SliceStable(summary.HTTP2, func(i, j int) bool {
+		return summary.HTTP2[i].Time().Before(summary.HTTP2[j].Time())
+	})
+	sort.SliceStable(summary.Diameter, func(i, j int) bool {
+		return summary.Diameter[i].Time.Before(summary.Diameter[j].Time)
+	})
+	return &summary
+}
+
+// SummarizeFiles summarises capture files as one capture. The files are read
+// in the order of their first packet, which puts the files of a rotated
+// capture back in sequence.
+func SummarizeFiles(paths ...string) (*Summary, error) {
+	type capture struct {
+		path  string
+		start time.Time
+	}
+	captures := make([]capture, 0, len(paths))
+	for _, path := range paths {
+		start, err := firstPacketTime(path)
+		if err != nil {
+			return nil, err
+		}
+		captures = append(captures, capture{path: path, start: start})
+	}
+	sort.SliceStable(captures, func(i, j int) bool {
+		return captures[i].start.Before(captures[j].start)
+	})
+
+	s := NewSummarizer()
+	for _, capture := range captures {
+		file, err := os.Open(capture.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open capture: %w", err)
+		}
+		err = s.Add(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", capture.path, err)
+		}
+	}
+	return s.Summary(), nil
+}
+
+// firstPacketTime returns the time of the first packet of a capture file, zero
+// for an empty capture
+func firstPacketTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open capture: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := NewReader(file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	packet, err := reader.Next()
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return packet.Timestamp, nil
+}
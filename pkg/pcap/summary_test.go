@@ -0,0 +1,296 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+var (
+	amf = netip.MustParseAddrPort("10.0.0.1:41000")
+	udm = netip.MustParseAddrPort("10.0.0.2:80")
+	mme = netip.MustParseAddrPort("10.0.1.1:50000")
+	hss = netip.MustParseAddrPort("10.0.1.2:3868")
+)
+
+var testStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// ipv4 wraps a transport segment in Ethernet and IPv4 headers
+func ipv4(proto uint8, src, dst netip.AddrPort, transport []byte) []byte {
+	frame := make([]byte, 14, 34+len(transport))
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(transport)))
+	ip[8], ip[9] = 64, proto
+	copy(ip[12:16], src.Addr().AsSlice())
+	copy(ip[16:20], dst.Addr().AsSlice())
+	frame = append(frame, ip...)
+	return append(frame, transport...)
+}
+
+func tcpPacket(src, dst netip.AddrPort, seq uint32, flags byte, payload []byte) []byte {
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:2], src.Port())
+	binary.BigEndian.PutUint16(header[2:4], dst.Port())
+	binary.BigEndian.PutUint32(header[4:8], seq)
+	header[12], header[13] = 5<<4, flags
+	return ipv4(protoTCP, src, dst, append(header, payload...))
+}
+
+func sctpPacket(src, dst netip.AddrPort, chunks ...[]byte) []byte {
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint16(header[0:2], src.Port())
+	binary.BigEndian.PutUint16(header[2:4], dst.Port())
+	for _, chunk := range chunks {
+		header = append(header, chunk...)
+	}
+	return ipv4(protoSCTP, src, dst, header)
+}
+
+func sctpChunk(typ, flags byte, value []byte) []byte {
+	chunk := []byte{typ, flags, 0, 0}
+	binary.BigEndian.PutUint16(chunk[2:4], uint16(4+len(value)))
+	chunk = append(chunk, value...)
+	for len(chunk)%4 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func sctpDataChunk(flags byte, tsn uint32, data []byte) []byte {
+	value := binary.BigEndian.AppendUint32(nil, tsn)
+	value = append(value, 0, 0, 0, 0)
+	value = binary.BigEndian.AppendUint32(value, diameterPPID)
+	return sctpChunk(sctpData, flags, append(value, data...))
+}
+
+func http2Frame(typ, flags byte, stream uint32, payload []byte) []byte {
+	frame := []byte{byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload)), typ, flags}
+	frame = binary.BigEndian.AppendUint32(frame, stream)
+	return append(frame, payload...)
+}
+
+// headerBlock encodes header fields given as name, value pairs
+func headerBlock(encoder *hpack.Encoder, buf *bytes.Buffer, fields ...string) []byte {
+	buf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+func avp(code uint32, value []byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, code)
+	data = binary.BigEndian.AppendUint32(data, uint32(8+len(value)))
+	data[4] = 0x40
+	data = append(data, value...)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+func diameterMessage(command uint32, request bool, hopByHop uint32, avps ...[]byte) []byte {
+	var body []byte
+	for _, a := range avps {
+		body = append(body, a...)
+	}
+	msg := binary.BigEndian.AppendUint32(nil, uint32(diameterHeaderLen+len(body)))
+	msg[0] = 1
+	msg = binary.BigEndian.AppendUint32(msg, command)
+	if request {
+		msg[4] = 0x80
+	}
+	msg = binary.BigEndian.AppendUint32(msg, 16777251)
+	msg = binary.BigEndian.AppendUint32(msg, hopByHop)
+	msg = binary.BigEndian.AppendUint32(msg, hopByHop)
+	return append(msg, body...)
+}
+
+func uint32AVP(code, value uint32) []byte {
+	return avp(code, binary.BigEndian.AppendUint32(nil, value))
+}
+
+// http2Packets is an HTTP/2 connection with a failed registration and a
+// successful query, the second response arriving out of order
+func http2Packets() []Packet {
+	var clientBuf, serverBuf bytes.Buffer
+	client, server := hpack.NewEncoder(&clientBuf), hpack.NewEncoder(&serverBuf)
+
+	register := headerBlock(client, &clientBuf, ":method", "PUT", ":path", "/nudm-uecm/v1/imsi-208930000000001/registrations/amf-3gpp-access", ":authority", "udm.example", ":scheme", "http")
+	query := headerBlock(client, &clientBuf, ":method", "GET", ":path", "/nudm-sdm/v2/imsi-208930000000001/am-data", ":authority", "udm.example", ":scheme", "http")
+	unavailable := headerBlock(server, &serverBuf, ":status", "503", "content-type", "application/problem+json")
+	ok := headerBlock(server, &serverBuf, ":status", "200", "content-type", "application/json")
+
+	clientData := []byte(http2Preface)
+	clientData = append(clientData, http2Frame(http2Settings, 0, 0, []byte{0, 1, 0, 0, 0x10, 0})...)
+	clientData = append(clientData, http2Frame(http2Headers, http2EndHeaders|1, 1, register)...)
+	queryFrame := http2Frame(http2Headers, 0, 3, query[:4])
+	queryFrame = append(queryFrame, http2Frame(http2Continuation, http2EndHeaders, 3, query[4:])...)
+
+	response := http2Frame(http2Headers, http2EndHeaders, 1, unavailable)
+	response = append(response, http2Frame(http2Data, 1, 1, []byte(`{"cause":"SYSTEM_FAILURE"}`))...)
+	response = append(response, http2Frame(http2Headers, http2EndHeaders|1, 3, ok)...)
+
+	at := func(ms int) time.Time { return testStart.Add(time.Duration(ms) * time.Millisecond) }
+	split := 20
+	return []Packet{
+		{Timestamp: at(0), LinkType: LinkTypeEthernet, Data: tcpPacket(amf, udm, 1000, tcpSYN, nil)},
+		{Timestamp: at(1), LinkType: LinkTypeEthernet, Data: tcpPacket(udm, amf, 5000, tcpSYN|tcpACK, nil)},
+		{Timestamp: at(2), LinkType: LinkTypeEthernet, Data: tcpPacket(amf, udm, 1001, tcpACK, clientData)},
+		{Timestamp: at(3), LinkType: LinkTypeEthernet, Data: tcpPacket(amf, udm, 1001+uint32(len(clientData)), tcpACK, queryFrame)},
+		// Retransmission of data already seen
+		{Timestamp: at(4), LinkType: LinkTypeEthernet, Data: tcpPacket(amf, udm, 1001, tcpACK, clientData[:30])},
+		{Timestamp: at(50), LinkType: LinkTypeEthernet, Data: tcpPacket(udm, amf, 5001+uint32(split), tcpACK, response[split:])},
+		{Timestamp: at(51), LinkType: LinkTypeEthernet, Data: tcpPacket(udm, amf, 5001, tcpACK, response[:split])},
+	}
+}
+
+func TestSummarizeHTTP2(t *testing.T) {
+	s := NewSummarizer()
+	for _, packet := range http2Packets() {
+		s.AddPacket(packet)
+	}
+	summary := s.Summary()
+
+	if len(summary.Flows) != 1 {
+		t.Fatalf("Summary() flows = %+v, want 1", summary.Flows)
+	}
+	flow := summary.Flows[0]
+	if flow.Client != amf || flow.Server != udm || flow.Application != ApplicationHTTP2 || flow.Packets != 7 {
+		t.Errorf("Summary() flow = %+v, want an http2 flow from %s to %s", flow, amf, udm)
+	}
+
+	if len(summary.HTTP2) != 2 {
+		t.Fatalf("Summary() http2 = %+v, want 2 streams", summary.HTTP2)
+	}
+	register, query := summary.HTTP2[0], summary.HTTP2[1]
+	if register.StreamID != 1 || register.Method != "PUT" || register.Status != 503 || !register.Failed() ||
+		register.Path != "/nudm-uecm/v1/imsi-208930000000001/registrations/amf-3gpp-access" || register.Authority != "udm.example" {
+		t.Errorf("Summary() stream 1 = %+v, want PUT answered with 503", register)
+	}
+	if !register.RequestTime.Equal(testStart.Add(2*time.Millisecond)) || !register.ResponseTime.Equal(testStart.Add(51*time.Millisecond)) {
+		t.Errorf("Summary() stream 1 times = %s, %s", register.RequestTime, register.ResponseTime)
+	}
+	if query.StreamID != 3 || query.Method != "GET" || query.Status != 200 || query.Failed() || query.Client != amf {
+		t.Errorf("Summary() stream 3 = %+v, want GET answered with 200", query)
+	}
+	if summary.HPACKErrors != 0 || summary.LostStreams != 0 {
+		t.Errorf("Summary() hpack errors = %d, lost streams = %d, want none", summary.HPACKErrors, summary.LostStreams)
+	}
+}
+
+func TestSummarizeDiameterTCP(t *testing.T) {
+	origin := avp(avpOriginHost, []byte("hss1.epc.example"))
+	ulr := diameterMessage(316, true, 0x1234, avp(avpSessionID, []byte("mme1;1;42")), avp(avpOriginHost, []byte("mme1.epc.example")))
+	ula := diameterMessage(316, false, 0x1234, avp(avpSessionID, []byte("mme1;1;42")), origin,
+		avp(avpExperimentalResult, append(uint32AVP(266, 10415), uint32AVP(avpExperimentalResultCode, 5001)...)))
+	dwa := diameterMessage(280, false, 0x99, origin, uint32AVP(avpResultCode, 2001))
+
+	// No handshake: the capture starts inside the connection
+	s := NewSummarizer()
+	s.AddPacket(Packet{Timestamp: testStart, LinkType: LinkTypeEthernet, Data: tcpPacket(mme, hss, 100, tcpACK, ulr[:10])})
+	s.AddPacket(Packet{Timestamp: testStart.Add(time.Millisecond), LinkType: LinkTypeEthernet, Data: tcpPacket(mme, hss, 110, tcpACK, ulr[10:])})
+	s.AddPacket(Packet{Timestamp: testStart.Add(30 * time.Millisecond), LinkType: LinkTypeEthernet, Data: tcpPacket(hss, mme, 900, tcpACK, append(ula, dwa...))})
+	summary := s.Summary()
+
+	if len(summary.Flows) != 1 || summary.Flows[0].Client != mme || summary.Flows[0].Application != ApplicationDiameter {
+		t.Fatalf("Summary() flows = %+v, want a diameter flow from %s", summary.Flows, mme)
+	}
+	if len(summary.Diameter) != 3 {
+		t.Fatalf("Summary() diameter = %+v, want 3 messages", summary.Diameter)
+	}
+	request, answer, watchdog := summary.Diameter[0], summary.Diameter[1], summary.Diameter[2]
+	if request.Name() != "ULR" || request.SessionID != "mme1;1;42" || request.OriginHost != "mme1.epc.example" || request.Failed() {
+		t.Errorf("Summary() request = %+v, want ULR", request)
+	}
+	if answer.Name() != "ULA" || !answer.Failed() || answer.Result() != "DIAMETER_ERROR_USER_UNKNOWN (5001)" ||
+		answer.Latency != 29*time.Millisecond || answer.Src != hss || answer.Transport != TransportTCP {
+		t.Errorf("Summary() answer = %+v (%s), want ULA with DIAMETER_ERROR_USER_UNKNOWN after 29ms", answer, answer.Result())
+	}
+	if watchdog.Name() != "DWA" || watchdog.Failed() || watchdog.Result() != "DIAMETER_SUCCESS (2001)" || watchdog.Latency != 0 {
+		t.Errorf("Summary() watchdog = %+v, want a successful DWA", watchdog)
+	}
+}
+
+func TestSummarizeSCTP(t *testing.T) {
+	air := diameterMessage(318, true, 7, avp(avpSessionID, []byte("mme1;2;7")))
+	aia := diameterMessage(318, false, 7, uint32AVP(avpResultCode, 3002))
+
+	packets := [][]byte{
+		sctpPacket(mme, hss, sctpChunk(sctpInit, 0, make([]byte, 16))),
+		sctpPacket(hss, mme, sctpChunk(sctpInitAck, 0, make([]byte, 16))),
+		sctpPacket(mme, hss, sctpChunk(sctpCookieEcho, 0, make([]byte, 8))),
+		sctpPacket(hss, mme, sctpChunk(sctpCookieAck, 0, nil)),
+		// The request is fragmented, and its second fragment retransmitted
+		sctpPacket(mme, hss, sctpDataChunk(0x02, 1, air[:16])),
+		sctpPacket(mme, hss, sctpDataChunk(0x01, 2, air[16:])),
+		sctpPacket(mme, hss, sctpDataChunk(0x01, 2, air[16:])),
+		sctpPacket(hss, mme, sctpDataChunk(0x03, 50, aia), sctpChunk(sctpError, 0, nil)),
+		sctpPacket(mme, hss, sctpChunk(sctpAbort, 0, nil)),
+	}
+	s := NewSummarizer()
+	for i, data := range packets {
+		s.AddPacket(Packet{Timestamp: testStart.Add(time.Duration(i) * time.Millisecond), LinkType: LinkTypeEthernet, Data: data})
+	}
+	summary := s.Summary()
+
+	if len(summary.SCTP) != 1 {
+		t.Fatalf("Summary() sctp = %+v, want 1 association", summary.SCTP)
+	}
+	assoc := summary.SCTP[0]
+	if assoc.Client != mme || assoc.State != SCTPAborted || assoc.DataChunks != 4 || assoc.Errors != 1 ||
+		len(assoc.PPIDs) != 1 || assoc.PPIDs[0] != diameterPPID {
+		t.Errorf("Summary() association = %+v, want an aborted association from %s", assoc, mme)
+	}
+
+	if len(summary.Diameter) != 2 {
+		t.Fatalf("Summary() diameter = %+v, want AIR and AIA", summary.Diameter)
+	}
+	if air := summary.Diameter[0]; air.Name() != "AIR" || air.SessionID != "mme1;2;7" || air.Transport != TransportSCTP {
+		t.Errorf("Summary() request = %+v, want the reassembled AIR", air)
+	}
+	if aia := summary.Diameter[1]; aia.Name() != "AIA" || !aia.Failed() || aia.Result() != "DIAMETER_UNABLE_TO_DELIVER (3002)" || aia.Latency != 2*time.Millisecond {
+		t.Errorf("Summary() answer = %+v, want AIA with DIAMETER_UNABLE_TO_DELIVER", aia)
+	}
+}
+
+func TestSummarizeFiles(t *testing.T) {
+	dir := t.TempDir()
+	packets := http2Packets()
+	// A rotated capture whose second file sorts first by name
+	files := map[string][]Packet{
+		"capture.pcap1": packets[:4],
+		"capture.pcap0": packets[4:],
+	}
+	var paths []string
+	for name, packets := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pcapFile(binary.LittleEndian, LinkTypeEthernet, packets...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	summary, err := SummarizeFiles(paths...)
+	if err != nil {
+		t.Fatalf("SummarizeFiles() error = %v", err)
+	}
+	if summary.Packets != len(packets) || !summary.FirstSeen.Equal(testStart) {
+		t.Errorf("SummarizeFiles() packets = %d from %s, want %d from %s", summary.Packets, summary.FirstSeen, len(packets), testStart)
+	}
+	if len(summary.HTTP2) != 2 || summary.HTTP2[0].Status != 503 || summary.HTTP2[1].Status != 200 {
+		t.Errorf("SummarizeFiles() http2 = %+v, want the streams across both files", summary.HTTP2)
+	}
+
+	if _, err := SummarizeFiles(filepath.Join(dir, "missing.pcap")); err == nil {
+		t.Error("SummarizeFiles() on a missing file should return error")
+	}
+}
@@ -0,0 +1,412 @@
+// Package robot parses the output.xml written by Robot Framework (pybot) runs.
+// Both the pre-7.0 format (starttime/endtime attributes) and the 7.x format
+// (start/elapsed attributes) are understood.
+package robot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a suite, test or keyword
+type Status string
+
+// Robot Framework statuses
+const (
+	StatusPass   Status = "PASS"
+	StatusFail   Status = "FAIL"
+	StatusSkip   Status = "SKIP"
+	StatusNotRun Status = "NOT RUN"
+	StatusNotSet Status = "NOT SET"
+)
+
+// Result is a parsed output.xml
+type Result struct {
+	Generator string
+	Generated time.Time
+	Suite     *Suite
+	// Errors are the execution errors and warnings listed at the end of the run
+	Errors []Message
+}
+
+// Suite is a test suite and its children
+type Suite struct {
+	ID       string
+	Name     string
+	Source   string
+	Status   Status
+	Message  string
+	Start    time.Time
+	End      time.Time
+	Setup    *Keyword
+	Teardown *Keyword
+	Suites   []*Suite
+	Tests    []*Test
+}
+
+// Test is a single test case
+type Test struct {
+	ID   string
+	Name string
+	// Suite is the dotted long name of the suite the test belongs to
+	Suite    string
+	Tags     []string
+	Status   Status
+	Message  string
+	Start    time.Time
+	End      time.Time
+	Keywords []*Keyword
+}
+
+// Keyword is a keyword or control structure executed by a test or suite
+type Keyword struct {
+	Name    string
+	Library string
+	// Type is the keyword type, e.g. SETUP, TEARDOWN, FOR or IF, or "" for a
+	// normal keyword call
+	Type     string
+	Args     []string
+	Status   Status
+	Message  string
+	Start    time.Time
+	End      time.Time
+	Messages []Message
+	Keywords []*Keyword
+}
+
+// Message is a log message written during the run
+type Message struct {
+	Timestamp time.Time
+	Level     string
+	Text      string
+}
+
+// Duration returns how long the test ran
+func (t *Test) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// LongName returns the test name prefixed with its suite
+func (t *Test) LongName() string {
+	if t.Suite == "" {
+		return t.Name
+	}
+	return t.Suite + "." + t.Name
+}
+
+// Contains reports whether ts falls within the test's execution window
+func (t *Test) Contains(ts time.Time) bool {
+	return !t.Start.IsZero() && !ts.Before(t.Start) && ts.Before(t.End)
+}
+
+// Tests returns every test in the run in execution order
+func (r *Result) Tests() []*Test {
+	var tests []*Test
+	var walk func(s *Suite)
+	walk = func(s *Suite) {
+		tests = append(tests, s.Tests...)
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	if r.Suite != nil {
+		walk(r.Suite)
+	}
+	return tests
+}
+
+// Failed returns the tests that failed
+func (r *Result) Failed() []*Test {
+	var failed []*Test
+	for _, test := range r.Tests() {
+		if test.Status == StatusFail {
+			failed = append(failed, test)
+		}
+	}
+	return failed
+}
+
+// TestAt returns the test that was running at ts, or nil if none was
+func (r *Result) TestAt(ts time.Time) *Test {
+	for _, test := range r.Tests() {
+		if test.Contains(ts) {
+			return test
+		}
+	}
+	return nil
+}
+
+// Totals counts the tests by status
+func (r *Result) Totals() (passed, failed, skipped int) {
+	for _, test := range r.Tests() {
+		switch test.Status {
+		case StatusPass:
+			passed++
+		case StatusFail:
+			failed++
+		case StatusSkip:
+			skipped++
+		}
+	}
+	return passed, failed, skipped
+}
+
+// ParseFile parses an output.xml file. Timestamps are interpreted in loc,
+// which should be the time zone of the host pybot ran on; nil means UTC.
+func ParseFile(path string, loc *time.Location) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	result, err := Parse(file, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// Parse reads an output.xml document. Timestamps are interpreted in loc;
+// nil means UTC.
+func Parse(r io.Reader, loc *time.Location) (*Result, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var doc xmlRobot
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode output.xml: %w", err)
+	}
+	if doc.XMLName.Local != "robot" {
+		return nil, fmt.Errorf("unexpected root element <%s>, want <robot>", doc.XMLName.Local)
+	}
+
+	p := parser{loc: loc}
+	result := &Result{
+		Generator: doc.Generator,
+		Generated: p.time(doc.Generated),
+	}
+	if doc.Suite != nil {
+		result.Suite = p.suite(doc.Suite, "")
+	}
+	for _, msg := range doc.Errors {
+		result.Errors = append(result.Errors, p.message(msg))
+	}
+	return result, nil
+}
+
+// xmlRobot is the <robot> root element
+type xmlRobot struct {
+	XMLName   xml.Name
+	Generator string       `xml:"generator,attr"`
+	Generated string       `xml:"generated,attr"`
+	Suite     *xmlSuite    `xml:"suite"`
+	Errors    []xmlMessage `xml:"errors>msg"`
+}
+
+type xmlSuite struct {
+	ID       string       `xml:"id,attr"`
+	Name     string       `xml:"name,attr"`
+	Source   string       `xml:"source,attr"`
+	Suites   []xmlSuite   `xml:"suite"`
+	Tests    []xmlTest    `xml:"test"`
+	Keywords []xmlKeyword `xml:"kw"`
+	Setup    *xmlKeyword  `xml:"setup"`
+	Teardown *xmlKeyword  `xml:"teardown"`
+	Status   xmlStatus    `xml:"status"`
+}
+
+type xmlTest struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+	// Tags are wrapped in <tags> before 7.0 and direct children afterwards
+	WrappedTags []string     `xml:"tags>tag"`
+	Tags        []string     `xml:"tag"`
+	Doc         string       `xml:"doc"`
+	Timeout     string       `xml:"timeout"`
+	Status      xmlStatus    `xml:"status"`
+	Body        []xmlKeyword `xml:",any"`
+}
+
+type xmlKeyword struct {
+	XMLName xml.Name
+	Name    string `xml:"name,attr"`
+	// Library is called owner from 7.0 on
+	Library  string       `xml:"library,attr"`
+	Owner    string       `xml:"owner,attr"`
+	Type     string       `xml:"type,attr"`
+	Args     []string     `xml:"arg"`
+	Messages []xmlMessage `xml:"msg"`
+	Status   xmlStatus    `xml:"status"`
+	Body     []xmlKeyword `xml:",any"`
+}
+
+type xmlStatus struct {
+	Status    string `xml:"status,attr"`
+	StartTime string `xml:"starttime,attr"`
+	EndTime   string `xml:"endtime,attr"`
+	Start     string `xml:"start,attr"`
+	Elapsed   string `xml:"elapsed,attr"`
+	Message   string `xml:",chardata"`
+}
+
+type xmlMessage struct {
+	Timestamp string `xml:"timestamp,attr"`
+	Time      string `xml:"time,attr"`
+	Level     string `xml:"level,attr"`
+	Text      string `xml:",chardata"`
+}
+
+// bodyElements are the children of a test or keyword that represent executed steps
+var bodyElements = map[string]bool{
+	"kw": true, "setup": true, "teardown": true,
+	"for": true, "iter": true, "if": true, "branch": true,
+	"try": true, "while": true, "group": true,
+}
+
+// timeLayouts are the timestamp formats used by the different output.xml versions
+var timeLayouts = []string{
+	"20060102 15:04:05.000",
+	"2006-01-02T15:04:05.999999",
+}
+
+// parser converts decoded XML into the exported types
+type parser struct {
+	loc *time.Location
+}
+
+func (p parser) suite(s *xmlSuite, parent string) *Suite {
+	suite := &Suite{
+		ID:      s.ID,
+		Name:    s.Name,
+		Source:  s.Source,
+		Status:  Status(s.Status.Status),
+		Message: strings.TrimSpace(s.Status.Message),
+	}
+	suite.Start, suite.End = p.window(s.Status)
+
+	longName := s.Name
+	if parent != "" {
+		longName = parent + "." + s.Name
+	}
+
+	if s.Setup != nil {
+		suite.Setup = p.keyword(s.Setup, "SETUP")
+	}
+	if s.Teardown != nil {
+		suite.Teardown = p.keyword(s.Teardown, "TEARDOWN")
+	}
+	for i := range s.Keywords {
+		kw := p.keyword(&s.Keywords[i], "")
+		switch kw.Type {
+		case "SETUP":
+			suite.Setup = kw
+		case "TEARDOWN":
+			suite.Teardown = kw
+		}
+	}
+
+	for i := range s.Suites {
+		suite.Suites = append(suite.Suites, p.suite(&s.Suites[i], longName))
+	}
+	for i := range s.Tests {
+		suite.Tests = append(suite.Tests, p.test(&s.Tests[i], longName))
+	}
+	return suite
+}
+
+func (p parser) test(t *xmlTest, suite string) *Test {
+	test := &Test{
+		ID:      t.ID,
+		Name:    t.Name,
+		Suite:   suite,
+		Tags:    append(t.WrappedTags, t.Tags...),
+		Status:  Status(t.Status.Status),
+		Message: strings.TrimSpace(t.Status.Message),
+	}
+	test.Start, test.End = p.window(t.Status)
+	test.Keywords = p.body(t.Body)
+	return test
+}
+
+func (p parser) body(elements []xmlKeyword) []*Keyword {
+	var keywords []*Keyword
+	for i := range elements {
+		name := elements[i].XMLName.Local
+		if !bodyElements[name] {
+			continue
+		}
+		kwType := ""
+		if name != "kw" {
+			kwType = strings.ToUpper(name)
+		}
+		keywords = append(keywords, p.keyword(&elements[i], kwType))
+	}
+	return keywords
+}
+
+func (p parser) keyword(k *xmlKeyword, kwType string) *Keyword {
+	if k.Type != "" && !strings.EqualFold(k.Type, "kw") {
+		kwType = strings.ToUpper(k.Type)
+	}
+	library := k.Library
+	if library == "" {
+		library = k.Owner
+	}
+
+	kw := &Keyword{
+		Name:     k.Name,
+		Library:  library,
+		Type:     kwType,
+		Args:     k.Args,
+		Status:   Status(k.Status.Status),
+		Message:  strings.TrimSpace(k.Status.Message),
+		Keywords: p.body(k.Body),
+	}
+	kw.Start, kw.End = p.window(k.Status)
+	for _, msg := range k.Messages {
+		kw.Messages = append(kw.Messages, p.message(msg))
+	}
+	return kw
+}
+
+func (p parser) message(m xmlMessage) Message {
+	ts := m.Timestamp
+	if ts == "" {
+		ts = m.Time
+	}
+	return Message{
+		Timestamp: p.time(ts),
+		Level:     m.Level,
+		Text:      m.Text,
+	}
+}
+
+// window returns the start and end time recorded in a status element
+func (p parser) window(s xmlStatus) (time.Time, time.Time) {
+	if s.Start != "" {
+		start := p.time(s.Start)
+		seconds, err := strconv.ParseFloat(s.Elapsed, 64)
+		if err != nil || start.IsZero() {
+			return start, start
+		}
+		return start, start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return p.time(s.StartTime), p.time(s.EndTime)
+}
+
+// time parses an output.xml timestamp; unset values ("N/A") yield the zero time
+func (p parser) time(value string) time.Time {
+	for _, layout := range timeLayouts {
+		if ts, err := time.ParseInLocation(layout, value, p.loc); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,176 @@
+package robot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const outputRF6 = `<?xml version="1.0" encoding="UTF-8"?>
+<robot generator="Robot 6.1.1 (Python 3.11.4 on linux)" generated="20240301 12:00:00.000" rpa="false" schemaversion="4">
+<suite id="s1" name="Uecm" source="/opt/tests/uecm">
+<suite id="s1-s1" name="Register" source="/opt/tests/uecm/register.robot">
+<kw name="Open Connection" library="SBI" type="SETUP">
+<status status="PASS" starttime="20240301 12:00:00.100" endtime="20240301 12:00:00.200"/>
+</kw>
+<test id="s1-s1-t1" name="TC_UECM_Register_06" line="10">
+<kw name="Register UE" library="UecmLib">
+<arg>imsi-001</arg>
+<msg timestamp="20240301 12:00:01.000" level="INFO">sent PUT</msg>
+<status status="PASS" starttime="20240301 12:00:00.500" endtime="20240301 12:00:04.000"/>
+</kw>
+<tags>
+<tag>smoke</tag>
+</tags>
+<status status="PASS" starttime="20240301 12:00:00.300" endtime="20240301 12:00:05.000"/>
+</test>
+<test id="s1-s1-t2" name="TC_UECM_Register_07" line="20">
+<kw name="Register UE" library="UecmLib">
+<status status="FAIL" starttime="20240301 12:00:05.100" endtime="20240301 12:00:09.000">HTTP 500 != 201</status>
+</kw>
+<status status="FAIL" starttime="20240301 12:00:05.000" endtime="20240301 12:00:10.000">HTTP 500 != 201</status>
+</test>
+<status status="FAIL" starttime="20240301 12:00:00.050" endtime="20240301 12:00:10.100"/>
+</suite>
+<status status="FAIL" starttime="20240301 12:00:00.000" endtime="20240301 12:00:10.200"/>
+</suite>
+<errors>
+<msg timestamp="20240301 12:00:00.010" level="WARN">Imported library has no keywords</msg>
+</errors>
+</robot>
+`
+
+const outputRF7 = `<?xml version="1.0" encoding="UTF-8"?>
+<robot generator="Robot 7.0 (Python 3.12.1 on linux)" generated="2024-03-01T12:00:00.000000" rpa="false" schemaversion="5">
+<suite id="s1" name="Nim" source="/opt/tests/nim.robot">
+<setup name="Open Connection" owner="SBI">
+<status status="PASS" start="2024-03-01T12:00:00.100000" elapsed="0.100"/>
+</setup>
+<test id="s1-t1" name="TC_NIM_Query_01" line="5">
+<for flavor="IN">
+<iter>
+<kw name="Query" owner="NimLib">
+<msg time="2024-03-01T12:00:01.000000" level="FAIL">timeout</msg>
+<status status="FAIL" start="2024-03-01T12:00:00.600000" elapsed="2.0">timeout</status>
+</kw>
+<status status="FAIL" start="2024-03-01T12:00:00.550000" elapsed="2.1"/>
+</iter>
+<status status="FAIL" start="2024-03-01T12:00:00.500000" elapsed="2.2"/>
+</for>
+<tag>regression</tag>
+<status status="FAIL" start="2024-03-01T12:00:00.300000" elapsed="2.5">timeout</status>
+</test>
+<status status="FAIL" start="2024-03-01T12:00:00.000000" elapsed="3.0"/>
+</suite>
+<errors>
+</errors>
+</robot>
+`
+
+func at(value string) time.Time {
+	ts, err := time.ParseInLocation("15:04:05.000", value, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(2024, 3, 1, ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.UTC)
+}
+
+func TestParseRF6(t *testing.T) {
+	result, err := Parse(strings.NewReader(outputRF6), nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if result.Suite.Name != "Uecm" || len(result.Suite.Suites) != 1 {
+		t.Fatalf("Parse() suite = %s with %d children", result.Suite.Name, len(result.Suite.Suites))
+	}
+	register := result.Suite.Suites[0]
+	if register.Setup == nil || register.Setup.Name != "Open Connection" {
+		t.Errorf("Parse() suite setup = %+v", register.Setup)
+	}
+
+	tests := result.Tests()
+	if len(tests) != 2 {
+		t.Fatalf("Tests() returned %d tests, want 2", len(tests))
+	}
+	failed := tests[1]
+	if failed.LongName() != "Uecm.Register.TC_UECM_Register_07" || failed.Status != StatusFail || failed.Message != "HTTP 500 != 201" {
+		t.Errorf("Tests()[1] = %s %s %q", failed.LongName(), failed.Status, failed.Message)
+	}
+	if !failed.Start.Equal(at("12:00:05.000")) || failed.Duration() != 5*time.Second {
+		t.Errorf("Tests()[1] window = %v + %v", failed.Start, failed.Duration())
+	}
+	if len(tests[0].Tags) != 1 || tests[0].Tags[0] != "smoke" {
+		t.Errorf("Tests()[0].Tags = %v", tests[0].Tags)
+	}
+	kw := tests[0].Keywords[0]
+	if kw.Library != "UecmLib" || len(kw.Args) != 1 || len(kw.Messages) != 1 || kw.Messages[0].Text != "sent PUT" {
+		t.Errorf("Tests()[0].Keywords[0] = %+v", kw)
+	}
+
+	if passed, failedCount, skipped := result.Totals(); passed != 1 || failedCount != 1 || skipped != 0 {
+		t.Errorf("Totals() = %d, %d, %d", passed, failedCount, skipped)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Level != "WARN" {
+		t.Errorf("Parse() errors = %+v", result.Errors)
+	}
+}
+
+func TestParseRF7(t *testing.T) {
+	result, err := Parse(strings.NewReader(outputRF7), nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if result.Suite.Setup == nil || result.Suite.Setup.Library != "SBI" {
+		t.Errorf("Parse() suite setup = %+v", result.Suite.Setup)
+	}
+	tests := result.Tests()
+	if len(tests) != 1 {
+		t.Fatalf("Tests() returned %d tests, want 1", len(tests))
+	}
+	test := tests[0]
+	if test.Status != StatusFail || test.Duration() != 2500*time.Millisecond || test.Tags[0] != "regression" {
+		t.Errorf("Tests()[0] = %s %v %v", test.Status, test.Duration(), test.Tags)
+	}
+	loop := test.Keywords[0]
+	if loop.Type != "FOR" || loop.Keywords[0].Type != "ITER" || loop.Keywords[0].Keywords[0].Name != "Query" {
+		t.Errorf("Tests()[0] body = %+v", loop)
+	}
+}
+
+func TestTestAt(t *testing.T) {
+	result, err := Parse(strings.NewReader(outputRF6), nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		ts   time.Time
+		want string
+	}{
+		{ts: at("12:00:01.000"), want: "TC_UECM_Register_06"},
+		{ts: at("12:00:05.000"), want: "TC_UECM_Register_07"},
+		{ts: at("12:00:09.999"), want: "TC_UECM_Register_07"},
+		{ts: at("12:00:10.000"), want: ""},
+		{ts: at("11:59:59.000"), want: ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if test := result.TestAt(tt.ts); test != nil {
+			got = test.Name
+		}
+		if got != tt.want {
+			t.Errorf("TestAt(%v) = %q, want %q", tt.ts, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<html></html>"), nil); err == nil {
+		t.Error("Parse() error = nil, want error for non-robot document")
+	}
+	if _, err := Parse(strings.NewReader("<robot"), nil); err == nil {
+		t.Error("Parse() error = nil, want error for truncated document")
+	}
+}
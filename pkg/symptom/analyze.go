@@ -0,0 +1,768 @@
+package symptom
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/pcap"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+)
+
+// lineTimestampPattern matches the timestamp leading a log line, e.g.
+// "2024-03-01 12:00:00.123", "[2024/03/01 12:00:00]" or "2024-03-01T12:00:00Z"
+var lineTimestampPattern = regexp.MustCompile(`^\[?(\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?)(Z|[+-]\d{2}:?\d{2})?`)
+
+// Bundle is a symptom bundle opened for offline analysis
+type Bundle struct {
+	Manifest *Manifest
+	// Dir is the bundle root holding manifest.json and the artifacts
+	Dir string
+	// extracted is set when Dir is a temporary extraction of an archive
+	extracted bool
+}
+
+// OpenBundle opens a bundle, which may be a .tar.gz archive or an extracted
+// bundle directory. Archives are extracted into a temporary directory that is
+// removed by Close.
+func OpenBundle(path string) (*Bundle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	bundle := &Bundle{Dir: path}
+	if !info.IsDir() {
+		dir, err := os.MkdirTemp("", "symptom-bundle-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create extraction directory: %w", err)
+		}
+		bundle.Dir = dir
+		bundle.extracted = true
+		if err := extractBundle(path, dir); err != nil {
+			bundle.Close()
+			return nil, err
+		}
+	}
+
+	bundle.Manifest, err = ReadManifest(bundle.Dir)
+	if err != nil {
+		bundle.Close()
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Close removes the extracted copy of an archived bundle
+func (b *Bundle) Close() error {
+	if !b.extracted {
+		return nil
+	}
+	return os.RemoveAll(b.Dir)
+}
+
+// extractBundle extracts a bundle archive into dir, dropping the run
+// directory's name that prefixes every entry
+func extractBundle(bundle, dir string) error {
+	file, err := os.Open(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		_, rel, _ := strings.Cut(header.Name, "/")
+		rel = strings.TrimSuffix(rel, "/")
+		if rel == "" {
+			continue
+		}
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("bundle entry %q is outside the bundle", header.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to extract bundle: %w", err)
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target); err != nil {
+				return fmt.Errorf("failed to extract bundle: %w", err)
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// AnalyzeOptions controls how a bundle is analyzed
+type AnalyzeOptions struct {
+	// Rules re-classify the collected logs; nil keeps the events recorded in the bundle
+	Rules *RuleSet
+	// Multiline groups the collected logs before they are re-classified
+	Multiline []*Multiline
+	// Location is the time zone of log timestamps without one; nil means UTC
+	Location *time.Location
+}
+
+// AnalyzeOptionsFromConfig re-classifies with the rules and multi-line grouping of cfg
+func AnalyzeOptionsFromConfig(cfg *config.Config) (AnalyzeOptions, error) {
+	rules, err := rulesFor(cfg)
+	if err != nil {
+		return AnalyzeOptions{}, fmt.Errorf("invalid error rules: %w", err)
+	}
+	multiline, err := CompileMultiline(cfg.Symptom.Multiline)
+	if err != nil {
+		return AnalyzeOptions{}, fmt.Errorf("invalid multiline configuration: %w", err)
+	}
+	return AnalyzeOptions{Rules: rules, Multiline: multiline}, nil
+}
+
+// Analysis is the triage report of a bundle
+type Analysis struct {
+	Namespace string                 `json:"namespace"`
+	Cluster   kubernetes.ClusterInfo `json:"cluster"`
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
+	// Reclassified is set when the logs were classified again with new rules
+	Reclassified bool `json:"reclassified"`
+	// Groups counts the events by rule and pod, most severe first
+	Groups []EventGroup `json:"groups"`
+	// Timeline is every event across all sources in time order
+	Timeline []ErrorEvent `json:"timeline"`
+	Cores    []ErrorEvent `json:"cores"`
+	Tests    []TestReport `json:"tests,omitempty"`
+	// Captures summarises the packet captures of each pod
+	Captures []CaptureSummary `json:"captures,omitempty"`
+}
+
+// CaptureSummary is the protocol summary of the packet capture of one pod
+type CaptureSummary struct {
+	Pod string `json:"pod"`
+	// Files are the capture files in the bundle
+	Files   []string      `json:"files"`
+	Summary *pcap.Summary `json:"summary,omitempty"`
+	// Error is why the files could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// EventGroup is the events of one rule in one pod
+type EventGroup struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Pod      string   `json:"pod"`
+	// Events is the number of distinct events; Count includes their repeats
+	Events    int       `json:"events"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Sample is the first line of the earliest event
+	Sample string `json:"sample"`
+}
+
+// TestReport is a pybot test case with the events that occurred while it ran
+type TestReport struct {
+	Name      string       `json:"name"`
+	Status    robot.Status `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	StartTime time.Time    `json:"start_time"`
+	EndTime   time.Time    `json:"end_time"`
+	// Rules counts the events of the test by rule, including repeats
+	Rules map[string]int `json:"rules,omitempty"`
+}
+
+// Events returns the number of events that occurred during the test
+func (t TestReport) Events() int {
+	total := 0
+	for _, count := range t.Rules {
+		total += count
+	}
+	return total
+}
+
+// Analyze builds the triage report of a bundle. With rules set, the collected
+// log files are classified again, so newer rules can be validated against old
+// bundles; core, Kubernetes event and restart events are kept as recorded.
+// Failed HTTP/2 responses and Diameter answers in the packet captures join
+// the events on the timeline.
+func (b *Bundle) Analyze(opts AnalyzeOptions) (*Analysis, error) {
+	events := b.Manifest.Events
+	if opts.Rules != nil {
+		var err error
+		if events, err = b.reclassify(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	captures := b.summarizeCaptures()
+	if len(captures) > 0 {
+		events = append([]ErrorEvent(nil), events...)
+		for _, capture := range captures {
+			events = append(events, packetEvents(capture)...)
+		}
+	}
+	analysis := analyzeEvents(b.Manifest, events, opts.Rules != nil)
+	analysis.Captures = captures
+	return analysis, nil
+}
+
+// summarizeCaptures decodes the capture files of each pod as one capture
+func (b *Bundle) summarizeCaptures() []CaptureSummary {
+	var captures []CaptureSummary
+	byPod := make(map[string]int)
+	for _, artifact := range b.Manifest.Artifacts {
+		if artifact.Kind != "pcap" {
+			continue
+		}
+		i, ok := byPod[artifact.Pod]
+		if !ok {
+			i = len(captures)
+			byPod[artifact.Pod] = i
+			captures = append(captures, CaptureSummary{Pod: artifact.Pod})
+		}
+		captures[i].Files = append(captures[i].Files, artifact.Path)
+	}
+
+	for i, capture := range captures {
+		paths := make([]string, len(capture.Files))
+		for j, file := range capture.Files {
+			paths[j] = filepath.Join(b.Dir, filepath.FromSlash(file))
+		}
+		summary, err := pcap.SummarizeFiles(paths...)
+		if err != nil {
+			// Bundles extract to a temporary directory, so name files by their bundle path
+			captures[i].Error = strings.ReplaceAll(err.Error(), b.Dir+string(filepath.Separator), "")
+			continue
+		}
+		captures[i].Summary = summary
+	}
+	return captures
+}
+
+// packetEvents reports the 4xx and 5xx HTTP/2 responses and the failed
+// Diameter answers of a capture, folding repeats
+func packetEvents(capture CaptureSummary) []ErrorEvent {
+	if capture.Summary == nil {
+		return nil
+	}
+	var folder eventFolder
+	for _, stream := range capture.Summary.HTTP2 {
+		if !stream.Failed() {
+			continue
+		}
+		severity := SeverityWarning
+		if stream.Status >= 500 {
+			severity = SeverityError
+		}
+		request := stream.Method + " " + stream.Path
+		if stream.Method == "" {
+			request = fmt.Sprintf("stream %d", stream.StreamID)
+		}
+		folder.add(ErrorEvent{
+			Timestamp: stream.ResponseTime,
+			Kind:      EventPacket,
+			Pod:       capture.Pod,
+			Source:    pcap.ApplicationHTTP2,
+			Message:   fmt.Sprintf("%s -> %d %s", request, stream.Status, http.StatusText(stream.Status)),
+			Rule:      RuleHTTP2Error,
+			Severity:  severity,
+			Groups: map[string]string{
+				"method":    stream.Method,
+				"path":      stream.Path,
+				"authority": stream.Authority,
+				"status":    strconv.Itoa(stream.Status),
+				"client":    stream.Client.String(),
+				"server":    stream.Server.String(),
+			},
+		})
+	}
+	for _, msg := range capture.Summary.Diameter {
+		if msg.Request || !msg.Failed() {
+			continue
+		}
+		message := fmt.Sprintf("%s %s", msg.Name(), msg.Result())
+		if msg.OriginHost != "" {
+			message += " from " + msg.OriginHost
+		}
+		folder.add(ErrorEvent{
+			Timestamp: msg.Time,
+			Kind:      EventPacket,
+			Pod:       capture.Pod,
+			Source:    pcap.ApplicationDiameter,
+			Message:   message,
+			Rule:      RuleDiameterError,
+			Severity:  SeverityError,
+			Groups: map[string]string{
+				"command":     msg.RequestName(),
+				"result":      msg.Result(),
+				"origin_host": msg.OriginHost,
+				"session_id":  msg.SessionID,
+				"src":         msg.Src.String(),
+				"dst":         msg.Dst.String(),
+			},
+		})
+	}
+	return folder.events()
+}
+
+// eventFolder folds the repeats of an event into its first occurrence, as
+// the collection pipeline does
+type eventFolder struct {
+	byPrint map[string]*ErrorEvent
+	folded  []*ErrorEvent
+}
+
+func (f *eventFolder) add(event ErrorEvent) {
+	event.Count = 1
+	event.FirstSeen = event.Timestamp
+	event.LastSeen = event.Timestamp
+	event.Fingerprint = fingerprint(event)
+	if first, ok := f.byPrint[event.Fingerprint]; ok {
+		first.Count++
+		if event.Timestamp.Before(first.FirstSeen) {
+			first.FirstSeen = event.Timestamp
+			first.Timestamp = event.Timestamp
+		}
+		if event.Timestamp.After(first.LastSeen) {
+			first.LastSeen = event.Timestamp
+		}
+		return
+	}
+	if f.byPrint == nil {
+		f.byPrint = make(map[string]*ErrorEvent)
+	}
+	f.byPrint[event.Fingerprint] = &event
+	f.folded = append(f.folded, &event)
+}
+
+// events returns the folded events in the order they were first added
+func (f *eventFolder) events() []ErrorEvent {
+	events := make([]ErrorEvent, 0, len(f.folded))
+	for _, event := range f.folded {
+		events = append(events, *event)
+	}
+	return events
+}
+
+// analyzeEvents builds the triage report of the events of the run described by manifest
+func analyzeEvents(manifest *Manifest, events []ErrorEvent, reclassified bool) *Analysis {
+	var tests []ManifestTest
+	if manifest.Pybot != nil {
+		tests = manifest.Pybot.Tests
+	}
+	timeline := make([]ErrorEvent, len(events))
+	for i, event := range events {
+		event.TestCase = ""
+		for _, test := range tests {
+			if !test.StartTime.IsZero() && !event.Timestamp.Before(test.StartTime) && event.Timestamp.Before(test.EndTime) {
+				event.TestCase = test.Name
+				break
+			}
+		}
+		timeline[i] = event
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Timestamp.Before(timeline[j].Timestamp)
+	})
+
+	analysis := &Analysis{
+		Namespace:    manifest.Namespace,
+		Cluster:      manifest.Cluster,
+		StartTime:    manifest.StartTime,
+		EndTime:      manifest.EndTime,
+		Reclassified: reclassified,
+		Groups:       groupEvents(timeline),
+		Timeline:     timeline,
+	}
+	for _, event := range timeline {
+		if event.Kind == EventCore {
+			analysis.Cores = append(analysis.Cores, event)
+		}
+	}
+	for _, test := range tests {
+		report := TestReport{
+			Name:      test.Name,
+			Status:    test.Status,
+			Message:   test.Message,
+			StartTime: test.StartTime,
+			EndTime:   test.EndTime,
+		}
+		for _, event := range timeline {
+			if event.TestCase != test.Name {
+				continue
+			}
+			if report.Rules == nil {
+				report.Rules = make(map[string]int)
+			}
+			report.Rules[eventRule(event)] += max(event.Count, 1)
+		}
+		analysis.Tests = append(analysis.Tests, report)
+	}
+	return analysis
+}
+
+// reclassify matches the collected log files against new rules. Log events
+// of sources whose file is not in the bundle are kept as recorded.
+func (b *Bundle) reclassify(opts AnalyzeOptions) ([]ErrorEvent, error) {
+	// Lines without a timestamp of their own take the time the collection saw them
+	seen := make(map[string]time.Time)
+	for _, event := range b.Manifest.Events {
+		if event.Kind == EventLog {
+			key := logKey(event.Pod, event.Source, event.Message)
+			if _, ok := seen[key]; !ok {
+				seen[key] = event.FirstSeen
+			}
+		}
+	}
+
+	var folder eventFolder
+
+	scanned := make(map[string]bool)
+	for _, artifact := range b.Manifest.Artifacts {
+		if artifact.Kind != "log" && artifact.Kind != "podlog" {
+			continue
+		}
+		if err := b.scanLog(artifact, opts, seen, folder.add); err != nil {
+			return nil, err
+		}
+		scanned[artifact.Pod+":"+artifact.Source] = true
+	}
+
+	var result []ErrorEvent
+	for _, event := range b.Manifest.Events {
+		if event.Kind != EventLog || !scanned[event.Pod+":"+event.Source] {
+			result = append(result, event)
+		}
+	}
+	return append(result, folder.events()...), nil
+}
+
+// scanLog classifies the lines of a collected log file
+func (b *Bundle) scanLog(artifact Artifact, opts AnalyzeOptions, seen map[string]time.Time, add func(ErrorEvent)) error {
+	file, err := os.Open(filepath.Join(b.Dir, filepath.FromSlash(artifact.Path)))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", artifact.Path, err)
+	}
+	defer file.Close()
+
+	grouper := newLineGrouper(offlineMultiline(multilineFor(opts.Multiline, artifact.Source)), func(ts time.Time, block string) {
+		event := opts.Rules.classify(artifact.Source, ts, block)
+		if event == nil {
+			return
+		}
+		event.Pod = artifact.Pod
+		event.Container = artifact.Container
+		if event.Timestamp.IsZero() {
+			event.Timestamp = b.Manifest.StartTime
+			if first, ok := seen[logKey(event.Pod, event.Source, event.Message)]; ok {
+				event.Timestamp = first
+			}
+		}
+		add(*event)
+	})
+
+	// Lines without a timestamp belong to the last line that had one
+	var last time.Time
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ts, ok := lineTimestamp(line, opts.Location); ok {
+			last = ts
+		}
+		grouper.addAt(last, line)
+	}
+	grouper.close()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", artifact.Path, err)
+	}
+	return nil
+}
+
+// offlineMultiline returns a copy of a grouping for reading a saved file, where
+// blocks end only at the next record since reading never pauses
+func offlineMultiline(multiline *Multiline) *Multiline {
+	if multiline == nil {
+		return nil
+	}
+	copied := *multiline
+	copied.FlushTimeout = math.MaxInt64
+	return &copied
+}
+
+// lineTimestamp parses the timestamp leading a log line. Timestamps without a
+// zone are read in loc; nil means UTC.
+func lineTimestamp(line string, loc *time.Location) (time.Time, bool) {
+	match := lineTimestampPattern.FindStringSubmatch(line)
+	if match == nil {
+		return time.Time{}, false
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	value := strings.NewReplacer("/", "-", ",", ".", "T", " ").Replace(match[1])
+	layout := "2006-01-02 15:04:05"
+	if zone := match[2]; zone != "" {
+		if len(zone) == 5 {
+			zone = zone[:3] + ":" + zone[3:]
+		}
+		value += zone
+		layout += "Z07:00"
+	}
+	ts, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// logKey identifies a log message regardless of its volatile values
+func logKey(pod, source, message string) string {
+	return pod + "\x00" + source + "\x00" + normalizeMessage(message)
+}
+
+// eventRule names the rule of an event; events recorded before rules existed have none
+func eventRule(event ErrorEvent) string {
+	if event.Rule != "" {
+		return event.Rule
+	}
+	return string(event.Kind)
+}
+
+// groupEvents counts time ordered events by rule and pod, most severe and most
+// frequent first
+func groupEvents(events []ErrorEvent) []EventGroup {
+	var groups []*EventGroup
+	byKey := make(map[string]*EventGroup)
+	for _, event := range events {
+		rule := eventRule(event)
+		key := rule + "\x00" + event.Pod
+		group, ok := byKey[key]
+		if !ok {
+			severity := event.Severity
+			if severity == "" {
+				severity = SeverityError
+			}
+			sample, _, _ := strings.Cut(event.Message, "\n")
+			group = &EventGroup{
+				Rule:      rule,
+				Severity:  severity,
+				Pod:       event.Pod,
+				FirstSeen: event.Timestamp,
+				Sample:    sample,
+			}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.Events++
+		group.Count += max(event.Count, 1)
+		lastSeen := event.LastSeen
+		if lastSeen.IsZero() {
+			lastSeen = event.Timestamp
+		}
+		if lastSeen.After(group.LastSeen) {
+			group.LastSeen = lastSeen
+		}
+	}
+
+	sorted := make([]EventGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Severity.Level() != b.Severity.Level() {
+			return a.Severity.Level() > b.Severity.Level()
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Pod < b.Pod
+	})
+	return sorted
+}
+
+// WriteJSON writes the analysis as indented JSON
+func (a *Analysis) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(a); err != nil {
+		return fmt.Errorf("failed to encode analysis: %w", err)
+	}
+	return nil
+}
+
+// WriteText writes the analysis as a human-readable triage report
+func (a *Analysis) WriteText(w io.Writer) error {
+	var b strings.Builder
+	distinct, total := len(a.Timeline), 0
+	for _, event := range a.Timeline {
+		total += max(event.Count, 1)
+	}
+
+	fmt.Fprintf(&b, "Namespace: %s", a.Namespace)
+	if a.Cluster.Context != "" {
+		fmt.Fprintf(&b, " (context %s)", a.Cluster.Context)
+	}
+	fmt.Fprintf(&b, "\nCollected: %s to %s (%s)\n",
+		a.StartTime.Format(time.DateTime), a.EndTime.Format(time.DateTime), a.EndTime.Sub(a.StartTime).Round(time.Second))
+	fmt.Fprintf(&b, "Events:    %d distinct, %d total", distinct, total)
+	if a.Reclassified {
+		b.WriteString(", logs reclassified with the given rules")
+	}
+	b.WriteString("\n")
+
+	if len(a.Groups) > 0 {
+		b.WriteString("\nEvents by rule and pod:\n\n")
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SEVERITY\tRULE\tPOD\tEVENTS\tCOUNT\tFIRST SEEN\tLAST SEEN\tSAMPLE")
+		for _, group := range a.Groups {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+				strings.ToUpper(string(group.Severity)), group.Rule, group.Pod, group.Events, group.Count,
+				group.FirstSeen.Format(time.TimeOnly), group.LastSeen.Format(time.TimeOnly), truncate(group.Sample, 80))
+		}
+		tw.Flush()
+	}
+
+	if len(a.Cores) > 0 {
+		b.WriteString("\nCores:\n\n")
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tPOD\tPROCESS\tPID\tSIGNAL\tPATH\tTEST CASE")
+		for _, event := range a.Cores {
+			core := event.Core
+			if core == nil {
+				core = &CoreInfo{Path: event.Source}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+				event.Timestamp.Format(time.DateTime), event.Pod, core.Process, core.PID, core.SignalName(), core.Path, event.TestCase)
+		}
+		tw.Flush()
+	}
+
+	if len(a.Tests) > 0 {
+		b.WriteString("\nTest cases:\n\n")
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEST\tSTATUS\tEVENTS\tRULES")
+		for _, test := range a.Tests {
+			rules := make([]string, 0, len(test.Rules))
+			for rule, count := range test.Rules {
+				rules = append(rules, fmt.Sprintf("%s x%d", rule, count))
+			}
+			sort.Strings(rules)
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", test.Name, test.Status, test.Events(), strings.Join(rules, ", "))
+		}
+		tw.Flush()
+	}
+
+	if len(a.Captures) > 0 {
+		b.WriteString("\nPacket captures:\n\n")
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "POD\tFILES\tPACKETS\tFLOWS\tHTTP/2 STREAMS\tHTTP/2 FAILED\tDIAMETER\tDIAMETER FAILED\tSCTP\tNOTES")
+		for _, capture := range a.Captures {
+			summary := capture.Summary
+			if summary == nil {
+				fmt.Fprintf(tw, "%s\t%d\t-\t-\t-\t-\t-\t-\t-\t%s\n", capture.Pod, len(capture.Files), capture.Error)
+				continue
+			}
+			httpFailed, diameterFailed := 0, 0
+			for _, stream := range summary.HTTP2 {
+				if stream.Failed() {
+					httpFailed++
+				}
+			}
+			for _, msg := range summary.Diameter {
+				if !msg.Request && msg.Failed() {
+					diameterFailed++
+				}
+			}
+			states := make([]string, 0, len(summary.SCTP))
+			for _, assoc := range summary.SCTP {
+				state := assoc.State
+				if state == "" {
+					state = "data"
+				}
+				states = append(states, state)
+			}
+			var notes []string
+			if summary.Truncated {
+				notes = append(notes, "truncated")
+			}
+			if summary.LostStreams > 0 {
+				notes = append(notes, fmt.Sprintf("%d lost TCP streams", summary.LostStreams))
+			}
+			if summary.HPACKErrors > 0 {
+				notes = append(notes, fmt.Sprintf("%d HPACK errors", summary.HPACKErrors))
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+				capture.Pod, len(capture.Files), summary.Packets, len(summary.Flows), len(summary.HTTP2), httpFailed,
+				len(summary.Diameter), diameterFailed, strings.Join(states, ","), strings.Join(notes, ", "))
+		}
+		tw.Flush()
+	}
+
+	if len(a.Timeline) > 0 {
+		b.WriteString("\nTimeline:\n\n")
+		for _, event := range a.Timeline {
+			formatEvent(&b, event)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,337 @@
+package symptom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/pcap"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+)
+
+func TestLineTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "space separated", line: "2024-03-01 12:00:05 ERROR reset", want: time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC), wantOK: true},
+		{name: "milliseconds with comma", line: "2024-03-01 12:00:05,250 ERROR reset", want: time.Date(2024, 3, 1, 12, 0, 5, 250e6, time.UTC), wantOK: true},
+		{name: "bracketed with slashes", line: "[2024/03/01 12:00:05] ERROR reset", want: time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC), wantOK: true},
+		{name: "RFC 3339", line: "2024-03-01T12:00:05.5Z ERROR reset", want: time.Date(2024, 3, 1, 12, 0, 5, 5e8, time.UTC), wantOK: true},
+		{name: "offset without colon", line: "2024-03-01T14:00:05+0200 ERROR reset", want: time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC), wantOK: true},
+		{name: "no timestamp", line: "    at handler.c:42", wantOK: false},
+		{name: "time only", line: "12:00:05 ERROR reset", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lineTimestamp(tt.line, nil)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("lineTimestamp(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// writeTestBundle writes a bundle with an Envoy log, a core and two pybot test
+// cases, returning the archive path and the run directory
+func writeTestBundle(t *testing.T) (string, string) {
+	t.Helper()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	runDir := filepath.Join(t.TempDir(), "miniudm-20240301-120000")
+
+	log := strings.Join([]string{
+		"2024-03-01 12:00:05 INFO started",
+		"2024-03-01 12:00:10 ERROR upstream reset id=1",
+		"2024-03-01 12:00:20 WARN retry budget low",
+		"2024-03-01 12:01:10 ERROR upstream reset id=2",
+		"2024-03-01 12:01:15 PANIC handler crashed",
+		"    at handler.c:42",
+		"    at main.c:7",
+		"2024-03-01 12:01:16 INFO restarted",
+	}, "\n") + "\n"
+	logPath := filepath.Join(runDir, "logs", "uecm-a", "Envoy.log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logPath, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		Namespace: "miniudm",
+		StartTime: start,
+		EndTime:   start.Add(2 * time.Minute),
+		Events: []ErrorEvent{
+			{Timestamp: start.Add(10 * time.Second), Kind: EventLog, Pod: "uecm-a", Source: "/Envoy", Message: "2024-03-01 12:00:10 ERROR upstream reset id=1", Rule: "error", Severity: SeverityError, Count: 2},
+			{Timestamp: start.Add(75 * time.Second), Kind: EventCore, Pod: "uecm-a", Source: "/logstore/TspCore", Message: "core dumped", Rule: RuleCoreDump, Severity: SeverityCritical,
+				Core: &CoreInfo{Pod: "uecm-a", Path: "/logstore/TspCore/core.uecm.42", Process: "uecm", PID: 42, Signal: 11}},
+		},
+		Pybot: &ManifestPybot{Tests: []ManifestTest{
+			{Name: "Suite.TC_01", Status: robot.StatusPass, StartTime: start, EndTime: start.Add(time.Minute)},
+			{Name: "Suite.TC_02", Status: robot.StatusFail, StartTime: start.Add(time.Minute), EndTime: start.Add(2 * time.Minute)},
+		}},
+	}
+	artifacts, err := manifestArtifacts(runDir, []Artifact{{Kind: "log", Pod: "uecm-a", Container: "mcc", Source: "/Envoy", Path: logPath}})
+	if err != nil {
+		t.Fatalf("manifestArtifacts() error = %v", err)
+	}
+	manifest.Artifacts = artifacts
+
+	bundle := filepath.Join(t.TempDir(), "miniudm.tar.gz")
+	if err := archiveBundle(runDir, bundle, manifest); err != nil {
+		t.Fatalf("archiveBundle() error = %v", err)
+	}
+	return bundle, runDir
+}
+
+func TestAnalyzeRecordedEvents(t *testing.T) {
+	_, runDir := writeTestBundle(t)
+
+	bundle, err := OpenBundle(runDir)
+	if err != nil {
+		t.Fatalf("OpenBundle() error = %v", err)
+	}
+	defer bundle.Close()
+
+	analysis, err := bundle.Analyze(AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if analysis.Reclassified || len(analysis.Timeline) != 2 {
+		t.Fatalf("Analyze() timeline = %+v, want the 2 recorded events", analysis.Timeline)
+	}
+	if got := analysis.Groups[0]; got.Rule != RuleCoreDump || got.Severity != SeverityCritical {
+		t.Errorf("Analyze() first group = %+v, want the critical core group", got)
+	}
+	if got := analysis.Groups[1]; got.Rule != "error" || got.Events != 1 || got.Count != 2 {
+		t.Errorf("Analyze() error group = %+v, want 1 event seen twice", got)
+	}
+	if len(analysis.Cores) != 1 || analysis.Cores[0].TestCase != "Suite.TC_02" {
+		t.Errorf("Analyze() cores = %+v, want the core during Suite.TC_02", analysis.Cores)
+	}
+	if got := analysis.Tests[0].Rules; got["error"] != 2 {
+		t.Errorf("Analyze() Suite.TC_01 rules = %v, want error x2", got)
+	}
+	if got := analysis.Tests[1].Rules; got[RuleCoreDump] != 1 {
+		t.Errorf("Analyze() Suite.TC_02 rules = %v, want core-dump x1", got)
+	}
+
+	var text bytes.Buffer
+	if err := analysis.WriteText(&text); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{"Events:    2 distinct, 3 total", "SIGSEGV", "Suite.TC_02  FAIL", "test case: Suite.TC_01"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("WriteText() = %q, want it to contain %q", text.String(), want)
+		}
+	}
+}
+
+func TestAnalyzeReclassify(t *testing.T) {
+	archive, _ := writeTestBundle(t)
+
+	bundle, err := OpenBundle(archive)
+	if err != nil {
+		t.Fatalf("OpenBundle() error = %v", err)
+	}
+	opts, err := AnalyzeOptionsFromConfig(&config.Config{Symptom: config.SymptomConfig{
+		Rules: []config.RuleConfig{
+			{Name: "panic", Pattern: `PANIC`, Severity: "critical"},
+			{Name: "upstream-reset", Pattern: `upstream reset`},
+			{Name: "retry-budget", Pattern: `retry budget`, Severity: "warning"},
+		},
+		Multiline: []config.MultilineConfig{{Start: `^\d{4}-`}},
+	}})
+	if err != nil {
+		t.Fatalf("AnalyzeOptionsFromConfig() error = %v", err)
+	}
+
+	analysis, err := bundle.Analyze(opts)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if err := bundle.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(bundle.Dir); !os.IsNotExist(err) {
+		t.Errorf("Close() left the extracted bundle at %s", bundle.Dir)
+	}
+
+	var rules []string
+	for _, group := range analysis.Groups {
+		rules = append(rules, group.Rule)
+	}
+	want := []string{RuleCoreDump, "panic", "upstream-reset", "retry-budget"}
+	if strings.Join(rules, ",") != strings.Join(want, ",") {
+		t.Fatalf("Analyze() groups = %v, want %v", rules, want)
+	}
+	reset := analysis.Groups[2]
+	if reset.Events != 1 || reset.Count != 2 || reset.FirstSeen.Second() != 10 || reset.LastSeen.Minute() != 1 {
+		t.Errorf("Analyze() upstream-reset group = %+v, want both resets folded from 12:00:10 to 12:01:10", reset)
+	}
+
+	var panicEvent ErrorEvent
+	for _, event := range analysis.Timeline {
+		if event.Rule == "panic" {
+			panicEvent = event
+		}
+	}
+	if !strings.HasSuffix(panicEvent.Message, "at main.c:7") || panicEvent.Container != "mcc" || panicEvent.TestCase != "Suite.TC_02" {
+		t.Errorf("Analyze() panic event = %+v, want the grouped backtrace during Suite.TC_02", panicEvent)
+	}
+
+	var decoded Analysis
+	var out bytes.Buffer
+	if err := analysis.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || !decoded.Reclassified || len(decoded.Timeline) != len(analysis.Timeline) {
+		t.Errorf("WriteJSON() = %s, want the reclassified analysis", out.String())
+	}
+}
+
+// diameterCapture writes a pcap file of a ULR answered with DIAMETER_ERROR_USER_UNKNOWN
+func diameterCapture(t *testing.T, path string, ts time.Time) {
+	t.Helper()
+	avp := func(code uint32, data []byte) []byte {
+		a := binary.BigEndian.AppendUint32(nil, code)
+		a = binary.BigEndian.AppendUint32(a, uint32(8+len(data)))
+		a[4] = 0x40
+		a = append(a, data...)
+		for len(a)%4 != 0 {
+			a = append(a, 0)
+		}
+		return a
+	}
+	message := func(request bool, avps ...[]byte) []byte {
+		body := bytes.Join(avps, nil)
+		msg := binary.BigEndian.AppendUint32(nil, uint32(20+len(body)))
+		msg[0] = 1
+		msg = binary.BigEndian.AppendUint32(msg, 316)
+		if request {
+			msg[4] = 0x80
+		}
+		msg = binary.BigEndian.AppendUint32(msg, 16777251)
+		msg = binary.BigEndian.AppendUint32(msg, 7)
+		msg = binary.BigEndian.AppendUint32(msg, 7)
+		return append(msg, body...)
+	}
+	// packet is a raw IPv4 TCP segment with a pcap record header
+	packet := func(ts time.Time, src, dst [4]byte, srcPort, dstPort uint16, payload []byte) []byte {
+		tcp := make([]byte, 20)
+		binary.BigEndian.PutUint16(tcp[0:2], srcPort)
+		binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+		binary.BigEndian.PutUint32(tcp[4:8], 1)
+		tcp[12], tcp[13] = 5<<4, 0x18
+		ip := make([]byte, 20)
+		ip[0], ip[8], ip[9] = 0x45, 64, 6
+		binary.BigEndian.PutUint16(ip[2:4], uint16(40+len(payload)))
+		copy(ip[12:16], src[:])
+		copy(ip[16:20], dst[:])
+		data := append(append(ip, tcp...), payload...)
+
+		record := binary.LittleEndian.AppendUint32(nil, uint32(ts.Unix()))
+		record = binary.LittleEndian.AppendUint32(record, uint32(ts.Nanosecond()/1000))
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(data)))
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(data)))
+		return append(record, data...)
+	}
+
+	file := binary.LittleEndian.AppendUint32(nil, 0xa1b2c3d4)
+	file = binary.LittleEndian.AppendUint16(file, 2)
+	file = binary.LittleEndian.AppendUint16(file, 4)
+	file = append(file, make([]byte, 8)...)
+	file = binary.LittleEndian.AppendUint32(file, 65535)
+	file = binary.LittleEndian.AppendUint32(file, 101)
+	client, server := [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+	file = append(file, packet(ts, client, server, 40000, 3868, message(true, avp(263, []byte("s1"))))...)
+	experimental := avp(297, append(avp(266, binary.BigEndian.AppendUint32(nil, 10415)), avp(298, binary.BigEndian.AppendUint32(nil, 5001))...))
+	file = append(file, packet(ts.Add(20*time.Millisecond), server, client, 3868, 40000, message(false, avp(263, []byte("s1")), avp(264, []byte("hss1")), experimental))...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyzeCaptures(t *testing.T) {
+	_, runDir := writeTestBundle(t)
+	bundle, err := OpenBundle(runDir)
+	if err != nil {
+		t.Fatalf("OpenBundle() error = %v", err)
+	}
+	defer bundle.Close()
+
+	ts := bundle.Manifest.StartTime.Add(30 * time.Second)
+	diameterCapture(t, filepath.Join(runDir, "pcap", "uecm-a", "capture.pcap"), ts)
+	if err := os.WriteFile(filepath.Join(runDir, "pcap", "uecm-b.pcap"), []byte("this is not a packet capture file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recorded := bundle.Manifest.Events
+	bundle.Manifest.Artifacts = append(bundle.Manifest.Artifacts,
+		Artifact{Kind: "pcap", Pod: "uecm-a", Path: "pcap/uecm-a/capture.pcap"},
+		Artifact{Kind: "pcap", Pod: "uecm-b", Path: "pcap/uecm-b.pcap"})
+
+	analysis, err := bundle.Analyze(AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(bundle.Manifest.Events) != len(recorded) {
+		t.Errorf("Analyze() changed the manifest events to %+v", bundle.Manifest.Events)
+	}
+	if len(analysis.Captures) != 2 || analysis.Captures[0].Summary == nil || analysis.Captures[1].Error == "" {
+		t.Fatalf("Analyze() captures = %+v, want a summary for uecm-a and an error for uecm-b", analysis.Captures)
+	}
+	if len(analysis.Timeline) != 3 {
+		t.Fatalf("Analyze() timeline = %+v, want the packet event between the recorded ones", analysis.Timeline)
+	}
+	got := analysis.Timeline[1]
+	if got.Kind != EventPacket || got.Rule != RuleDiameterError || got.Groups["result"] != "DIAMETER_ERROR_USER_UNKNOWN (5001)" || !got.Timestamp.Equal(ts.Add(20*time.Millisecond)) {
+		t.Errorf("Analyze() packet event = %+v, want the failed ULA", got)
+	}
+
+	var text bytes.Buffer
+	if err := analysis.WriteText(&text); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{"Packet captures:", "ULA DIAMETER_ERROR_USER_UNKNOWN (5001) from hss1", "failed to read pcap/uecm-b.pcap: not a pcap or pcapng capture"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("WriteText() = %q, want it to contain %q", text.String(), want)
+		}
+	}
+}
+
+func TestPacketEvents(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	stream := func(status int, at time.Duration) pcap.HTTP2Stream {
+		return pcap.HTTP2Stream{StreamID: 1, Method: "PUT", Path: "/nudm-uecm/v1/imsi-1/registrations/amf-3gpp-access",
+			Status: status, RequestTime: ts, ResponseTime: ts.Add(at)}
+	}
+	capture := CaptureSummary{Pod: "uecm-a", Summary: &pcap.Summary{HTTP2: []pcap.HTTP2Stream{
+		stream(201, time.Second), stream(503, 2*time.Second), stream(503, 3*time.Second), stream(404, 4*time.Second),
+	}}}
+
+	events := packetEvents(capture)
+	if len(events) != 2 {
+		t.Fatalf("packetEvents() = %+v, want the 503s folded and the 404", events)
+	}
+	if got := events[0]; got.Severity != SeverityError || got.Count != 2 || !got.LastSeen.Equal(ts.Add(3*time.Second)) ||
+		got.Message != "PUT /nudm-uecm/v1/imsi-1/registrations/amf-3gpp-access -> 503 Service Unavailable" {
+		t.Errorf("packetEvents() 503 = %+v", got)
+	}
+	if got := events[1]; got.Severity != SeverityWarning || got.Groups["status"] != "404" {
+		t.Errorf("packetEvents() 404 = %+v", got)
+	}
+}
@@ -0,0 +1,416 @@
+package symptom
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Bundle layout
+const (
+	manifestName        = "manifest.json"
+	manifestVersion     = 1
+	defaultBundleName   = "{namespace}-{timestamp}.tar.gz"
+	bundleTimestamp     = "20060102-150405"
+	redactedHeaderValue = "<redacted>"
+	// redactedURLValue replaces URL credentials and query values, where the
+	// brackets of redactedHeaderValue would be escaped
+	redactedURLValue = "redacted"
+)
+
+// Manifest describes the contents of a symptom bundle. It is stored as
+// manifest.json at the root of the bundle.
+type Manifest struct {
+	Version   int                    `json:"version"`
+	Cluster   kubernetes.ClusterInfo `json:"cluster"`
+	Namespace string                 `json:"namespace"`
+	Pods      []string               `json:"pods"`
+	Targets   []TargetPod            `json:"targets"`
+	// StartTime and EndTime bound the collection
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Config is the effective configuration with webhook headers redacted
+	Config    *config.Config  `json:"config"`
+	Pybot     *ManifestPybot  `json:"pybot,omitempty"`
+	Events    []ErrorEvent    `json:"events"`
+	Stats     EventStats      `json:"stats"`
+	Sinks     []SinkStats     `json:"sinks,omitempty"`
+	Captures  []Capture       `json:"captures,omitempty"`
+	Traces    []Trace         `json:"traces,omitempty"`
+	Totals    EventTotals     `json:"totals"`
+	Routines  []RoutineResult `json:"routines,omitempty"`
+	Artifacts []Artifact      `json:"artifacts"`
+}
+
+// ManifestPybot is the pybot run recorded in a manifest
+type ManifestPybot struct {
+	Pod         string         `json:"pod"`
+	Command     []string       `json:"command"`
+	StartTime   time.Time      `json:"start_time"`
+	EndTime     time.Time      `json:"end_time"`
+	Status      PybotStatus    `json:"status"`
+	ExitCode    int            `json:"exit_code"`
+	FailedTests int            `json:"failed_tests"`
+	Error       string         `json:"error,omitempty"`
+	Tests       []ManifestTest `json:"tests,omitempty"`
+}
+
+// ManifestTest is a pybot test case recorded in a manifest
+type ManifestTest struct {
+	Name      string       `json:"name"`
+	Status    robot.Status `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	StartTime time.Time    `json:"start_time"`
+	EndTime   time.Time    `json:"end_time"`
+	// Events counts the errors detected while the test ran
+	Events int `json:"events"`
+}
+
+// newManifest describes a finished collection. The artifacts are filled in
+// when the bundle is written.
+func newManifest(cluster kubernetes.ClusterInfo, cfg *config.Config, result *CollectionResult) *Manifest {
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		Cluster:   cluster,
+		Namespace: result.Namespace,
+		Pods:      result.Pods,
+		Targets:   result.Targets,
+		StartTime: result.StartTime,
+		EndTime:   result.EndTime,
+		Config:    redactConfig(cfg),
+		Events:    result.Events,
+		Stats:     result.Stats,
+		Sinks:     result.Sinks,
+		Captures:  result.Captures,
+		Traces:    result.Traces,
+		Totals:    result.Totals,
+		Routines:  result.Routines,
+	}
+
+	if pybot := result.Pybot; pybot != nil {
+		manifest.Pybot = &ManifestPybot{
+			Pod:         pybot.Pod,
+			Command:     pybot.Command,
+			StartTime:   pybot.StartTime,
+			EndTime:     pybot.EndTime,
+			Status:      pybot.Status,
+			ExitCode:    pybot.ExitCode,
+			FailedTests: pybot.FailedTests,
+			Error:       pybot.Error,
+		}
+		for _, summary := range result.TestSummaries() {
+			manifest.Pybot.Tests = append(manifest.Pybot.Tests, ManifestTest{
+				Name:      summary.Test.LongName(),
+				Status:    summary.Test.Status,
+				Message:   summary.Test.Message,
+				StartTime: summary.Test.Start,
+				EndTime:   summary.Test.End,
+				Events:    len(summary.Events),
+			})
+		}
+	}
+	return manifest
+}
+
+// redactConfig copies the configuration without webhook header values and
+// URL credentials and query values, which usually carry credentials
+func redactConfig(cfg *config.Config) *config.Config {
+	redacted := *cfg
+	redacted.Symptom.Sinks = make([]config.SinkConfig, len(cfg.Symptom.Sinks))
+	for i, sink := range cfg.Symptom.Sinks {
+		if sink.URL != "" {
+			sink.URL = redactURL(sink.URL)
+		}
+		if len(sink.Headers) > 0 {
+			headers := make(map[string]string, len(sink.Headers))
+			for name := range sink.Headers {
+				headers[name] = redactedHeaderValue
+			}
+			sink.Headers = headers
+		}
+		redacted.Symptom.Sinks[i] = sink
+	}
+	return &redacted
+}
+
+// redactURL replaces the user info and the query values of a URL, keeping the
+// query parameter names. A URL that does not parse is replaced as a whole.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedHeaderValue
+	}
+	if u.User != nil {
+		u.User = url.User(redactedURLValue)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			query[name] = []string{redactedURLValue}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// bundlePath returns where the bundle of a run is written
+func bundlePath(cfg *config.Config, result *CollectionResult) string {
+	dir := cfg.Symptom.Bundle.Dir
+	if dir == "" {
+		dir = cfg.Symptom.OutputDir
+	}
+	name := cfg.Symptom.Bundle.Name
+	if name == "" {
+		name = defaultBundleName
+	}
+	name = strings.NewReplacer(
+		"{namespace}", result.Namespace,
+		"{timestamp}", result.StartTime.Format(bundleTimestamp),
+	).Replace(name)
+	if !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") {
+		name += ".tar.gz"
+	}
+	return filepath.Join(dir, name)
+}
+
+// writeBundle records the run in manifest.json, writes the HTML and Markdown
+// reports and archives the run directory
+func (c *Collector) writeBundle(result *CollectionResult) error {
+	if err := os.MkdirAll(result.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	manifest := newManifest(c.k8sClient.Cluster(), c.config, result)
+	collected := result.Artifacts
+	report := NewReport(manifest, analyzeEvents(manifest, manifest.Events, false))
+	reports, err := report.writeReports(result.OutputDir)
+	if err != nil {
+		// The bundle is still worth having without its report
+		c.logger.Warn("Failed to write collection report", zap.Error(err))
+	}
+	for _, path := range reports {
+		collected = append(collected, Artifact{Kind: "report", Path: path})
+	}
+
+	artifacts, err := manifestArtifacts(result.OutputDir, collected)
+	if err != nil {
+		return err
+	}
+	manifest.Artifacts = artifacts
+
+	path := bundlePath(c.config, result)
+	if err := archiveBundle(result.OutputDir, path, manifest); err != nil {
+		return err
+	}
+	result.BundlePath = path
+	return nil
+}
+
+// manifestArtifacts lists every file in the run directory with its size and
+// SHA-256, using the watcher's description where one collected the file
+func manifestArtifacts(runDir string, collected []Artifact) ([]Artifact, error) {
+	byPath := make(map[string]Artifact, len(collected))
+	for _, artifact := range collected {
+		byPath[filepath.Clean(artifact.Path)] = artifact
+	}
+
+	var artifacts []Artifact
+	err := filepath.WalkDir(runDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(runDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == manifestName {
+			return nil
+		}
+
+		artifact, ok := byPath[filepath.Clean(path)]
+		if !ok {
+			artifact = Artifact{Kind: "file"}
+			if dir, _, nested := strings.Cut(filepath.ToSlash(rel), "/"); nested {
+				artifact.Kind = dir
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		// Hash again: files such as the events log may have grown since they were recorded
+		artifact.SHA256, err = utils.CalculateSHA256(path)
+		if err != nil {
+			return err
+		}
+		artifact.Size = info.Size()
+		artifact.Path = filepath.ToSlash(rel)
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list run directory: %w", err)
+	}
+	return artifacts, nil
+}
+
+// archiveBundle writes manifest.json into the run directory and archives the
+// directory as a .tar.gz whose entries are below the run directory's name
+func archiveBundle(runDir, bundle string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(runDir, manifestName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bundle), 0755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	// Write to a temporary name so a failed run never leaves a partial bundle
+	tmp := bundle + ".partial"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	if err := archiveDir(file, runDir); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tmp, bundle); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// archiveDir writes dir as a gzip compressed tar stream
+func archiveDir(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	root := filepath.Base(dir)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(root, rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.CopyN(tw, file, header.Size)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of a bundle, which may be a .tar.gz archive
+// or an extracted bundle directory
+func ReadManifest(path string) (*Manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	var data []byte
+	if info.IsDir() {
+		data, err = os.ReadFile(filepath.Join(path, manifestName))
+	} else {
+		data, err = readBundleFile(path, manifestName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// readBundleFile returns a file stored in a bundle archive, by its path
+// relative to the bundle root
+func readBundleFile(bundle, name string) ([]byte, error) {
+	file, err := os.Open(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in bundle", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if _, rel, ok := strings.Cut(header.Name, "/"); ok && rel == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
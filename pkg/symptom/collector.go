@@ -2,8 +2,10 @@ package symptom
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"path"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -15,26 +17,124 @@ import (
 // Collector handles symptom collection from Kubernetes pods
 type Collector struct {
 	config    *config.Config
-	k8sClient *kubernetes.Client
+	k8sClient kubernetes.ClusterClient
 	logger    *zap.Logger
+	// sinks receive events in addition to the configured sinks
+	sinks []EventSink
 }
 
 // SymptomCollectionConfig holds configuration for symptom collection
 type SymptomCollectionConfig struct {
 	Namespace string
 	Pods      []string
+	Targets   []kubernetes.ResolvedTarget
 	StartTime time.Time
 }
 
-// ErrorEvent represents an error detected during collection
+// TargetPod identifies a running pod container that symptoms are collected from
+type TargetPod struct {
+	Target     string `json:"target"`
+	Deployment string `json:"deployment"`
+	Name       string `json:"name"`
+	Container  string `json:"container"`
+}
+
+// CollectionResult describes what a symptom collection run produced
+type CollectionResult struct {
+	Namespace string
+	Pods      []string
+	Targets   []TargetPod
+	StartTime time.Time
+	EndTime   time.Time
+	// OutputDir is the local directory holding this run's files
+	OutputDir string
+	Pybot     *PybotResult
+	Events    []ErrorEvent
+	// Artifacts are the files collected by the watchers and packet captures
+	Artifacts []Artifact
+	// Captures reports the packet capture of each target pod
+	Captures []Capture
+	// Traces reports the tracing of each traced process
+	Traces []Trace
+	// Totals counts the events by severity, kind and source
+	Totals EventTotals
+	// Routines reports the outcome of each collection routine, with every
+	// failure that was only logged during the run
+	Routines []RoutineResult
+	// Stats counts the events received, folded, rate limited and dropped
+	Stats EventStats
+	// Sinks counts the events delivered to each event sink
+	Sinks []SinkStats
+	// BundlePath is the .tar.gz archive of the run directory, empty if it
+	// could not be written
+	BundlePath string
+}
+
+// Duration returns how long the collection ran
+func (r *CollectionResult) Duration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// Cores returns the core files detected during the run
+func (r *CollectionResult) Cores() []*CoreInfo {
+	var cores []*CoreInfo
+	for _, event := range r.Events {
+		if event.Core != nil {
+			cores = append(cores, event.Core)
+		}
+	}
+	return cores
+}
+
+// EventKind distinguishes the sources of error events
+type EventKind string
+
+// Event kinds
+const (
+	EventLog     EventKind = "log"
+	EventCore    EventKind = "core"
+	EventK8s     EventKind = "event"
+	EventRestart EventKind = "restart"
+	// EventPacket events are decoded from packet captures when a bundle is analyzed
+	EventPacket EventKind = "packet"
+)
+
+// watcherStopTimeout bounds how long stopping the watchers may take
+const watcherStopTimeout = 30 * time.Second
+
+// ErrorEvent represents an error detected during collection. The JSON form
+// is what the event sinks write.
 type ErrorEvent struct {
-	Timestamp time.Time
-	Source    string
-	Message   string
+	Timestamp time.Time `json:"timestamp"`
+	Kind      EventKind `json:"kind"`
+	Pod       string    `json:"pod,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+	// TestCase is the pybot test that was running when the error occurred
+	TestCase string `json:"test_case,omitempty"`
+	// Core is set for core events
+	Core *CoreInfo `json:"core,omitempty"`
+	// Rule is the name of the rule that classified the event
+	Rule     string   `json:"rule,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+	// Groups holds the capture groups of the rule's pattern
+	Groups map[string]string `json:"groups,omitempty"`
+	Tags   []string          `json:"tags,omitempty"`
+	// Fingerprint identifies repeats of the event, see fingerprint
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Count is the number of occurrences folded into the event, which was
+	// first seen at FirstSeen (the Timestamp) and last at LastSeen
+	Count     int       `json:"count,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Summary marks the record sinks receive when the collection ends for an
+	// event that repeated, with its final Count and LastSeen
+	Summary bool `json:"summary,omitempty"`
 }
 
 // NewCollector creates a new symptom collector
-func NewCollector(cfg *config.Config, k8sClient *kubernetes.Client, logger *zap.Logger) *Collector {
+func NewCollector(cfg *config.Config, k8sClient kubernetes.ClusterClient, logger *zap.Logger) *Collector {
 	return &Collector{
 		config:    cfg,
 		k8sClient: k8sClient,
@@ -42,6 +142,12 @@ func NewCollector(cfg *config.Config, k8sClient *kubernetes.Client, logger *zap.
 	}
 }
 
+// AddSink adds a sink receiving the events of every later collection in addition
+// to the configured sinks. The sink is closed at the end of each collection.
+func (c *Collector) AddSink(sink EventSink) {
+	c.sinks = append(c.sinks, sink)
+}
+
 // StartCollection starts the symptom collection process
 //
 // Command Syntax: symptom-collection -n <namespace> -p <"podnames">
@@ -113,7 +219,18 @@ func NewCollector(cfg *config.Config, k8sClient *kubernetes.Client, logger *zap.
 //	    Store the traces for analysis or symptom collection
 //
 // Copy all the information to local path (All traces, pcap, log.html)
-func (c *Collector) StartCollection(ctx context.Context, namespace string, pods []string) error {
+//
+// Collection stops once pybot finishes, when Symptom.CollectionTimeout expires
+// or when parent ends, whichever comes first, and also when a collection
+// routine panics. However it stops, the watchers, packet captures and traces
+// that were started are stopped again in reverse order and the bundle is
+// written from what was collected. The result reports what was found and how
+// each routine fared; it is nil only when the collection could not start.
+// When parent ended or the timeout stopped pybot, the partial result is
+// returned with an error wrapping context.Cause(parent) or ErrCollectionTimeout.
+// With no pybot suites configured the timeout is the normal end.
+func (c *Collector) StartCollection(parent context.Context, namespace string, pods []string) (*CollectionResult, error) {
+	ctx := parent
 	config := &SymptomCollectionConfig{
 		Namespace: namespace,
 		Pods:      pods,
@@ -125,91 +242,281 @@ func (c *Collector) StartCollection(ctx context.Context, namespace string, pods
 		zap.Strings("pods", config.Pods),
 	)
 
+	result := &CollectionResult{
+		Namespace: config.Namespace,
+		Pods:      config.Pods,
+		StartTime: config.StartTime,
+		OutputDir: filepath.Join(c.config.Symptom.OutputDir,
+			fmt.Sprintf("%s-%s", config.Namespace, config.StartTime.Format("20060102-150405"))),
+	}
+
+	rules, err := rulesFor(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error rules: %w", err)
+	}
+	multiline, err := CompileMultiline(c.config.Symptom.Multiline)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiline configuration: %w", err)
+	}
+	if _, err := pcapMode(c.config.Symptom.Pcap); err != nil {
+		return nil, fmt.Errorf("invalid pcap configuration: %w", err)
+	}
+	if _, err := compileTraceProcesses(c.config.Symptom.Trace); err != nil {
+		return nil, fmt.Errorf("invalid trace configuration: %w", err)
+	}
+
 	// Validation checks
+	if err := c.preflight(ctx, config.Namespace); err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+
 	if err := c.validateNamespace(ctx, config.Namespace); err != nil {
-		return fmt.Errorf("namespace validation failed: %w", err)
+		return nil, fmt.Errorf("namespace validation failed: %w", err)
+	}
+
+	resolved, err := c.validateDeployments(ctx, config.Namespace, config.Pods)
+	if err != nil {
+		return nil, fmt.Errorf("deployment validation failed: %w", err)
+	}
+	config.Targets = resolved
+	targets := c.targetPods(resolved)
+	result.Targets = targets
+
+	watchers, err := buildWatchers(&WatcherEnv{
+		Client:    c.k8sClient,
+		Config:    c.config,
+		Logger:    c.logger,
+		Namespace: config.Namespace,
+		Targets:   targets,
+		OutputDir: result.OutputDir,
+		StartTime: config.StartTime,
+		Rules:     rules,
+		Multiline: multiline,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("symptom source configuration failed: %w", err)
+	}
+
+	pybotNamespace := c.pybotNamespace(config.Namespace)
+	pybotPod, err := c.resolvePybotPod(pybotNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("pybot validation failed: %w", err)
+	}
+
+	driver, err := buildTraceDriver(&TraceEnv{Client: c.k8sClient, Config: c.config, Logger: c.logger})
+	if err != nil {
+		return nil, fmt.Errorf("trace driver configuration failed: %w", err)
+	}
+
+	built, err := buildSinks(&SinkEnv{Config: c.config, Logger: c.logger, OutputDir: result.OutputDir})
+	if err != nil {
+		return nil, fmt.Errorf("event sink configuration failed: %w", err)
+	}
+	for _, sink := range c.sinks {
+		built = append(built, sinkWithConfig{sink: sink})
 	}
+	sinks := newFanout(c.logger, built)
+	// Sinks flush after the collection is cancelled, bounded by sinkCloseTimeout
+	sinks.start(context.WithoutCancel(ctx))
+
+	// Every enable step registers its undo action. They run when the test
+	// completes, the collection is cancelled or times out, or a routine panics,
+	// which cancels the collection.
+	teardown := newTeardown(c.logger)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer func() {
+		// A panic of the collection itself still disables what was enabled
+		if r := recover(); r != nil {
+			teardown.run(ctx)
+			panic(r)
+		}
+	}()
 
-	if err := c.validateDeployments(ctx, config.Namespace, config.Pods); err != nil {
-		return fmt.Errorf("deployment validation failed: %w", err)
+	// The test run is bounded by the collection timeout
+	testCtx := ctx
+	if timeout := c.config.Symptom.CollectionTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		testCtx, cancel = context.WithTimeoutCause(ctx, timeout, ErrCollectionTimeout)
+		defer cancel()
 	}
 
 	// Start symptom collection routines
-	var wg sync.WaitGroup
-	errorChan := make(chan ErrorEvent, 100)
+	routines := newRoutineGroup(c.logger, cancel)
+	events := newPipeline(c.config, c.logger, sinks)
 
 	// Routine 1: Enable traces for processes
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	traces := newTraceSession(driver)
+	teardown.add(RoutineTraces, traceDisableTimeout, func(ctx context.Context) {
+		c.logger.Info("Disabling traces for all processes")
+		var err error
+		if result.Traces, err = c.disableTraces(ctx, traces); err != nil {
+			c.logger.Warn("Traces not disabled", zap.Error(err))
+		}
+	})
+	routines.Go(RoutineTraces, func() {
 		c.logger.Info("Enabling traces for processes")
-		c.enableTraces(ctx, config.Pods)
-	}()
+		c.enableTraces(ctx, traces, config.Namespace, targets)
+	})
 
 	// Routine 2: Enable pcap capture
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	captures := newPcapSession()
+	teardown.add(RoutinePcap, pcapStopTimeout, func(ctx context.Context) {
+		c.logger.Info("Disabling pcaps")
+		var (
+			artifacts []Artifact
+			err       error
+		)
+		result.Captures, artifacts, err = c.disablePcap(ctx, captures, result.OutputDir)
+		if err != nil {
+			c.logger.Warn("Packet captures not collected", zap.Error(err))
+		}
+		result.Artifacts = append(result.Artifacts, artifacts...)
+	})
+	routines.Go(RoutinePcap, func() {
 		c.logger.Info("Enabling pcap capture")
-		c.enablePcap(ctx, config.Pods)
-	}()
+		c.enablePcap(ctx, captures, config, targets)
+	})
+
+	// Routines 4-8: Start the symptom watchers. They run until the test
+	// completes, not until the caller's context ends, and are started before
+	// pybot so that they see everything the test causes.
+	started, watcherErrs := c.startWatchers(ctx, watchers, events.emit)
+	teardown.add(RoutineWatchers, watcherStopTimeout, func(ctx context.Context) {
+		artifacts, errs := c.stopWatchers(ctx, started)
+		result.Artifacts = append(result.Artifacts, artifacts...)
+		watcherErrs = append(watcherErrs, errs...)
+	})
 
 	// Routine 3: Execute pybot command
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		c.logger.Info("Executing pybot command")
-		c.executePybot(ctx)
-	}()
+	pybotDone := make(chan struct{})
+	finishPybot := sync.OnceFunc(func() { close(pybotDone) })
+	var fetchErr error
+	routines.Go(RoutinePybot, func() {
+		// Cleanup also starts if the routine panics
+		defer finishPybot()
+		result.Pybot = c.executePybot(testCtx, pybotNamespace, pybotPod)
+		finishPybot()
+
+		if pybotPod == nil || result.Pybot.Status == PybotError {
+			return
+		}
+		// The output of an interrupted run is still fetched
+		fetchCtx, cancelFetch := context.WithTimeout(context.WithoutCancel(ctx), pybotFetchTimeout)
+		defer cancelFetch()
+		localDir := filepath.Join(result.OutputDir, "pybot")
+		if err := c.fetchPybotOutput(fetchCtx, pybotPod, result.Pybot, localDir); err != nil {
+			c.logger.Warn("Failed to collect pybot results", zap.Error(err))
+			fetchErr = fmt.Errorf("failed to collect pybot results: %w", err)
+		}
+	})
 
-	// Routines 4-8: Watch log files
-	logPaths := c.config.Paths.LogPaths
-	if len(logPaths) == 0 {
-		logPaths = []string{
-			"/cmconfig.log",
-			"/logstore/TspCore",
-			"/RTPTraceError",
-			"/Envoy",
-			"/dumplog",
+	// Routine 9: Monitor completion and cleanup
+	routines.Go("cleanup", func() {
+		<-pybotDone
+		c.logger.Info("Test completed, starting cleanup")
+		teardown.run(ctx)
+	})
+
+	panicked := routines.Wait()
+	if parent.Err() != nil {
+		c.logger.Warn("Symptom collection interrupted, writing the partial bundle", zap.Error(context.Cause(parent)))
+	}
+	// The cleanup routine itself may have panicked before the teardown ran
+	teardown.run(ctx)
+	if result.Pybot == nil {
+		result.Pybot = &PybotResult{Namespace: pybotNamespace, Status: PybotError, Error: "pybot routine did not complete"}
+	}
+	result.Events, result.Stats = events.close()
+	closeCtx, cancelClose := context.WithTimeout(context.WithoutCancel(ctx), sinkCloseTimeout)
+	result.Sinks = sinks.close(closeCtx)
+	cancelClose()
+
+	result.EndTime = time.Now()
+	c.correlateTestCases(result)
+	result.Totals = totalEvents(result.Events)
+
+	// Every failure only logged so far is reported per routine
+	var traceErrs, pcapErrs, pybotErrs, sinkErrs []error
+	for _, trace := range result.Traces {
+		switch {
+		case trace.Error == "":
+		case trace.PID == 0:
+			traceErrs = append(traceErrs, fmt.Errorf("%s %s: %s", trace.Pod, trace.Process, trace.Error))
+		default:
+			traceErrs = append(traceErrs, fmt.Errorf("%s %s[%d]: %s", trace.Pod, trace.Process, trace.PID, trace.Error))
 		}
 	}
-
-	for _, logPath := range logPaths {
-		wg.Add(1)
-		logPath := logPath // Capture for goroutine
-		go func() {
-			defer wg.Done()
-			c.logger.Info("Watching log file", zap.String("path", logPath))
-			c.watchLogFile(ctx, logPath, errorChan)
-		}()
+	for _, capture := range result.Captures {
+		if capture.Error != "" {
+			pcapErrs = append(pcapErrs, fmt.Errorf("%s: %s", capture.Pod, capture.Error))
+		}
 	}
-
-	// Error monitoring
-	go func() {
-		for event := range errorChan {
-			c.logger.Error("Error detected during symptom collection",
-				zap.Time("timestamp", event.Timestamp),
-				zap.String("source", event.Source),
-				zap.String("message", event.Message),
-			)
+	if result.Pybot.Status == PybotError {
+		pybotErrs = append(pybotErrs, errors.New(result.Pybot.Error))
+	}
+	for _, sink := range result.Sinks {
+		if sink.Failed > 0 || sink.Dropped > 0 {
+			sinkErrs = append(sinkErrs, fmt.Errorf("%s lost events: %d failed, %d dropped", sink.Name, sink.Failed, sink.Dropped))
 		}
-	}()
+	}
+	result.Routines = []RoutineResult{
+		newRoutineResult(RoutineTraces, driver == nil,
+			append(append(traceErrs, routines.panicOf(RoutineTraces)), teardown.errors(RoutineTraces)...)),
+		newRoutineResult(RoutinePcap, !c.config.Symptom.Pcap.Enabled,
+			append(append(pcapErrs, routines.panicOf(RoutinePcap)), teardown.errors(RoutinePcap)...)),
+		newRoutineResult(RoutinePybot, result.Pybot.Status == PybotSkipped,
+			append(pybotErrs, fetchErr, routines.panicOf(RoutinePybot))),
+		newRoutineResult(RoutineWatchers, false,
+			append(append(watcherErrs, routines.panicOf("cleanup")), teardown.errors(RoutineWatchers)...)),
+		newRoutineResult(RoutineSinks, false, sinkErrs),
+	}
 
-	// Routine 9: Monitor completion and cleanup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		// In real implementation, wait for pybot completion signal
-		time.Sleep(10 * time.Second) // Simulated test duration
-		c.logger.Info("Test completed, starting cleanup")
-		c.cleanup(ctx, config)
-	}()
+	var bundleErr error
+	if bundleErr = c.writeBundle(result); bundleErr != nil {
+		c.logger.Error("Failed to write symptom bundle", zap.String("dir", result.OutputDir), zap.Error(bundleErr))
+	} else {
+		c.logger.Info("Symptom bundle written", zap.String("path", result.BundlePath))
+	}
+	// The manifest cannot report its own bundle
+	result.Routines = append(result.Routines, newRoutineResult(RoutineBundle, false, []error{bundleErr}))
+	c.logger.Info("Symptom collection completed",
+		zap.Duration("duration", result.Duration()),
+		zap.Int("events", result.Stats.Recorded),
+		zap.Int("duplicates", result.Stats.Duplicates),
+		zap.Int("rate_limited", result.Stats.RateLimited),
+		zap.Int("dropped", result.Stats.Dropped),
+	)
 
-	wg.Wait()
-	close(errorChan)
+	if panicked != nil {
+		return result, fmt.Errorf("symptom collection aborted: %w", panicked)
+	}
+	if parent.Err() != nil {
+		return result, fmt.Errorf("symptom collection interrupted: %w", context.Cause(parent))
+	}
+	if result.Pybot.Status == PybotTimeout {
+		return result, fmt.Errorf("pybot did not finish: %w", ErrCollectionTimeout)
+	}
+	if result.Pybot.Status == PybotError {
+		return result, fmt.Errorf("pybot run failed: %s", result.Pybot.Error)
+	}
+	return result, nil
+}
+
+// preflight verifies that the credentials hold every permission the collection needs
+// before anything is enabled in the cluster
+func (c *Collector) preflight(ctx context.Context, namespace string) error {
+	checks := kubernetes.SymptomCollectionChecksFor(c.config, namespace)
+	report, err := c.k8sClient.PreflightChecks(ctx, kubernetes.WorkflowSymptomCollection, namespace, checks)
+	if err != nil {
+		return err
+	}
 
-	duration := time.Since(config.StartTime)
-	c.logger.Info("Symptom collection completed", zap.Duration("duration", duration))
+	if !report.Allowed() {
+		c.logger.Error("Missing permissions for symptom collection\n" + report.Table())
+		return report.Err()
+	}
+	c.logger.Info("Preflight access checks passed\n" + report.Table())
 	return nil
 }
 
@@ -217,7 +524,7 @@ func (c *Collector) StartCollection(ctx context.Context, namespace string, pods
 func (c *Collector) validateNamespace(ctx context.Context, namespace string) error {
 	exists, err := c.k8sClient.NamespaceExists(namespace)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to check namespace %s: %w", namespace, err)
 	}
 	if !exists {
 		return fmt.Errorf("namespace %s does not exist", namespace)
@@ -225,115 +532,151 @@ func (c *Collector) validateNamespace(ctx context.Context, namespace string) err
 	return nil
 }
 
-// validateDeployments checks if deployments exist and are ready
-func (c *Collector) validateDeployments(ctx context.Context, namespace string, pods []string) error {
-	deployments, err := c.k8sClient.GetDeployments(namespace)
+// validateDeployments resolves each requested pod name to its deployment and
+// running pods, and checks that every deployment is ready
+func (c *Collector) validateDeployments(ctx context.Context, namespace string, pods []string) ([]kubernetes.ResolvedTarget, error) {
+	mode, err := kubernetes.ParseMatchMode(c.config.Kubernetes.MatchMode)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, pod := range pods {
-		found := false
-		for _, dep := range deployments {
-			if strings.Contains(dep.Name, pod) {
-				found = true
-				ready, err := c.k8sClient.IsDeploymentReady(namespace, dep.Name)
-				if err != nil {
-					return fmt.Errorf("failed to check deployment readiness: %w", err)
-				}
-				if !ready {
-					return fmt.Errorf("deployment %s is not ready", dep.Name)
-				}
-				break
-			}
+	targets, err := c.k8sClient.ResolveTargets(namespace, pods, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		status, err := c.k8sClient.GetRolloutStatus(namespace, target.Deployment.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check deployment readiness: %w", err)
 		}
-		if !found {
-			return fmt.Errorf("deployment for pod %s not found", pod)
+		if !status.Done {
+			return nil, fmt.Errorf("deployment %s is not ready (%s): %s",
+				target.Deployment.Name, status.Reason, status.Message)
 		}
+
+		if err := c.checkPodHealth(namespace, target); err != nil {
+			return nil, err
+		}
+
+		podNames := make([]string, 0, len(target.Pods))
+		for _, pod := range target.Pods {
+			podNames = append(podNames, pod.Name)
+		}
+		c.logger.Info("Resolved pod name",
+			zap.String("name", target.Name),
+			zap.String("deployment", target.Deployment.Name),
+			zap.String("selector", target.Selector.String()),
+			zap.Strings("pods", podNames),
+		)
 	}
 
-	return nil
+	return targets, nil
 }
 
-// enableTraces enables tracing for processes
-func (c *Collector) enableTraces(ctx context.Context, pods []string) {
-	for _, pod := range pods {
-		c.logger.Debug("Enabling trace for pod", zap.String("pod", pod))
-		// Actual implementation would enable tracing via kubectl exec or API
-		time.Sleep(500 * time.Millisecond)
+// checkPodHealth checks the readiness and liveness of every resolved pod.
+// Unready containers fail validation; restarts are only reported.
+func (c *Collector) checkPodHealth(namespace string, target kubernetes.ResolvedTarget) error {
+	for _, pod := range target.Pods {
+		health, err := c.k8sClient.GetPodHealth(namespace, pod.Name)
+		if err != nil {
+			return fmt.Errorf("failed to check pod readiness: %w", err)
+		}
+
+		for _, container := range health.Containers {
+			if !container.Ready {
+				return fmt.Errorf("container %s in pod %s is not ready (%s %s)",
+					container.Name, pod.Name, container.State, container.Reason)
+			}
+			if container.RestartCount > 0 {
+				c.logger.Warn("Container has restarted before collection",
+					zap.String("pod", pod.Name),
+					zap.String("container", container.Name),
+					zap.Int32("restarts", container.RestartCount),
+					zap.String("last_termination", container.LastTerminationReason),
+				)
+			}
+		}
+		if !health.Ready {
+			return fmt.Errorf("pod %s is not ready", pod.Name)
+		}
 	}
+	return nil
 }
 
-// enablePcap enables pcap capture
-func (c *Collector) enablePcap(ctx context.Context, pods []string) {
-	for _, pod := range pods {
-		c.logger.Debug("Enabling pcap for pod", zap.String("pod", pod))
-		// Actual implementation would enable pcap via kubectl exec or API
-		time.Sleep(500 * time.Millisecond)
+// targetPods flattens resolved targets into the pod containers to collect from
+func (c *Collector) targetPods(targets []kubernetes.ResolvedTarget) []TargetPod {
+	var pods []TargetPod
+	for _, target := range targets {
+		for _, pod := range target.Pods {
+			container := c.config.Symptom.Container
+			if container == "" && len(pod.Containers) > 0 {
+				container = pod.Containers[0]
+			}
+			pods = append(pods, TargetPod{
+				Target:     target.Name,
+				Deployment: target.Deployment.Name,
+				Name:       pod.Name,
+				Container:  container,
+			})
+		}
 	}
+	return pods
 }
 
-// executePybot executes pybot command on testclient pod
-func (c *Collector) executePybot(ctx context.Context) {
-	c.logger.Debug("Executing pybot command on testclient pod")
-	// Actual implementation would execute pybot via kubectl exec
-	time.Sleep(2 * time.Second)
-	c.logger.Debug("Pybot command completed")
+// startWatchers starts every watcher and returns the ones that started and
+// the failures of the others
+func (c *Collector) startWatchers(ctx context.Context, watchers []Watcher, emit EmitFunc) ([]Watcher, []error) {
+	started := make([]Watcher, 0, len(watchers))
+	var errs []error
+	for _, watcher := range watchers {
+		if err := watcher.Start(ctx, emit); err != nil {
+			c.logger.Error("Failed to start watcher", zap.String("watcher", watcher.Name()), zap.Error(err))
+			errs = append(errs, fmt.Errorf("failed to start watcher %s: %w", watcher.Name(), err))
+			continue
+		}
+		c.logger.Info("Started watcher", zap.String("watcher", watcher.Name()))
+		started = append(started, watcher)
+	}
+	return started, errs
 }
 
-// watchLogFile watches a log file for errors
-func (c *Collector) watchLogFile(ctx context.Context, filePath string, errorChan chan<- ErrorEvent) {
-	keywords := c.config.Symptom.ErrorKeywords
-	if len(keywords) == 0 {
-		keywords = []string{"error", "ERROR", "fatal", "FATAL", "exception", "EXCEPTION", "panic", "PANIC"}
+// stopWatchers stops the watchers in parallel and gathers their artifacts and
+// the failures to stop them
+func (c *Collector) stopWatchers(ctx context.Context, watchers []Watcher) ([]Artifact, []error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, watcher := range watchers {
+		wg.Add(1)
+		watcher := watcher // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			if err := watcher.Stop(ctx); err != nil {
+				c.logger.Warn("Failed to stop watcher", zap.String("watcher", watcher.Name()), zap.Error(err))
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to stop watcher %s: %w", watcher.Name(), err))
+				mu.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
 
-	interval := c.config.Symptom.CheckInterval
-	if interval == 0 {
-		interval = 1 * time.Second
+	var artifacts []Artifact
+	for _, watcher := range watchers {
+		artifacts = append(artifacts, watcher.Artifacts()...)
 	}
+	return artifacts, errs
+}
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			// In real implementation, read file and check for keywords
-			// For now, simulate error detection
-			if time.Now().Unix()%10 == 0 {
-				errorChan <- ErrorEvent{
-					Timestamp: time.Now(),
-					Source:    filePath,
-					Message:   fmt.Sprintf("Error detected in %s", filePath),
-				}
-			}
+// containsPath reports whether p is one of paths
+func containsPath(paths []string, p string) bool {
+	for _, candidate := range paths {
+		if path.Clean(candidate) == path.Clean(p) {
+			return true
 		}
 	}
+	return false
 }
-
-// cleanup performs cleanup after test completion
-// Routine 10: store the process traces into single file, can be used for analysis and also for symptom collections
-// Routine: Parallely Disables the trace of each process
-// Routine: Parallely Disables the pcaps
-// Routine: Stops the process for to watch the /cmconfig.log, Store the traces for analysis or symptom collection
-// Routine: Stops the process for to watch the /logstore/TspCore, Store the traces for analysis or symptom collection
-// Routine: Stops the process for to watch the /RTPTraceError, Store the traces for analysis or symptom collection
-// Routine: Stops the process for to watch the /Envoy, Store the traces for analysis or symptom collection
-// Routine: Stops the process for to watch the /dumplog, Store the traces for analysis or symptom collection
-// Copy all the information to local path (All traces, pcap, log.html)
-func (c *Collector) cleanup(ctx context.Context, config *SymptomCollectionConfig) {
-	c.logger.Info("Storing process traces for analysis")
-	c.logger.Info("Disabling traces for all processes")
-	c.logger.Info("Disabling pcaps")
-	c.logger.Info("Stopping log watchers and storing traces")
-
-	// In real implementation:
-	// - Store traces to file
-	// - Disable traces and pcaps
-	// - Stop log watchers
-	// - Copy all data to local path
-}
-
@@ -0,0 +1,267 @@
+package symptom
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func newDeployment(namespace, name string, replicas, ready int32) *v1.Deployment {
+	return &v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+		},
+		Status: v1.DeploymentStatus{
+			Replicas:          replicas,
+			UpdatedReplicas:   replicas,
+			ReadyReplicas:     ready,
+			AvailableReplicas: ready,
+		},
+	}
+}
+
+func newPod(namespace, name, app string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "mcc"}}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "mcc",
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
+
+func newTestCollector(t *testing.T, objects ...runtime.Object) (*Collector, *fake.Executor, *observer.ObservedLogs) {
+	t.Helper()
+	client, executor := fake.NewClient(objects...)
+	core, logs := observer.New(zap.DebugLevel)
+	cfg := &config.Config{
+		Paths:   config.PathsConfig{LogPaths: []string{"/cmconfig.log"}},
+		Symptom: config.SymptomConfig{CheckInterval: 10 * time.Millisecond, OutputDir: t.TempDir()},
+	}
+	return NewCollector(cfg, client, zap.New(core)), executor, logs
+}
+
+func TestValidateNamespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		wantErr   bool
+	}{
+		{name: "existing namespace", namespace: "miniudm", wantErr: false},
+		{name: "missing namespace", namespace: "dracvnf", wantErr: true},
+	}
+
+	collector, _, _ := newTestCollector(t, newNamespace("miniudm"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := collector.validateNamespace(context.Background(), tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNamespace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDeployments(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		pods     []string
+		wantPods int
+		wantErr  string
+	}{
+		{
+			name: "all replicas resolved",
+			objects: []runtime.Object{
+				newDeployment("miniudm", "uecm", 2, 2),
+				newPod("miniudm", "uecm-a", "uecm"),
+				newPod("miniudm", "uecm-b", "uecm"),
+			},
+			pods:     []string{"uecm"},
+			wantPods: 2,
+		},
+		{
+			name: "exact match preferred over longer name",
+			objects: []runtime.Object{
+				newDeployment("miniudm", "uecm", 1, 1),
+				newDeployment("miniudm", "uecm-proxy", 1, 1),
+				newPod("miniudm", "uecm-a", "uecm"),
+				newPod("miniudm", "uecm-proxy-a", "uecm-proxy"),
+			},
+			pods:     []string{"uecm"},
+			wantPods: 1,
+		},
+		{
+			name:    "deployment missing",
+			objects: []runtime.Object{newDeployment("miniudm", "uecm", 1, 1)},
+			pods:    []string{"nim"},
+			wantErr: "not found",
+		},
+		{
+			name: "deployment not ready",
+			objects: []runtime.Object{
+				newDeployment("miniudm", "uecm", 2, 1),
+				newPod("miniudm", "uecm-a", "uecm"),
+			},
+			pods:    []string{"uecm"},
+			wantErr: "not ready",
+		},
+		{
+			name: "container not ready",
+			objects: []runtime.Object{
+				newDeployment("miniudm", "uecm", 1, 1),
+				func() *corev1.Pod {
+					pod := newPod("miniudm", "uecm-a", "uecm")
+					pod.Status.ContainerStatuses[0].Ready = false
+					pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+					}
+					return pod
+				}(),
+			},
+			pods:    []string{"uecm"},
+			wantErr: "CrashLoopBackOff",
+		},
+		{
+			name:    "no running pods",
+			objects: []runtime.Object{newDeployment("miniudm", "uecm", 1, 1)},
+			pods:    []string{"uecm"},
+			wantErr: "no running pods",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, _, _ := newTestCollector(t, tt.objects...)
+			targets, err := collector.validateDeployments(context.Background(), "miniudm", tt.pods)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateDeployments() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateDeployments() error = %v", err)
+			}
+			if got := len(collector.targetPods(targets)); got != tt.wantPods {
+				t.Errorf("validateDeployments() resolved %d pods, want %d", got, tt.wantPods)
+			}
+		})
+	}
+}
+
+func TestStartCollection(t *testing.T) {
+	tests := []struct {
+		name       string
+		objects    []runtime.Object
+		deny       []string
+		pcapMode   string
+		namespace  string
+		pods       []string
+		wantErr    bool
+		wantErrors int
+	}{
+		{
+			name:      "exec permission denied",
+			objects:   []runtime.Object{newNamespace("miniudm")},
+			deny:      []string{"create", "pods/exec"},
+			namespace: "miniudm",
+			pods:      []string{"uecm"},
+			wantErr:   true,
+		},
+		{
+			name:      "ephemeral container permission denied",
+			objects:   []runtime.Object{newNamespace("miniudm")},
+			deny:      []string{"update", "pods/ephemeralcontainers"},
+			pcapMode:  PcapAuto,
+			namespace: "miniudm",
+			pods:      []string{"uecm"},
+			wantErr:   true,
+		},
+		{
+			name:      "namespace missing",
+			namespace: "miniudm",
+			pods:      []string{"uecm"},
+			wantErr:   true,
+		},
+		{
+			name:      "deployment missing",
+			objects:   []runtime.Object{newNamespace("miniudm")},
+			namespace: "miniudm",
+			pods:      []string{"uecm"},
+			wantErr:   true,
+		},
+		{
+			name: "errors reported from tailed log",
+			objects: []runtime.Object{
+				newNamespace("miniudm"),
+				newDeployment("miniudm", "uecm", 1, 1),
+				newPod("miniudm", "uecm-a", "uecm"),
+			},
+			namespace:  "miniudm",
+			pods:       []string{"uecm"},
+			wantErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, executor, logs := newTestCollector(t, tt.objects...)
+			if len(tt.deny) == 2 {
+				fake.Deny(collector.k8sClient.(*kubernetes.Client), tt.deny[0], tt.deny[1])
+			}
+			if tt.pcapMode != "" {
+				collector.config.Symptom.Pcap = config.PcapConfig{Enabled: true, Mode: tt.pcapMode}
+			}
+			executor.On("uecm-a", tailCommand, tailStream(
+				"2024-03-01 INFO registration ok",
+				"2024-03-01 ERROR registration failed for imsi-001",
+			))
+			executor.On("uecm-a", "sh -c for f in", fake.Reply("", 0))
+
+			collector.config.Symptom.CollectionTimeout = 1500 * time.Millisecond
+
+			result, err := collector.StartCollection(context.Background(), tt.namespace, tt.pods)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartCollection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			detected := logs.FilterMessage("Error detected during symptom collection").Len()
+			if detected != tt.wantErrors {
+				t.Errorf("StartCollection() reported %d errors, want %d", detected, tt.wantErrors)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := result.Totals.BySeverity[SeverityError]; got != tt.wantErrors {
+				t.Errorf("StartCollection() totals %d errors, want %d", got, tt.wantErrors)
+			}
+			if failed := result.FailedRoutines(); len(failed) != 0 {
+				t.Errorf("StartCollection() failed routines = %+v", failed)
+			}
+		})
+	}
+}
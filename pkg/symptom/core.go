@@ -0,0 +1,347 @@
+package symptom
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+)
+
+// defaultCoreNamePattern matches core.<pid> and core.<process>.<pid>
+const defaultCoreNamePattern = `^core\.(?:(?P<exe>[^.]+)\.)?(?P<pid>\d+)`
+
+// listCoresScript prints "<size> <mtime> <path>" for every regular file in "$1".
+// It is written for busybox as well as coreutils, which lack find -printf.
+const listCoresScript = `for f in "$1"/*; do [ -f "$f" ] && stat -c '%s %Y %n' "$f"; done; true`
+
+// signalNames names the signals that commonly produce cores
+var signalNames = map[int]string{
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	11: "SIGSEGV",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+	31: "SIGSYS",
+}
+
+// CoreInfo describes a core file detected in a target pod
+type CoreInfo struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	// Path is the core file path inside the container
+	Path    string    `json:"path"`
+	Process string    `json:"process,omitempty"`
+	PID     int       `json:"pid,omitempty"`
+	Signal  int       `json:"signal,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// LocalPath and SHA256 are set once the core is copied into the run directory
+	LocalPath string `json:"local_path,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// SignalName returns the name of the terminating signal, e.g. SIGSEGV
+func (c *CoreInfo) SignalName() string {
+	if name, ok := signalNames[c.Signal]; ok {
+		return name
+	}
+	if c.Signal == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("signal %d", c.Signal)
+}
+
+// String describes the core for logs and events
+func (c *CoreInfo) String() string {
+	process := c.Process
+	if process == "" {
+		process = "unknown process"
+	}
+	return fmt.Sprintf("core dumped by %s (pid %d, %s, %d bytes): %s",
+		process, c.PID, c.SignalName(), c.Size, c.Path)
+}
+
+// coreFile is a file seen in a core directory listing
+type coreFile struct {
+	size    int64
+	modTime int64
+}
+
+// parseCoreListing parses the output of listCoresScript
+func parseCoreListing(output string) map[string]coreFile {
+	files := make(map[string]coreFile)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		modTime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		files[fields[2]] = coreFile{size: size, modTime: modTime}
+	}
+	return files
+}
+
+// parseCoreName extracts the process name, PID and signal encoded in a core
+// file name using the named groups exe, pid and sig of pattern
+func parseCoreName(pattern *regexp.Regexp, name string, core *CoreInfo) {
+	match := pattern.FindStringSubmatch(name)
+	if match == nil {
+		return
+	}
+	for i, group := range pattern.SubexpNames() {
+		if match[i] == "" {
+			continue
+		}
+		switch group {
+		case "exe":
+			core.Process = match[i]
+		case "pid":
+			core.PID, _ = strconv.Atoi(match[i])
+		case "sig":
+			core.Signal, _ = strconv.Atoi(match[i])
+		}
+	}
+}
+
+// corePattern compiles the configured core file name pattern
+func corePattern(cfg *config.Config) (*regexp.Regexp, error) {
+	pattern := cfg.Symptom.CoreNamePattern
+	if pattern == "" {
+		pattern = defaultCoreNamePattern
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid core name pattern: %w", err)
+	}
+	return compiled, nil
+}
+
+// coreWatcher polls a core directory inside a target container and emits a core
+// event for every new core file once it stops growing. Cores present when the
+// watcher starts are ignored unless they are rewritten.
+type coreWatcher struct {
+	env     *WatcherEnv
+	target  TargetPod
+	dir     string
+	pattern *regexp.Regexp
+
+	loop      loop
+	mu        sync.Mutex
+	artifacts []Artifact
+}
+
+// newCoreWatchers creates a watcher for every core directory in every target pod
+func newCoreWatchers(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+	pattern, err := corePattern(env.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var built []Watcher
+	for _, target := range env.Targets {
+		for _, dir := range source.Paths {
+			built = append(built, &coreWatcher{env: env, target: target, dir: dir, pattern: pattern})
+		}
+	}
+	return built, nil
+}
+
+func (w *coreWatcher) Name() string {
+	return fmt.Sprintf("%s %s:%s", SourceCores, w.target.Name, w.dir)
+}
+
+func (w *coreWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	// The baseline is taken before the test runs, so that no core written
+	// once it started is mistaken for an old one
+	known, err := w.list(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list core directory %s: %w", w.dir, err)
+	}
+
+	w.loop.start(ctx, func(ctx context.Context) {
+		w.watch(ctx, known, emit)
+	})
+	return nil
+}
+
+func (w *coreWatcher) Stop(ctx context.Context) error {
+	return w.loop.stop(ctx)
+}
+
+func (w *coreWatcher) Artifacts() []Artifact {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Artifact(nil), w.artifacts...)
+}
+
+// watch polls the directory until ctx ends, reporting the files that are not
+// in known, the listing taken when the watcher started
+func (w *coreWatcher) watch(ctx context.Context, known map[string]coreFile, emit EmitFunc) {
+	pending := make(map[string]coreFile)
+	reported := make(map[string]bool)
+
+	for {
+		files, err := w.list(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			w.env.Logger.Warn("Failed to list core directory",
+				zap.String("pod", w.target.Name),
+				zap.String("path", w.dir),
+				zap.Error(err),
+			)
+		default:
+			for name, file := range files {
+				if reported[name] {
+					continue
+				}
+				if old, ok := known[name]; ok && old == file {
+					continue
+				}
+				// Report a new or rewritten core once two polls see the same size and mtime
+				if last, ok := pending[name]; ok && last == file {
+					delete(pending, name)
+					reported[name] = true
+					w.report(ctx, name, file, emit)
+					continue
+				}
+				pending[name] = file
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.env.interval()):
+		}
+	}
+}
+
+// list lists the regular files in the core directory
+func (w *coreWatcher) list(ctx context.Context) (map[string]coreFile, error) {
+	result, err := w.env.Client.ExecOutput(ctx, kubernetes.ExecOptions{
+		Namespace: w.env.Namespace,
+		Pod:       w.target.Name,
+		Container: w.target.Container,
+		Command:   []string{"sh", "-c", listCoresScript, "sh", w.dir},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("listing exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return parseCoreListing(result.Stdout), nil
+}
+
+// report copies a finished core into the run directory, reads its metadata
+// and emits a core event. The event is emitted even if the copy fails.
+func (w *coreWatcher) report(ctx context.Context, remotePath string, file coreFile, emit EmitFunc) {
+	core := &CoreInfo{
+		Pod:       w.target.Name,
+		Container: w.target.Container,
+		Path:      remotePath,
+		Size:      file.size,
+		ModTime:   time.Unix(file.modTime, 0),
+	}
+	parseCoreName(w.pattern, path.Base(remotePath), core)
+
+	if err := w.copy(ctx, core); err != nil {
+		w.env.Logger.Warn("Failed to copy core file",
+			zap.String("pod", w.target.Name),
+			zap.String("path", remotePath),
+			zap.Error(err),
+		)
+	}
+
+	emit(ErrorEvent{
+		Timestamp: core.ModTime,
+		Kind:      EventCore,
+		Pod:       w.target.Name,
+		Container: w.target.Container,
+		Source:    path.Dir(remotePath),
+		Message:   core.String(),
+		Core:      core,
+		Rule:      RuleCoreDump,
+		Severity:  SeverityCritical,
+	})
+}
+
+// copy copies a core file into the run directory, hashes it and reads its ELF notes
+func (w *coreWatcher) copy(ctx context.Context, core *CoreInfo) error {
+	if limit := w.env.Config.Symptom.MaxCoreSize; limit > 0 && core.Size > limit {
+		return fmt.Errorf("core is %d bytes, larger than symptom.max_core_size", core.Size)
+	}
+
+	copied, err := w.env.Client.CopyFromPod(ctx, kubernetes.CopyFromPodOptions{
+		Namespace: w.env.Namespace,
+		Pod:       w.target.Name,
+		Container: w.target.Container,
+		Paths:     []string{core.Path},
+		LocalDir:  filepath.Join(w.env.OutputDir, "cores", w.target.Name),
+	})
+	if err != nil {
+		return err
+	}
+	if len(copied.Files) == 0 {
+		return fmt.Errorf("core file disappeared before it could be copied")
+	}
+	core.LocalPath = copied.Files[0].LocalPath
+
+	artifact, err := newArtifact("core", w.target, core.Path, core.LocalPath)
+	if err != nil {
+		return err
+	}
+	core.SHA256 = artifact.SHA256
+	w.mu.Lock()
+	w.artifacts = append(w.artifacts, artifact)
+	w.mu.Unlock()
+
+	file, err := os.Open(core.LocalPath)
+	if err != nil {
+		return fmt.Errorf("failed to open core file: %w", err)
+	}
+	defer file.Close()
+
+	notes, err := readCoreNotes(file)
+	if err != nil {
+		// Truncated or compressed cores keep the details parsed from the name
+		w.env.Logger.Debug("Could not read core notes", zap.String("path", core.LocalPath), zap.Error(err))
+		return nil
+	}
+	if notes.Process != "" {
+		core.Process = notes.Process
+	}
+	if notes.PID != 0 {
+		core.PID = notes.PID
+	}
+	if notes.Signal != 0 {
+		core.Signal = notes.Signal
+	}
+	return nil
+}
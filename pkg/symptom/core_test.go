@@ -0,0 +1,219 @@
+package symptom
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+)
+
+// buildCore returns a minimal x86-64 ELF core file with NT_PRSTATUS and NT_PRPSINFO notes
+func buildCore(t *testing.T, process string, pid, signal int) []byte {
+	t.Helper()
+	order := binary.LittleEndian
+
+	note := func(noteType uint32, desc []byte) []byte {
+		var b bytes.Buffer
+		binary.Write(&b, order, uint32(5))
+		binary.Write(&b, order, uint32(len(desc)))
+		binary.Write(&b, order, noteType)
+		b.WriteString("CORE\x00\x00\x00\x00")
+		b.Write(desc)
+		return b.Bytes()
+	}
+
+	prstatus := make([]byte, 336)
+	order.PutUint16(prstatus[12:], uint16(signal))
+	order.PutUint32(prstatus[32:], uint32(pid))
+	prpsinfo := make([]byte, 136)
+	copy(prpsinfo[40:56], process)
+	notes := append(note(ntPRStatus, prstatus), note(ntPRPSInfo, prpsinfo)...)
+
+	const headerSize, progSize = 64, 56
+	header := elf.Header64{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Ehsize:    headerSize,
+		Phentsize: progSize,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	prog := elf.Prog64{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    headerSize + progSize,
+		Filesz: uint64(len(notes)),
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, order, header)
+	binary.Write(&b, order, prog)
+	b.Write(notes)
+	return b.Bytes()
+}
+
+func TestParseCoreName(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		file        string
+		wantProcess string
+		wantPID     int
+		wantSignal  int
+	}{
+		{name: "process and pid", pattern: defaultCoreNamePattern, file: "core.uecm.4242", wantProcess: "uecm", wantPID: 4242},
+		{name: "pid only", pattern: defaultCoreNamePattern, file: "core.4242", wantPID: 4242},
+		{name: "no match", pattern: defaultCoreNamePattern, file: "uecm.dump"},
+		{
+			name:        "custom pattern with signal",
+			pattern:     `^core-(?P<exe>\w+)-(?P<sig>\d+)-(?P<pid>\d+)-\d+$`,
+			file:        "core-nim-11-77-1709294400",
+			wantProcess: "nim",
+			wantPID:     77,
+			wantSignal:  11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := &CoreInfo{}
+			parseCoreName(regexp.MustCompile(tt.pattern), tt.file, core)
+			if core.Process != tt.wantProcess || core.PID != tt.wantPID || core.Signal != tt.wantSignal {
+				t.Errorf("parseCoreName() = %s/%d/%d, want %s/%d/%d",
+					core.Process, core.PID, core.Signal, tt.wantProcess, tt.wantPID, tt.wantSignal)
+			}
+		})
+	}
+}
+
+func TestReadCoreNotes(t *testing.T) {
+	notes, err := readCoreNotes(bytes.NewReader(buildCore(t, "uecm", 4242, 11)))
+	if err != nil {
+		t.Fatalf("readCoreNotes() error = %v", err)
+	}
+	if notes.Process != "uecm" || notes.PID != 4242 || notes.Signal != 11 {
+		t.Errorf("readCoreNotes() = %+v, want uecm/4242/11", notes)
+	}
+
+	if _, err := readCoreNotes(bytes.NewReader([]byte("not an elf file"))); err == nil {
+		t.Error("readCoreNotes() error = nil, want error for non-ELF data")
+	}
+}
+
+func TestParseCoreListing(t *testing.T) {
+	files := parseCoreListing("100 1709294400 /logstore/TspCore/core.uecm.1\ngarbage\n5 1709294401 /logstore/TspCore/with space\n")
+	if len(files) != 2 {
+		t.Fatalf("parseCoreListing() returned %d files, want 2", len(files))
+	}
+	if got := files["/logstore/TspCore/with space"]; got.size != 5 || got.modTime != 1709294401 {
+		t.Errorf("parseCoreListing() = %+v", got)
+	}
+}
+
+func TestWatchCores(t *testing.T) {
+	core := buildCore(t, "uecm", 4242, 11)
+	listings := []string{
+		"10 1709294000 /logstore/TspCore/core.old.1\n",
+		"10 1709294000 /logstore/TspCore/core.old.1\n100 1709294400 /logstore/TspCore/core.uecm.4242\n",
+		"10 1709294000 /logstore/TspCore/core.old.1\n900 1709294401 /logstore/TspCore/core.uecm.4242\n",
+	}
+	var mu sync.Mutex
+	calls := 0
+
+	collector, executor, _ := newTestCollector(t)
+	executor.On("uecm-a", "sh -c for f in", func(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error) {
+		mu.Lock()
+		listing := listings[len(listings)-1]
+		if calls < len(listings) {
+			listing = listings[calls]
+		}
+		calls++
+		mu.Unlock()
+		opts.Stdout.Write([]byte(listing))
+		return &kubernetes.ExecResult{}, nil
+	})
+	executor.On("uecm-a", "sh -c tar cf -", tarReply(t, map[string]string{
+		"logstore/TspCore/core.uecm.4242": string(core),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan ErrorEvent, 10)
+	target := TargetPod{Name: "uecm-a", Container: "mcc"}
+	watcher := &coreWatcher{
+		env:     newTestEnv(collector, target),
+		target:  target,
+		dir:     "/logstore/TspCore",
+		pattern: regexp.MustCompile(defaultCoreNamePattern),
+	}
+	if err := watcher.Start(ctx, func(event ErrorEvent) { events <- event }); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	var event ErrorEvent
+	select {
+	case event = <-events:
+	case <-ctx.Done():
+		t.Fatal("coreWatcher reported no core")
+	}
+	if err := watcher.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if event.Kind != EventCore || event.Core == nil {
+		t.Fatalf("coreWatcher event = %+v, want a core event", event)
+	}
+	got := event.Core
+	if got.Path != "/logstore/TspCore/core.uecm.4242" || got.Size != 900 {
+		t.Errorf("coreWatcher core = %s (%d bytes), want the stable core.uecm.4242", got.Path, got.Size)
+	}
+	if got.Process != "uecm" || got.PID != 4242 || got.SignalName() != "SIGSEGV" {
+		t.Errorf("coreWatcher core = %s/%d/%s, want uecm/4242/SIGSEGV", got.Process, got.PID, got.SignalName())
+	}
+	if got.SHA256 == "" {
+		t.Error("coreWatcher did not hash the copied core")
+	}
+	if data, err := os.ReadFile(got.LocalPath); err != nil || !bytes.Equal(data, core) {
+		t.Errorf("coreWatcher local copy %s error = %v", got.LocalPath, err)
+	}
+	if artifacts := watcher.Artifacts(); len(artifacts) != 1 || artifacts[0].Path != got.LocalPath || artifacts[0].SHA256 != got.SHA256 {
+		t.Errorf("Artifacts() = %+v, want the copied core", artifacts)
+	}
+
+	select {
+	case extra := <-events:
+		t.Errorf("coreWatcher reported unexpected event %s", extra.Message)
+	default:
+	}
+}
+
+func TestWatchCoresBaselineFails(t *testing.T) {
+	collector, executor, _ := newTestCollector(t)
+	executor.On("uecm-a", "sh -c for f in", fake.Reply("", 1))
+
+	target := TargetPod{Name: "uecm-a", Container: "mcc"}
+	watcher := &coreWatcher{
+		env:     newTestEnv(collector, target),
+		target:  target,
+		dir:     "/logstore/TspCore",
+		pattern: regexp.MustCompile(defaultCoreNamePattern),
+	}
+	// Without a baseline every core written before the first listing would be missed
+	if err := watcher.Start(context.Background(), func(ErrorEvent) {}); err == nil {
+		watcher.Stop(context.Background())
+		t.Fatal("Start() error = nil, want the failed listing")
+	}
+}
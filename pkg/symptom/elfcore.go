@@ -0,0 +1,103 @@
+package symptom
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ELF note types written into core files by the Linux kernel
+const (
+	ntPRStatus = 1
+	ntPRPSInfo = 3
+)
+
+// coreNotes holds the process details recorded in a core file's notes
+type coreNotes struct {
+	Process string
+	PID     int
+	Signal  int
+}
+
+// readCoreNotes extracts the process name, PID and terminating signal from the
+// NT_PRSTATUS and NT_PRPSINFO notes of an ELF core file
+func readCoreNotes(r io.ReaderAt) (*coreNotes, error) {
+	file, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ELF header: %w", err)
+	}
+	defer file.Close()
+
+	if file.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("not a core file (ELF type %s)", file.Type)
+	}
+
+	notes := &coreNotes{}
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read note segment: %w", err)
+		}
+		parseNotes(data, file.ByteOrder, file.Class, notes)
+	}
+	return notes, nil
+}
+
+// parseNotes walks a PT_NOTE segment and fills notes from the records it knows
+func parseNotes(data []byte, order binary.ByteOrder, class elf.Class, notes *coreNotes) {
+	for len(data) >= 12 {
+		nameSize := int(order.Uint32(data[0:4]))
+		descSize := int(order.Uint32(data[4:8]))
+		noteType := order.Uint32(data[8:12])
+		data = data[12:]
+
+		nameEnd := align4(nameSize)
+		descEnd := nameEnd + align4(descSize)
+		if nameEnd > len(data) || nameEnd+descSize > len(data) {
+			return
+		}
+		desc := data[nameEnd : nameEnd+descSize]
+
+		switch noteType {
+		case ntPRStatus:
+			// elf_prstatus: pr_cursig follows the siginfo header; pr_pid follows
+			// the two signal masks, which are longs
+			pidOffset := 32
+			if class == elf.ELFCLASS32 {
+				pidOffset = 24
+			}
+			if len(desc) >= pidOffset+4 && notes.PID == 0 {
+				notes.Signal = int(order.Uint16(desc[12:14]))
+				notes.PID = int(int32(order.Uint32(desc[pidOffset : pidOffset+4])))
+			}
+		case ntPRPSInfo:
+			// elf_prpsinfo: pr_fname is a 16 byte NUL-padded array
+			fnameOffset := 40
+			if class == elf.ELFCLASS32 {
+				fnameOffset = 28
+			}
+			if len(desc) >= fnameOffset+16 {
+				fname := desc[fnameOffset : fnameOffset+16]
+				if i := bytes.IndexByte(fname, 0); i >= 0 {
+					fname = fname[:i]
+				}
+				notes.Process = string(fname)
+			}
+		}
+
+		if descEnd > len(data) {
+			return
+		}
+		data = data[descEnd:]
+	}
+}
+
+// align4 rounds n up to the 4 byte alignment used by ELF notes
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,210 @@
+package symptom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// eventWatcher polls the Kubernetes events about the target pods and
+// deployments, saves them and reports the warnings among them
+type eventWatcher struct {
+	env *WatcherEnv
+	// objects are the names of the target pods, their deployments and the
+	// replica sets owning the pods, found when the watcher starts
+	objects map[string]bool
+
+	loop      loop
+	mu        sync.Mutex
+	artifacts []Artifact
+}
+
+// newEventWatchers creates a single watcher for the namespace
+func newEventWatchers(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+	return []Watcher{&eventWatcher{env: env}}, nil
+}
+
+func (w *eventWatcher) Name() string {
+	return fmt.Sprintf("%s %s", SourceEvents, w.env.Namespace)
+}
+
+func (w *eventWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	objects, err := w.involvedObjects()
+	if err != nil {
+		return fmt.Errorf("failed to find the replica sets of the target pods: %w", err)
+	}
+	w.objects = objects
+
+	localPath := filepath.Join(w.env.OutputDir, "events", w.env.Namespace+".log")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create events directory: %w", err)
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create events file: %w", err)
+	}
+
+	w.loop.start(ctx, func(ctx context.Context) {
+		w.watch(ctx, file, emit)
+
+		if err := file.Close(); err != nil {
+			w.env.Logger.Warn("Failed to save events", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		artifact, err := newArtifact("events", TargetPod{}, "events/"+w.env.Namespace, localPath)
+		if err != nil {
+			w.env.Logger.Warn("Failed to record events", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		w.mu.Lock()
+		w.artifacts = append(w.artifacts, artifact)
+		w.mu.Unlock()
+	})
+	return nil
+}
+
+func (w *eventWatcher) Stop(ctx context.Context) error {
+	return w.loop.stop(ctx)
+}
+
+func (w *eventWatcher) Artifacts() []Artifact {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Artifact(nil), w.artifacts...)
+}
+
+// watch polls the events until ctx ends
+func (w *eventWatcher) watch(ctx context.Context, file *os.File, emit EmitFunc) {
+	seen := make(map[string]bool)
+	selectors := w.selectors()
+	for {
+		var events []corev1.Event
+		for _, selector := range selectors {
+			listed, err := w.env.Client.GetEvents(w.env.Namespace, selector)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				w.env.Logger.Warn("Failed to list events", zap.String("namespace", w.env.Namespace),
+					zap.String("selector", selector), zap.Error(err))
+				continue
+			}
+			events = append(events, listed...)
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return eventTime(events[i]).Before(eventTime(events[j]))
+		})
+
+		for _, event := range events {
+			// Repeated events keep their UID and bump the count
+			key := fmt.Sprintf("%s/%d", event.UID, event.Count)
+			ts := eventTime(event)
+			if seen[key] || ts.Before(w.env.StartTime.Truncate(time.Second)) || !w.relevant(event) {
+				continue
+			}
+			seen[key] = true
+
+			object := fmt.Sprintf("%s/%s", strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name)
+			fmt.Fprintf(file, "%s %s %s %s: %s\n",
+				ts.Format(time.RFC3339), event.Type, event.Reason, object, event.Message)
+			if event.Type != corev1.EventTypeWarning {
+				continue
+			}
+
+			pod := ""
+			if event.InvolvedObject.Kind == "Pod" {
+				pod = event.InvolvedObject.Name
+			}
+			emit(ErrorEvent{
+				Timestamp: ts,
+				Kind:      EventK8s,
+				Pod:       pod,
+				Source:    "events",
+				Message:   fmt.Sprintf("%s %s: %s", event.Reason, object, event.Message),
+				Rule:      RuleK8sWarning,
+				Severity:  SeverityWarning,
+				Groups:    map[string]string{"reason": event.Reason},
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.env.interval()):
+		}
+	}
+}
+
+// involvedObjects returns the names of the target pods, their deployments and
+// the replica sets owning the pods. Sibling deployments can share a name
+// prefix, so a replica set is only included when a target pod names it as its
+// controller, or failing that through the pod-template-hash label.
+func (w *eventWatcher) involvedObjects() (map[string]bool, error) {
+	objects := make(map[string]bool)
+	for _, target := range w.env.Targets {
+		objects[target.Name] = true
+		if target.Deployment == "" {
+			continue
+		}
+		objects[target.Deployment] = true
+
+		pod, err := w.env.Client.GetPod(w.env.Namespace, target.Name)
+		if errors.Is(err, kubernetes.ErrNotFound) {
+			// The restart watcher reports the deleted pod
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "ReplicaSet" {
+			objects[owner.Name] = true
+		} else if hash := pod.Labels[v1.DefaultDeploymentUniqueLabelKey]; hash != "" {
+			objects[target.Deployment+"-"+hash] = true
+		}
+	}
+	return objects, nil
+}
+
+// selectors returns a field selector for the events of each involved object,
+// so the server filters the events instead of every event of the namespace
+// being listed each poll
+func (w *eventWatcher) selectors() []string {
+	selectors := make([]string, 0, len(w.objects))
+	for name := range w.objects {
+		selectors = append(selectors, "involvedObject.name="+name)
+	}
+	sort.Strings(selectors)
+	return selectors
+}
+
+// relevant reports whether an event concerns a target pod, its deployment or
+// the replica set owning the pod
+func (w *eventWatcher) relevant(event corev1.Event) bool {
+	return w.objects[event.InvolvedObject.Name]
+}
+
+// eventTime returns when an event last occurred
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
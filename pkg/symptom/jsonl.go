@@ -0,0 +1,87 @@
+package symptom
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// defaultJSONLPath is the events file written into the run directory
+const defaultJSONLPath = "events.jsonl"
+
+// jsonlSink appends every event as one JSON object per line. The file is
+// flushed after every batch so it can be followed while the collection runs.
+type jsonlSink struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// newJSONLSink creates the events file, relative to the run directory unless absolute
+func newJSONLSink(env *SinkEnv, cfg config.SinkConfig) (EventSink, error) {
+	path := cfg.Path
+	if path == "" {
+		path = defaultJSONLPath
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.OutputDir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create events directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create events file: %w", err)
+	}
+	return &jsonlSink{path: path, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (s *jsonlSink) Name() string {
+	return fmt.Sprintf("%s %s", SinkJSONL, s.path)
+}
+
+func (s *jsonlSink) Write(ctx context.Context, events []ErrorEvent) error {
+	encoder := json.NewEncoder(s.writer)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonlSink) Close(ctx context.Context) error {
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return s.file.Close()
+}
+
+// ReadEvents reads the events of a JSON Lines file written by the jsonl sink
+func ReadEvents(path string) ([]ErrorEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	defer file.Close()
+
+	var events []ErrorEvent
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var event ErrorEvent
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", len(events)+1, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,212 @@
+package symptom
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+)
+
+// tailLogScript prints the size of "$1" and the byte offset it follows the file
+// from, then runs tail from that offset. Without an offset in "$2" the file is
+// followed from its current end. An offset past the end of the file means it
+// was rotated or truncated, so the new file is followed from its start.
+const tailLogScript = `size=$(stat -c %s "$1" 2>/dev/null || echo 0)
+from=${2:-$size}
+[ "$size" -lt "$from" ] && from=0
+echo "$size $from"
+exec tail -c +$((from + 1)) -F "$1"`
+
+// logFileWatcher follows a log file inside a target container, reports lines
+// matching the error keywords and keeps every line it saw as an artifact
+type logFileWatcher struct {
+	env    *WatcherEnv
+	target TargetPod
+	path   string
+
+	loop      loop
+	mu        sync.Mutex
+	artifacts []Artifact
+}
+
+// newLogFileWatchers creates a watcher for every log path in every target pod
+func newLogFileWatchers(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+	var built []Watcher
+	for _, target := range env.Targets {
+		for _, logPath := range source.Paths {
+			built = append(built, &logFileWatcher{env: env, target: target, path: logPath})
+		}
+	}
+	return built, nil
+}
+
+func (w *logFileWatcher) Name() string {
+	return fmt.Sprintf("%s %s:%s", SourceLogFile, w.target.Name, w.path)
+}
+
+func (w *logFileWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	localPath := w.env.artifactPath("logs", w.target.Name, w.path, ".log")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create log copy: %w", err)
+	}
+
+	w.loop.start(ctx, func(ctx context.Context) {
+		w.watch(ctx, file, emit)
+
+		if err := file.Close(); err != nil {
+			w.env.Logger.Warn("Failed to save log copy", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		artifact, err := newArtifact("log", w.target, w.path, localPath)
+		if err != nil {
+			w.env.Logger.Warn("Failed to record log copy", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		w.mu.Lock()
+		w.artifacts = append(w.artifacts, artifact)
+		w.mu.Unlock()
+	})
+	return nil
+}
+
+func (w *logFileWatcher) Stop(ctx context.Context) error {
+	return w.loop.stop(ctx)
+}
+
+func (w *logFileWatcher) Artifacts() []Artifact {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Artifact(nil), w.artifacts...)
+}
+
+// watch tails the file until ctx ends. The tail is restarted if the exec
+// stream drops, resuming after the last byte read so no line is lost.
+func (w *logFileWatcher) watch(ctx context.Context, copyTo io.Writer, emit EmitFunc) {
+	offset := int64(-1)
+	for {
+		var err error
+		offset, err = w.tail(ctx, offset, copyTo, emit)
+		if ctx.Err() != nil {
+			return
+		}
+		w.env.Logger.Warn("Log tail interrupted, retrying",
+			zap.String("pod", w.target.Name),
+			zap.String("path", w.path),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.env.interval()):
+		}
+	}
+}
+
+// tail runs a single tail -F session from offset, or from the end of the file
+// when offset is negative, and scans its output line by line. It returns the
+// offset after the last byte read.
+func (w *logFileWatcher) tail(ctx context.Context, offset int64, copyTo io.Writer, emit EmitFunc) (int64, error) {
+	reader, writer := io.Pipe()
+	command := []string{"sh", "-c", tailLogScript, "sh", w.path}
+	if offset >= 0 {
+		command = append(command, strconv.FormatInt(offset, 10))
+	}
+
+	execErr := make(chan error, 1)
+	go func() {
+		result, err := w.env.Client.Exec(ctx, kubernetes.ExecOptions{
+			Namespace: w.env.Namespace,
+			Pod:       w.target.Name,
+			Container: w.target.Container,
+			Command:   command,
+			Stdout:    writer,
+			Stderr:    io.Discard,
+		})
+		if err == nil && result.ExitCode != 0 {
+			err = fmt.Errorf("tail exited with code %d", result.ExitCode)
+		}
+		writer.CloseWithError(err)
+		execErr <- err
+	}()
+
+	grouper := w.env.lineGrouper(w.path, func(event ErrorEvent) {
+		event.Pod = w.target.Name
+		event.Container = w.target.Container
+		emit(event)
+	})
+	// read counts the bytes of the lines scanned so far, newlines included
+	var read int64
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		read += int64(advance)
+		return advance, token, err
+	})
+	header := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if header {
+			header = false
+			from, err := w.parseTailHeader(line, offset, emit)
+			if err != nil {
+				// Without the offset the next session follows the file from its end
+				w.env.Logger.Warn("Failed to read log tail offset", zap.String("pod", w.target.Name),
+					zap.String("path", w.path), zap.Error(err))
+				from = -1
+			}
+			offset, read = from, 0
+			continue
+		}
+		fmt.Fprintln(copyTo, line)
+		grouper.add(line)
+	}
+	grouper.close()
+	reader.Close()
+	if !header && offset >= 0 {
+		offset += read
+	}
+
+	if err := <-execErr; err != nil {
+		return offset, err
+	}
+	return offset, scanner.Err()
+}
+
+// parseTailHeader reads the size and offset printed by tailLogScript. When
+// the file no longer reaches the offset a session was resumed from, it was
+// rotated or truncated while not followed, and a log-gap event is emitted.
+func (w *logFileWatcher) parseTailHeader(header string, offset int64, emit EmitFunc) (int64, error) {
+	var size, from int64
+	if _, err := fmt.Sscanf(header, "%d %d", &size, &from); err != nil {
+		return 0, fmt.Errorf("unexpected tail header %q: %w", header, err)
+	}
+	if offset >= 0 && from != offset {
+		emit(ErrorEvent{
+			Timestamp: time.Now(),
+			Kind:      EventLog,
+			Pod:       w.target.Name,
+			Container: w.target.Container,
+			Source:    w.path,
+			Message: fmt.Sprintf("%s shrank from %d to %d bytes while the tail was restarted; lines may have been missed",
+				w.path, offset, size),
+			Rule:     RuleLogGap,
+			Severity: SeverityWarning,
+		})
+	}
+	return from, nil
+}
@@ -0,0 +1,186 @@
+package symptom
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// Multi-line grouping defaults
+const (
+	defaultMultilineMaxLines     = 100
+	defaultMultilineFlushTimeout = 1 * time.Second
+)
+
+// Multiline groups the lines of a multi-line record, such as an error header
+// followed by its backtrace, so it is matched and reported as one block
+type Multiline struct {
+	// Source is a glob on the log path; empty applies to every source
+	Source       string
+	MaxLines     int
+	FlushTimeout time.Duration
+
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+}
+
+// CompileMultiline compiles multi-line grouping configurations in order
+func CompileMultiline(configs []config.MultilineConfig) ([]*Multiline, error) {
+	var compiled []*Multiline
+	for i, cfg := range configs {
+		multiline, err := compileMultiline(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("multiline #%d: %w", i+1, err)
+		}
+		compiled = append(compiled, multiline)
+	}
+	return compiled, nil
+}
+
+func compileMultiline(cfg config.MultilineConfig) (*Multiline, error) {
+	if cfg.Start == "" {
+		return nil, fmt.Errorf("start pattern is required")
+	}
+	start, err := regexp.Compile(cfg.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start pattern: %w", err)
+	}
+	var continuation *regexp.Regexp
+	if cfg.Continuation != "" {
+		if continuation, err = regexp.Compile(cfg.Continuation); err != nil {
+			return nil, fmt.Errorf("invalid continuation pattern: %w", err)
+		}
+	}
+	if _, err := path.Match(cfg.Source, ""); err != nil {
+		return nil, fmt.Errorf("invalid source glob: %w", err)
+	}
+
+	multiline := &Multiline{
+		Source:       cfg.Source,
+		MaxLines:     cfg.MaxLines,
+		FlushTimeout: cfg.FlushTimeout,
+		start:        start,
+		continuation: continuation,
+	}
+	if multiline.MaxLines <= 0 {
+		multiline.MaxLines = defaultMultilineMaxLines
+	}
+	if multiline.FlushTimeout <= 0 {
+		multiline.FlushTimeout = defaultMultilineFlushTimeout
+	}
+	return multiline, nil
+}
+
+// multilineFor returns the first grouping that applies to source, or nil
+func multilineFor(groupings []*Multiline, source string) *Multiline {
+	for _, multiline := range groupings {
+		if multiline.Source == "" {
+			return multiline
+		}
+		if ok, _ := path.Match(multiline.Source, source); ok {
+			return multiline
+		}
+	}
+	return nil
+}
+
+// continues reports whether line belongs to the block being grouped. Without a
+// continuation pattern every line that does not start a new block continues it.
+func (m *Multiline) continues(line string) bool {
+	if m.continuation != nil {
+		return m.continuation.MatchString(line)
+	}
+	return !m.start.MatchString(line)
+}
+
+// lineGrouper passes lines to flush, joining multi-line records into one block.
+// A block ends at the first line that does not continue it or once no line has
+// arrived for the flush timeout. Lines beyond the maximum are counted, not kept.
+type lineGrouper struct {
+	multiline *Multiline
+	flush     func(ts time.Time, block string)
+
+	mu      sync.Mutex
+	lines   []string
+	dropped int
+	ts      time.Time
+	timer   *time.Timer
+	// generation invalidates timers of blocks that were already flushed
+	generation int
+}
+
+// newLineGrouper groups lines with multiline; a nil multiline flushes every line on its own
+func newLineGrouper(multiline *Multiline, flush func(ts time.Time, block string)) *lineGrouper {
+	return &lineGrouper{multiline: multiline, flush: flush}
+}
+
+// add processes the next line, read now
+func (g *lineGrouper) add(line string) {
+	g.addAt(time.Now(), line)
+}
+
+// addAt processes the next line, read at ts. A block takes the time of its first line.
+func (g *lineGrouper) addAt(ts time.Time, line string) {
+	if g.multiline == nil {
+		g.flush(ts, line)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.lines) > 0 && g.multiline.continues(line) {
+		if len(g.lines) < g.multiline.MaxLines {
+			g.lines = append(g.lines, line)
+		} else {
+			g.dropped++
+		}
+		g.timer.Reset(g.multiline.FlushTimeout)
+		return
+	}
+
+	g.flushLocked()
+	if !g.multiline.start.MatchString(line) {
+		g.flush(ts, line)
+		return
+	}
+	g.lines = append(g.lines, line)
+	g.ts = ts
+	generation := g.generation
+	g.timer = time.AfterFunc(g.multiline.FlushTimeout, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.generation == generation {
+			g.flushLocked()
+		}
+	})
+}
+
+// close flushes the pending block
+func (g *lineGrouper) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+func (g *lineGrouper) flushLocked() {
+	if len(g.lines) == 0 {
+		return
+	}
+	g.timer.Stop()
+	g.generation++
+
+	block := strings.Join(g.lines, "\n")
+	if g.dropped > 0 {
+		block += "\n[" + strconv.Itoa(g.dropped) + " lines truncated]"
+	}
+	g.lines = nil
+	g.dropped = 0
+	g.flush(g.ts, block)
+}
@@ -0,0 +1,169 @@
+package symptom
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+func TestCompileMultiline(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []config.MultilineConfig
+		wantErr bool
+	}{
+		{name: "valid", configs: []config.MultilineConfig{{Source: "/dumplog", Start: `^\S`, Continuation: `^\s`}}},
+		{name: "missing start", configs: []config.MultilineConfig{{Continuation: `^\s`}}, wantErr: true},
+		{name: "invalid start", configs: []config.MultilineConfig{{Start: `(`}}, wantErr: true},
+		{name: "invalid continuation", configs: []config.MultilineConfig{{Start: `^\S`, Continuation: `[`}}, wantErr: true},
+		{name: "invalid source glob", configs: []config.MultilineConfig{{Source: `[`, Start: `^\S`}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileMultiline(tt.configs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileMultiline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLineGrouper(t *testing.T) {
+	backtrace := []string{
+		"2024-03-01 12:00:00 ERROR SIGSEGV in uecm",
+		"  #0 0x0000 in Diameter::decode()",
+		"  #1 0x0001 in Session::onAnswer()",
+	}
+
+	tests := []struct {
+		name       string
+		config     *config.MultilineConfig
+		lines      []string
+		wantBlocks []string
+	}{
+		{
+			name:       "no grouping",
+			lines:      backtrace,
+			wantBlocks: backtrace,
+		},
+		{
+			name:   "continuation pattern",
+			config: &config.MultilineConfig{Start: `^\d{4}-`, Continuation: `^\s+#\d+`},
+			lines:  append(append([]string{}, backtrace...), "2024-03-01 12:00:01 INFO restarted", "stray line"),
+			wantBlocks: []string{
+				"2024-03-01 12:00:00 ERROR SIGSEGV in uecm\n  #0 0x0000 in Diameter::decode()\n  #1 0x0001 in Session::onAnswer()",
+				"2024-03-01 12:00:01 INFO restarted",
+				"stray line",
+			},
+		},
+		{
+			name:   "lines not starting a block continue it",
+			config: &config.MultilineConfig{Start: `^\d{4}-`},
+			lines:  append(append([]string{}, backtrace...), "2024-03-01 12:00:01 INFO restarted"),
+			wantBlocks: []string{
+				"2024-03-01 12:00:00 ERROR SIGSEGV in uecm\n  #0 0x0000 in Diameter::decode()\n  #1 0x0001 in Session::onAnswer()",
+				"2024-03-01 12:00:01 INFO restarted",
+			},
+		},
+		{
+			name:   "max lines",
+			config: &config.MultilineConfig{Start: `^\d{4}-`, MaxLines: 2},
+			lines:  backtrace,
+			wantBlocks: []string{
+				"2024-03-01 12:00:00 ERROR SIGSEGV in uecm\n  #0 0x0000 in Diameter::decode()\n[1 lines truncated]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var multiline *Multiline
+			if tt.config != nil {
+				compiled, err := CompileMultiline([]config.MultilineConfig{*tt.config})
+				if err != nil {
+					t.Fatalf("CompileMultiline() error = %v", err)
+				}
+				multiline = compiled[0]
+			}
+
+			var blocks []string
+			grouper := newLineGrouper(multiline, func(ts time.Time, block string) {
+				blocks = append(blocks, block)
+			})
+			for _, line := range tt.lines {
+				grouper.add(line)
+			}
+			grouper.close()
+
+			if !reflect.DeepEqual(blocks, tt.wantBlocks) {
+				t.Errorf("lineGrouper blocks = %q, want %q", blocks, tt.wantBlocks)
+			}
+		})
+	}
+}
+
+func TestLineGrouperFlushTimeout(t *testing.T) {
+	compiled, err := CompileMultiline([]config.MultilineConfig{{Start: `^ERROR`, FlushTimeout: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("CompileMultiline() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	var blocks []string
+	grouper := newLineGrouper(compiled[0], func(ts time.Time, block string) {
+		mu.Lock()
+		defer mu.Unlock()
+		blocks = append(blocks, block)
+	})
+	grouper.add("ERROR decode failed")
+	grouper.add("  at Diameter::decode()")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(blocks)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	grouper.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"ERROR decode failed\n  at Diameter::decode()"}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("lineGrouper blocks after timeout = %q, want %q", blocks, want)
+	}
+}
+
+func TestMultilineFor(t *testing.T) {
+	compiled, err := CompileMultiline([]config.MultilineConfig{
+		{Source: "/dumplog", Start: `^\S`},
+		{Source: "/RTPTrace*", Start: `^\d`},
+	})
+	if err != nil {
+		t.Fatalf("CompileMultiline() error = %v", err)
+	}
+
+	tests := []struct {
+		source string
+		want   *Multiline
+	}{
+		{source: "/dumplog", want: compiled[0]},
+		{source: "/RTPTraceError", want: compiled[1]},
+		{source: "/Envoy", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := multilineFor(compiled, tt.source); got != tt.want {
+				t.Errorf("multilineFor(%s) = %+v, want %+v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Packet capture modes, used as symptom.pcap.mode
+const (
+	PcapAuto      = "auto"
+	PcapExec      = "exec"
+	PcapEphemeral = "ephemeral"
+)
+
+// Packet capture defaults, used when the configuration leaves them unset
+const (
+	defaultPcapInterface = "any"
+	defaultPcapImage     = "nicolaka/netshoot:v0.13"
+	defaultPcapDir       = "/tmp/symptom-pcap"
+	// pcapFileName is the capture file; tcpdump numbers the files when rotating
+	pcapFileName = "capture.pcap"
+	// pcapStartTimeout bounds starting a capture, including pulling the ephemeral image
+	pcapStartTimeout = 2 * time.Minute
+	// pcapStopTimeout bounds stopping a capture and copying its files
+	pcapStopTimeout = 2 * time.Minute
+)
+
+// pcapCheck fails with the tcpdump log unless the tcpdump started in $dir is
+// still running a second after it was started
+const pcapCheck = `sleep 1
+pid=$(cat "$dir/tcpdump.pid" 2>/dev/null)
+if [ -z "$pid" ] || ! kill -0 "$pid" 2>/dev/null || grep -q 'Z (zombie)' "/proc/$pid/status" 2>/dev/null; then
+	cat "$dir/tcpdump.log" >&2
+	exit 1
+fi`
+
+// startPcapScript runs tcpdump with the arguments after "$1" in the background,
+// writing its pid and log into the directory "$1"
+const startPcapScript = `dir=$1; shift
+mkdir -p "$dir" || exit 1
+tcpdump "$@" >"$dir/tcpdump.log" 2>&1 </dev/null &
+echo $! >"$dir/tcpdump.pid"
+` + pcapCheck
+
+// checkPcapScript verifies the capture in the directory "$1"
+const checkPcapScript = `dir=$1
+` + pcapCheck
+
+// runPcapScript is the command of an ephemeral capture container. Once tcpdump
+// exits the container, and so the capture files, are kept until "$1/done"
+// exists, or for an hour at most.
+const runPcapScript = `dir=$1; shift
+mkdir -p "$dir" || exit 1
+tcpdump "$@" >"$dir/tcpdump.log" 2>&1 &
+echo $! >"$dir/tcpdump.pid"
+wait
+i=0
+while [ ! -e "$dir/done" ] && [ $i -lt 3600 ]; do sleep 1; i=$((i+1)); done`
+
+// stopPcapScript interrupts the tcpdump started in "$1", which makes it flush
+// its files, and waits up to 10 seconds for it to exit
+const stopPcapScript = `pid=$(cat "$1/tcpdump.pid") || exit 1
+kill -INT "$pid" 2>/dev/null
+i=0
+while kill -0 "$pid" 2>/dev/null && ! grep -q 'Z (zombie)' "/proc/$pid/status" 2>/dev/null; do
+	i=$((i+1))
+	if [ $i -gt 100 ]; then kill -KILL "$pid"; break; fi
+	sleep 0.1
+done`
+
+// Capture is the outcome of the packet capture in one target pod
+type Capture struct {
+	Pod string `json:"pod"`
+	// Container ran tcpdump: the target container or an ephemeral one
+	Container string `json:"container,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	Interface string `json:"interface"`
+	Filter    string `json:"filter,omitempty"`
+	// Files and Bytes count the capture files copied into the run directory
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+	// Error is why the capture failed to start or stop; empty on success
+	Error string `json:"error,omitempty"`
+}
+
+// pcapMode returns the configured capture mode
+func pcapMode(cfg config.PcapConfig) (string, error) {
+	switch cfg.Mode {
+	case "":
+		return PcapExec, nil
+	case PcapAuto, PcapExec, PcapEphemeral:
+		return cfg.Mode, nil
+	default:
+		return "", fmt.Errorf("unknown pcap mode %q (expected %s, %s or %s)", cfg.Mode, PcapAuto, PcapExec, PcapEphemeral)
+	}
+}
+
+// pcapSession holds the captures of a collection run from enablePcap until disablePcap
+type pcapSession struct {
+	// started is closed once every capture was started or failed to
+	started  chan struct{}
+	captures []*podCapture
+}
+
+func newPcapSession() *pcapSession {
+	return &pcapSession{started: make(chan struct{})}
+}
+
+// podCapture runs tcpdump for one target pod, inside the target container or
+// in an ephemeral container sharing the pod's network namespace
+type podCapture struct {
+	client    kubernetes.ClusterClient
+	cfg       config.PcapConfig
+	mode      string
+	namespace string
+	target    TargetPod
+	dir       string
+	// ephemeral names the ephemeral container created if tcpdump cannot run in the target
+	ephemeral string
+
+	// running is set while a capture needs stopping
+	running bool
+	result  Capture
+}
+
+func newPodCapture(client kubernetes.ClusterClient, cfg config.PcapConfig, mode, namespace string, target TargetPod, startTime time.Time) *podCapture {
+	if cfg.Interface == "" {
+		cfg.Interface = defaultPcapInterface
+	}
+	if cfg.Image == "" {
+		cfg.Image = defaultPcapImage
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultPcapDir
+	}
+	return &podCapture{
+		client:    client,
+		cfg:       cfg,
+		mode:      mode,
+		namespace: namespace,
+		target:    target,
+		dir:       dir,
+		ephemeral: fmt.Sprintf("symptom-pcap-%d", startTime.Unix()),
+		result:    Capture{Pod: target.Name, Interface: cfg.Interface, Filter: cfg.Filter},
+	}
+}
+
+// tcpdumpArgs returns the tcpdump arguments for the configuration
+func (p *podCapture) tcpdumpArgs() []string {
+	args := []string{"-i", p.cfg.Interface, "-w", path.Join(p.dir, pcapFileName)}
+	if p.cfg.Snaplen > 0 {
+		args = append(args, "-s", strconv.Itoa(p.cfg.Snaplen))
+	}
+	if p.cfg.RotateSize > 0 {
+		// tcpdump drops privileges before opening rotated files unless told not to
+		args = append(args, "-C", strconv.Itoa(p.cfg.RotateSize), "-Z", "root")
+		if p.cfg.RotateCount > 0 {
+			args = append(args, "-W", strconv.Itoa(p.cfg.RotateCount))
+		}
+	}
+	if p.cfg.Filter != "" {
+		args = append(args, p.cfg.Filter)
+	}
+	return args
+}
+
+// container returns the container tcpdump runs in
+func (p *podCapture) container() string {
+	if p.result.Mode == PcapEphemeral {
+		return p.ephemeral
+	}
+	return p.target.Container
+}
+
+// exec runs a capture script in the capture container and fails on a non-zero exit
+func (p *podCapture) exec(ctx context.Context, script string, args ...string) error {
+	result, err := p.client.ExecOutput(ctx, kubernetes.ExecOptions{
+		Namespace: p.namespace,
+		Pod:       p.target.Name,
+		Container: p.container(),
+		Command:   append([]string{"sh", "-c", script, "sh"}, args...),
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// start starts tcpdump in the target container when it has tcpdump and the
+// mode allows it, and in an ephemeral container otherwise
+func (p *podCapture) start(ctx context.Context) error {
+	if p.mode != PcapEphemeral {
+		result, err := p.client.ExecOutput(ctx, kubernetes.ExecOptions{
+			Namespace: p.namespace,
+			Pod:       p.target.Name,
+			Container: p.target.Container,
+			Command:   []string{"sh", "-c", "command -v tcpdump"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to look for tcpdump: %w", err)
+		}
+		if result.ExitCode == 0 {
+			return p.startExec(ctx)
+		}
+		if p.mode == PcapExec {
+			return fmt.Errorf("tcpdump not found in container %s", p.target.Container)
+		}
+	}
+	return p.startEphemeral(ctx)
+}
+
+func (p *podCapture) startExec(ctx context.Context) error {
+	p.result.Mode = PcapExec
+	p.result.Container = p.target.Container
+	if err := p.exec(ctx, startPcapScript, append([]string{p.dir}, p.tcpdumpArgs()...)...); err != nil {
+		p.release(ctx)
+		return fmt.Errorf("tcpdump did not start: %w", err)
+	}
+	p.running = true
+	return nil
+}
+
+func (p *podCapture) startEphemeral(ctx context.Context) error {
+	p.result.Mode = PcapEphemeral
+	p.result.Container = p.ephemeral
+	err := p.client.RunEphemeralContainer(ctx, p.namespace, p.target.Name, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    p.ephemeral,
+			Image:   p.cfg.Image,
+			Command: append([]string{"sh", "-c", runPcapScript, "sh", p.dir}, p.tcpdumpArgs()...),
+			SecurityContext: &corev1.SecurityContext{
+				Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN", "NET_RAW"}},
+			},
+		},
+		TargetContainerName: p.target.Container,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start capture container: %w", err)
+	}
+	if err := p.exec(ctx, checkPcapScript, p.dir); err != nil {
+		p.release(ctx)
+		return fmt.Errorf("tcpdump did not start: %w", err)
+	}
+	p.running = true
+	return nil
+}
+
+// stop stops tcpdump, copies the capture files below localDir and removes them from the pod
+func (p *podCapture) stop(ctx context.Context, localDir string) ([]Artifact, error) {
+	p.running = false
+	defer p.release(ctx)
+
+	if err := p.exec(ctx, stopPcapScript, p.dir); err != nil {
+		return nil, fmt.Errorf("failed to stop tcpdump: %w", err)
+	}
+	copied, err := p.client.CopyFromPod(ctx, kubernetes.CopyFromPodOptions{
+		Namespace: p.namespace,
+		Pod:       p.target.Name,
+		Container: p.container(),
+		Paths:     []string{path.Join(p.dir, pcapFileName+"*")},
+		LocalDir:  localDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy capture files: %w", err)
+	}
+
+	var artifacts []Artifact
+	for _, file := range copied.Files {
+		artifact, err := newArtifact("pcap", p.target, file.RemotePath, file.LocalPath)
+		if err != nil {
+			return artifacts, err
+		}
+		artifact.Container = p.container()
+		artifacts = append(artifacts, artifact)
+		p.result.Files++
+		p.result.Bytes += file.Size
+	}
+	return artifacts, nil
+}
+
+// release removes the capture directory from the target container, or lets
+// the ephemeral container exit. Failures only leave files behind, so they are ignored.
+func (p *podCapture) release(ctx context.Context) {
+	script := `rm -rf "$1"`
+	if p.result.Mode == PcapEphemeral {
+		script = `touch "$1/done"`
+	}
+	p.exec(ctx, script, p.dir)
+}
+
+// enablePcap starts a packet capture in every target pod. A pod whose capture
+// cannot start is reported and the others keep capturing.
+func (c *Collector) enablePcap(ctx context.Context, session *pcapSession, config *SymptomCollectionConfig, pods []TargetPod) {
+	defer close(session.started)
+
+	cfg := c.config.Symptom.Pcap
+	if !cfg.Enabled {
+		c.logger.Info("Packet capture is disabled")
+		return
+	}
+	mode, err := pcapMode(cfg)
+	if err != nil {
+		c.logger.Error("Packet capture not started", zap.Error(err))
+		return
+	}
+
+	startCtx, cancel := context.WithTimeout(ctx, pcapStartTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		capture := newPodCapture(c.k8sClient, cfg, mode, config.Namespace, pod, config.StartTime)
+		session.captures = append(session.captures, capture)
+
+		wg.Add(1)
+		pod := pod // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			if err := capture.start(startCtx); err != nil {
+				capture.result.Error = err.Error()
+				c.logger.Warn("Failed to start packet capture",
+					zap.String("pod", pod.Name),
+					zap.String("mode", capture.result.Mode),
+					zap.Error(err),
+				)
+				return
+			}
+			c.logger.Info("Started packet capture",
+				zap.String("pod", pod.Name),
+				zap.String("container", capture.result.Container),
+				zap.String("mode", capture.result.Mode),
+				zap.String("interface", capture.cfg.Interface),
+				zap.String("filter", capture.cfg.Filter),
+			)
+		}()
+	}
+	wg.Wait()
+}
+
+// disablePcap stops the running captures in parallel and copies their files
+// into pcap/<pod>/ of the run directory
+func (c *Collector) disablePcap(ctx context.Context, session *pcapSession, outputDir string) ([]Capture, []Artifact, error) {
+	select {
+	case <-session.started:
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("packet captures still starting: %w", ctx.Err())
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		artifacts []Artifact
+	)
+	for _, capture := range session.captures {
+		if !capture.running {
+			continue
+		}
+		wg.Add(1)
+		capture := capture // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			copied, err := capture.stop(ctx, filepath.Join(outputDir, "pcap", capture.target.Name))
+			mu.Lock()
+			artifacts = append(artifacts, copied...)
+			mu.Unlock()
+			if err != nil {
+				capture.result.Error = err.Error()
+				c.logger.Warn("Failed to collect packet capture",
+					zap.String("pod", capture.target.Name),
+					zap.String("container", capture.result.Container),
+					zap.Error(err),
+				)
+				return
+			}
+			c.logger.Info("Collected packet capture",
+				zap.String("pod", capture.target.Name),
+				zap.Int("files", capture.result.Files),
+				zap.Int64("bytes", capture.result.Bytes),
+			)
+		}()
+	}
+	wg.Wait()
+
+	captures := make([]Capture, 0, len(session.captures))
+	for _, capture := range session.captures {
+		captures = append(captures, capture.result)
+	}
+	return captures, artifacts, nil
+}
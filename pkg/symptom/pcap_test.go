@@ -0,0 +1,205 @@
+package symptom
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTcpdumpArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.PcapConfig
+		want string
+	}{
+		{
+			name: "defaults",
+			want: "-i any -w /tmp/symptom-pcap/capture.pcap",
+		},
+		{
+			name: "snaplen, rotation and filter",
+			cfg:  config.PcapConfig{Interface: "eth0", Snaplen: 256, RotateSize: 100, RotateCount: 5, Filter: "port 3868 or sctp"},
+			want: "-i eth0 -w /tmp/symptom-pcap/capture.pcap -s 256 -C 100 -Z root -W 5 port 3868 or sctp",
+		},
+		{
+			name: "rotation count without size",
+			cfg:  config.PcapConfig{RotateCount: 5, Dir: "/var/tmp/pcap"},
+			want: "-i any -w /var/tmp/pcap/capture.pcap",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := newPodCapture(nil, tt.cfg, PcapAuto, "miniudm", TargetPod{Name: "uecm-a"}, time.Now())
+			if got := strings.Join(capture.tcpdumpArgs(), " "); got != tt.want {
+				t.Errorf("tcpdumpArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPcapMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: PcapExec},
+		{mode: "exec", want: PcapExec},
+		{mode: "ephemeral", want: PcapEphemeral},
+		{mode: "sidecar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := pcapMode(config.PcapConfig{Mode: tt.mode})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("pcapMode(%q) = %q, %v, want %q", tt.mode, got, err, tt.want)
+		}
+	}
+}
+
+// newPcapCollector returns a test collector with packet capture enabled in mode
+// and a fake target pod uecm-a
+func newPcapCollector(t *testing.T, mode string) (*Collector, *fake.Executor) {
+	t.Helper()
+	collector, executor, _ := newTestCollector(t, newPod("miniudm", "uecm-a", "uecm"))
+	collector.config.Symptom.Pcap = config.PcapConfig{Enabled: true, Mode: mode, Interface: "eth0"}
+	return collector, executor
+}
+
+// collectPcap starts and stops the captures of uecm-a
+func collectPcap(t *testing.T, collector *Collector) ([]Capture, []Artifact, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	outputDir := t.TempDir()
+	session := newPcapSession()
+	config := &SymptomCollectionConfig{Namespace: "miniudm", StartTime: time.Unix(1700000000, 0)}
+	collector.enablePcap(ctx, session, config, []TargetPod{{Name: "uecm-a", Container: "mcc"}})
+	captures, artifacts, err := collector.disablePcap(ctx, session, outputDir)
+	if err != nil {
+		t.Fatalf("disablePcap() error = %v", err)
+	}
+	return captures, artifacts, outputDir
+}
+
+func TestPcapExec(t *testing.T) {
+	collector, executor := newPcapCollector(t, PcapAuto)
+	executor.On("uecm-a", "sh -c command -v tcpdump", fake.Reply("/usr/sbin/tcpdump\n", 0))
+	executor.On("uecm-a", "sh -c "+startPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c "+stopPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c tar cf -", tarReply(t, map[string]string{
+		"tmp/symptom-pcap/capture.pcap": "pcap",
+	}))
+	executor.On("uecm-a", `sh -c rm -rf "$1" sh /tmp/symptom-pcap`, fake.Reply("", 0))
+
+	captures, artifacts, outputDir := collectPcap(t, collector)
+
+	want := Capture{Pod: "uecm-a", Container: "mcc", Mode: PcapExec, Interface: "eth0", Files: 1, Bytes: 4}
+	if len(captures) != 1 || captures[0] != want {
+		t.Fatalf("disablePcap() captures = %+v, want %+v", captures, want)
+	}
+	wantPath := filepath.Join(outputDir, "pcap", "uecm-a", "tmp", "symptom-pcap", "capture.pcap")
+	if len(artifacts) != 1 || artifacts[0].Kind != "pcap" || artifacts[0].Path != wantPath {
+		t.Errorf("disablePcap() artifacts = %+v, want %s", artifacts, wantPath)
+	}
+
+	var removed bool
+	for _, call := range executor.Calls() {
+		if call.Container != "mcc" {
+			t.Errorf("capture command ran in container %q, want mcc", call.Container)
+		}
+		removed = removed || strings.HasPrefix(strings.Join(call.Command, " "), `sh -c rm -rf`)
+	}
+	if !removed {
+		t.Error("disablePcap() did not remove the capture directory")
+	}
+}
+
+func TestPcapEphemeral(t *testing.T) {
+	collector, executor := newPcapCollector(t, PcapAuto)
+	clientset := collector.k8sClient.(*kubernetes.Client).Clientset.(*k8sfake.Clientset)
+
+	var created []corev1.EphemeralContainer
+	clientset.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		pod := action.(k8stesting.UpdateAction).GetObject().(*corev1.Pod)
+		created = pod.Spec.EphemeralContainers
+		for _, container := range created {
+			pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+				Name:  container.Name,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			})
+		}
+		return false, nil, nil
+	})
+
+	executor.On("uecm-a", "sh -c command -v tcpdump", fake.Reply("", 1))
+	executor.On("uecm-a", "sh -c "+checkPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c "+stopPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c tar cf -", tarReply(t, map[string]string{
+		"tmp/symptom-pcap/capture.pcap": "pcap",
+	}))
+	executor.On("uecm-a", `sh -c touch "$1/done" sh /tmp/symptom-pcap`, fake.Reply("", 0))
+
+	captures, artifacts, _ := collectPcap(t, collector)
+
+	if len(captures) != 1 || captures[0].Mode != PcapEphemeral || captures[0].Error != "" || captures[0].Files != 1 {
+		t.Fatalf("disablePcap() captures = %+v, want one ephemeral capture", captures)
+	}
+	if len(artifacts) != 1 || artifacts[0].Container != "symptom-pcap-1700000000" {
+		t.Errorf("disablePcap() artifacts = %+v, want the file of the ephemeral container", artifacts)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("enablePcap() created %d ephemeral containers, want 1", len(created))
+	}
+	container := created[0]
+	if container.TargetContainerName != "mcc" || container.Image != defaultPcapImage {
+		t.Errorf("enablePcap() ephemeral container targets %q with %q, want mcc with %q",
+			container.TargetContainerName, container.Image, defaultPcapImage)
+	}
+	if command := strings.Join(container.Command, " "); !strings.HasSuffix(command, "sh /tmp/symptom-pcap -i eth0 -w /tmp/symptom-pcap/capture.pcap") {
+		t.Errorf("enablePcap() ephemeral command = %q, want tcpdump on eth0", command)
+	}
+
+	var done bool
+	for _, call := range executor.Calls() {
+		if call.Container == "symptom-pcap-1700000000" && strings.HasPrefix(strings.Join(call.Command, " "), `sh -c touch`) {
+			done = true
+		}
+	}
+	if !done {
+		t.Error("disablePcap() did not release the ephemeral container")
+	}
+}
+
+func TestPcapStartFailure(t *testing.T) {
+	collector, executor := newPcapCollector(t, PcapExec)
+	executor.On("uecm-a", "sh -c command -v tcpdump", fake.Reply("", 1))
+
+	captures, artifacts, _ := collectPcap(t, collector)
+
+	if len(captures) != 1 || !strings.Contains(captures[0].Error, "tcpdump not found in container mcc") {
+		t.Fatalf("disablePcap() captures = %+v, want the start failure", captures)
+	}
+	if len(artifacts) != 0 {
+		t.Errorf("disablePcap() artifacts = %+v, want none", artifacts)
+	}
+	if calls := executor.Calls(); len(calls) != 1 {
+		t.Errorf("executed %d commands, want only the tcpdump lookup", len(calls))
+	}
+}
@@ -0,0 +1,254 @@
+package symptom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Pipeline defaults, used when the configuration leaves them unset
+const (
+	defaultRateLimit = 10
+	defaultRateBurst = 50
+	defaultMaxEvents = 10000
+)
+
+// volatilePatterns mask the parts of a message that differ between repeats of
+// the same error, in order: UUIDs, hex values, IMSIs and other numbers
+var volatilePatterns = []struct {
+	pattern *regexp.Regexp
+	mask    string
+}{
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b`), "<hex>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), "<hex>"},
+	{regexp.MustCompile(`\d+`), "<n>"},
+}
+
+// EventCounts counts what happened to the events reported by watchers
+type EventCounts struct {
+	// Received is every event a watcher emitted
+	Received int `json:"received"`
+	// Recorded is the number of distinct events kept in the result
+	Recorded int `json:"recorded"`
+	// Duplicates were folded into an earlier event with the same fingerprint
+	Duplicates int `json:"duplicates"`
+	// RateLimited were discarded because their source exceeded its rate limit
+	RateLimited int `json:"rate_limited"`
+	// Dropped were discarded because the result already held symptom.max_events
+	Dropped int `json:"dropped"`
+}
+
+// EventStats summarises the error pipeline of a collection run
+type EventStats struct {
+	EventCounts
+	// Sources breaks the counts down by "<pod>:<source>"
+	Sources map[string]*EventCounts `json:"sources"`
+}
+
+// pipeline receives watcher events without ever blocking them. Repeats of an
+// event are folded into the first occurrence, new events are rate limited per
+// source and the number of kept events is bounded.
+type pipeline struct {
+	logger    *zap.Logger
+	sinks     *fanout
+	rateLimit rate.Limit
+	rateBurst int
+	maxEvents int
+
+	mu      sync.Mutex
+	closed  bool
+	events  []*ErrorEvent
+	byPrint map[string]*ErrorEvent
+	// repeated holds the fingerprints of the recorded events that later
+	// occurrences were folded into
+	repeated map[string]bool
+	limiters map[string]*rate.Limiter
+	stats    EventStats
+}
+
+// newPipeline creates a pipeline publishing recorded events to sinks, which may be nil
+func newPipeline(cfg *config.Config, logger *zap.Logger, sinks *fanout) *pipeline {
+	p := &pipeline{
+		logger:    logger,
+		sinks:     sinks,
+		rateLimit: rate.Limit(cfg.Symptom.RateLimit),
+		rateBurst: cfg.Symptom.RateBurst,
+		maxEvents: cfg.Symptom.MaxEvents,
+		byPrint:   make(map[string]*ErrorEvent),
+		repeated:  make(map[string]bool),
+		limiters:  make(map[string]*rate.Limiter),
+		stats:     EventStats{Sources: make(map[string]*EventCounts)},
+	}
+	if p.rateLimit <= 0 {
+		p.rateLimit = defaultRateLimit
+	}
+	if p.rateBurst <= 0 {
+		p.rateBurst = defaultRateBurst
+	}
+	if p.maxEvents <= 0 {
+		p.maxEvents = defaultMaxEvents
+	}
+	return p
+}
+
+// emit implements EmitFunc. Events emitted after close are ignored.
+func (p *pipeline) emit(event ErrorEvent) {
+	if event.Count == 0 {
+		event.Count = 1
+	}
+	event.FirstSeen = event.Timestamp
+	event.LastSeen = event.Timestamp
+	event.Fingerprint = fingerprint(event)
+	source := event.Pod + ":" + event.Source
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	counts := p.stats.Sources[source]
+	if counts == nil {
+		counts = &EventCounts{}
+		p.stats.Sources[source] = counts
+	}
+	counts.Received++
+	p.stats.Received++
+
+	// Every core is kept; each one is a separate crash
+	if event.Core == nil {
+		if first, ok := p.byPrint[event.Fingerprint]; ok {
+			p.repeated[event.Fingerprint] = true
+			first.Count += event.Count
+			if event.Timestamp.After(first.LastSeen) {
+				first.LastSeen = event.Timestamp
+			}
+			counts.Duplicates++
+			p.stats.Duplicates++
+			p.mu.Unlock()
+			return
+		}
+		if !p.limiter(source).Allow() {
+			counts.RateLimited++
+			p.stats.RateLimited++
+			p.mu.Unlock()
+			return
+		}
+	}
+	if len(p.events) >= p.maxEvents {
+		counts.Dropped++
+		p.stats.Dropped++
+		p.mu.Unlock()
+		return
+	}
+
+	recorded := event
+	p.events = append(p.events, &recorded)
+	if event.Core == nil {
+		p.byPrint[event.Fingerprint] = &recorded
+	}
+	counts.Recorded++
+	p.stats.Recorded++
+	// Publishing never blocks; holding the lock keeps it from racing close
+	if p.sinks != nil {
+		p.sinks.publish(event)
+	}
+	p.mu.Unlock()
+
+	p.log(event)
+}
+
+// limiter returns the rate limiter of a source
+func (p *pipeline) limiter(source string) *rate.Limiter {
+	limiter, ok := p.limiters[source]
+	if !ok {
+		limiter = rate.NewLimiter(p.rateLimit, p.rateBurst)
+		p.limiters[source] = limiter
+	}
+	return limiter
+}
+
+// log reports a newly recorded event
+func (p *pipeline) log(event ErrorEvent) {
+	if event.Kind == EventCore {
+		p.logger.Error("Core dump detected during symptom collection",
+			zap.Time("timestamp", event.Timestamp),
+			zap.String("pod", event.Pod),
+			zap.String("path", event.Core.Path),
+			zap.String("process", event.Core.Process),
+			zap.Int("pid", event.Core.PID),
+			zap.String("signal", event.Core.SignalName()),
+			zap.Int64("size", event.Core.Size),
+			zap.String("sha256", event.Core.SHA256),
+		)
+		return
+	}
+	p.logger.Error("Error detected during symptom collection",
+		zap.Time("timestamp", event.Timestamp),
+		zap.String("pod", event.Pod),
+		zap.String("source", event.Source),
+		zap.String("rule", event.Rule),
+		zap.String("severity", string(event.Severity)),
+		zap.String("message", event.Message),
+	)
+}
+
+// close stops accepting events and returns the recorded events in order of
+// first occurrence together with the pipeline counters. Sinks only received
+// the first occurrence of each event, so every event that repeated is
+// published again as a summary with its final count.
+func (p *pipeline) close() ([]ErrorEvent, EventStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+
+	if p.sinks != nil {
+		for _, event := range p.events {
+			if p.repeated[event.Fingerprint] && event.Core == nil {
+				summary := *event
+				summary.Summary = true
+				p.sinks.publish(summary)
+			}
+		}
+	}
+
+	events := make([]ErrorEvent, 0, len(p.events))
+	for _, event := range p.events {
+		events = append(events, *event)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	stats := EventStats{EventCounts: p.stats.EventCounts, Sources: make(map[string]*EventCounts, len(p.stats.Sources))}
+	for source, counts := range p.stats.Sources {
+		copied := *counts
+		stats.Sources[source] = &copied
+	}
+	return events, stats
+}
+
+// fingerprint identifies repeats of an event: the same kind, rule, pod, container
+// and source, and the same message once volatile values are masked
+func fingerprint(event ErrorEvent) string {
+	hash := sha256.New()
+	for _, part := range []string{string(event.Kind), event.Rule, event.Pod, event.Container, event.Source, normalizeMessage(event.Message)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// normalizeMessage masks the volatile values of a message
+func normalizeMessage(message string) string {
+	for _, volatile := range volatilePatterns {
+		message = volatile.pattern.ReplaceAllString(message, volatile.mask)
+	}
+	return message
+}
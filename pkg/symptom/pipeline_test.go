@@ -0,0 +1,161 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := ErrorEvent{Kind: EventLog, Pod: "uecm-a", Source: "/Envoy", Rule: "error",
+		Message: "2024-03-01 12:00:00 ERROR upstream reset for imsi-262011234567890 stream 0x7f3a"}
+
+	tests := []struct {
+		name     string
+		event    ErrorEvent
+		wantSame bool
+	}{
+		{
+			name: "numbers and ids differ",
+			event: ErrorEvent{Kind: EventLog, Pod: "uecm-a", Source: "/Envoy", Rule: "error",
+				Message: "2024-03-01 12:00:07 ERROR upstream reset for imsi-262019999999999 stream 0x11"},
+			wantSame: true,
+		},
+		{
+			name: "different source",
+			event: ErrorEvent{Kind: EventLog, Pod: "uecm-a", Source: "/dumplog", Rule: "error",
+				Message: base.Message},
+		},
+		{
+			name: "different pod",
+			event: ErrorEvent{Kind: EventLog, Pod: "uecm-b", Source: "/Envoy", Rule: "error",
+				Message: base.Message},
+		},
+		{
+			name: "different text",
+			event: ErrorEvent{Kind: EventLog, Pod: "uecm-a", Source: "/Envoy", Rule: "error",
+				Message: "2024-03-01 12:00:00 ERROR connect timeout for imsi-262011234567890 stream 0x7f3a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := fingerprint(tt.event) == fingerprint(base); same != tt.wantSame {
+				t.Errorf("fingerprint() equal = %v, want %v (%q vs %q)", same, tt.wantSame,
+					normalizeMessage(tt.event.Message), normalizeMessage(base.Message))
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	logEvent := func(source, message string, offset time.Duration) ErrorEvent {
+		return ErrorEvent{Timestamp: start.Add(offset), Kind: EventLog, Pod: "uecm-a", Source: source, Message: message}
+	}
+
+	p := newPipeline(&config.Config{Symptom: config.SymptomConfig{RateLimit: 0.001, RateBurst: 3, MaxEvents: 5}}, zap.NewNop(), nil)
+
+	// One error repeated 100 times
+	for i := 0; i < 100; i++ {
+		p.emit(logEvent("/Envoy", fmt.Sprintf("ERROR upstream reset stream %d", i), time.Duration(i)*time.Second))
+	}
+	// Distinct errors beyond the burst of the source
+	for i := 0; i < 4; i++ {
+		p.emit(logEvent("/dumplog", fmt.Sprintf("ERROR decode failed in %c", 'a'+i), 0))
+	}
+	// Cores are neither folded nor rate limited, but are bounded by max events
+	for i := 0; i < 3; i++ {
+		p.emit(ErrorEvent{Timestamp: start, Kind: EventCore, Pod: "uecm-a", Source: "/logstore/TspCore",
+			Message: fmt.Sprintf("core %d", i), Core: &CoreInfo{}})
+	}
+
+	events, stats := p.close()
+	p.emit(logEvent("/Envoy", "ERROR after close", 0))
+
+	if len(events) != 5 {
+		t.Fatalf("close() returned %d events, want 5", len(events))
+	}
+	first := events[0]
+	if first.Count != 100 || !first.FirstSeen.Equal(start) || !first.LastSeen.Equal(start.Add(99*time.Second)) {
+		t.Errorf("folded event count = %d, seen %s - %s, want 100 between %s and %s",
+			first.Count, first.FirstSeen, first.LastSeen, start, start.Add(99*time.Second))
+	}
+
+	want := EventCounts{Received: 107, Recorded: 5, Duplicates: 99, RateLimited: 1, Dropped: 2}
+	if stats.EventCounts != want {
+		t.Errorf("close() stats = %+v, want %+v", stats.EventCounts, want)
+	}
+	if got := stats.Sources["uecm-a:/dumplog"]; got == nil || got.Recorded != 3 || got.RateLimited != 1 {
+		t.Errorf("close() /dumplog stats = %+v, want 3 recorded and 1 rate limited", got)
+	}
+	if got := stats.Sources["uecm-a:/logstore/TspCore"]; got == nil || got.Recorded != 1 || got.Dropped != 2 {
+		t.Errorf("close() core stats = %+v, want 1 recorded and 2 dropped", got)
+	}
+}
+
+func TestPipelineDoesNotBlock(t *testing.T) {
+	p := newPipeline(&config.Config{}, zap.NewNop(), nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			p.emit(ErrorEvent{Timestamp: time.Now(), Kind: EventLog, Source: "/Envoy", Message: fmt.Sprintf("ERROR storm %c%d", 'a'+i%26, i)})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("emit() blocked during an error storm")
+	}
+	if _, stats := p.close(); stats.Received != 10000 || stats.Recorded > defaultRateBurst+defaultRateLimit*10 {
+		t.Errorf("close() stats = %+v, want every event received and the storm rate limited", stats.EventCounts)
+	}
+}
+
+func TestPipelineRepeatSummary(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := newJSONLSink(&SinkEnv{OutputDir: dir}, config.SinkConfig{})
+	if err != nil {
+		t.Fatalf("newJSONLSink() error = %v", err)
+	}
+	sinks := newFanout(zap.NewNop(), []sinkWithConfig{{sink: sink}})
+	sinks.start(context.Background())
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := newPipeline(&config.Config{}, zap.NewNop(), sinks)
+	for i := 0; i < 500; i++ {
+		p.emit(ErrorEvent{Timestamp: start.Add(time.Duration(i) * time.Second), Kind: EventLog, Pod: "uecm-a",
+			Source: "/Envoy", Message: fmt.Sprintf("ERROR upstream reset stream %d", i)})
+	}
+	p.emit(ErrorEvent{Timestamp: start, Kind: EventLog, Pod: "uecm-a", Source: "/dumplog", Message: "ERROR decode failed"})
+	p.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sinks.close(ctx)
+
+	got, err := ReadEvents(filepath.Join(dir, defaultJSONLPath))
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	// The first occurrences as they were detected, then the summary of the repeated one
+	if len(got) != 3 {
+		t.Fatalf("ReadEvents() = %+v, want 2 events and 1 summary", got)
+	}
+	if got[0].Count != 1 || got[0].Summary || got[1].Summary {
+		t.Errorf("ReadEvents() first occurrences = %+v, %+v, want count 1 and no summary", got[0], got[1])
+	}
+	summary := got[2]
+	if !summary.Summary || summary.Count != 500 || summary.Fingerprint != got[0].Fingerprint ||
+		!summary.LastSeen.Equal(start.Add(499*time.Second)) {
+		t.Errorf("ReadEvents() summary = %+v, want the /Envoy error with count 500", summary)
+	}
+}
@@ -0,0 +1,135 @@
+package symptom
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podLogWatcher follows the container log of a target pod through the API
+// server, reports lines matching the error keywords and saves the log
+type podLogWatcher struct {
+	env    *WatcherEnv
+	target TargetPod
+
+	loop      loop
+	mu        sync.Mutex
+	artifacts []Artifact
+}
+
+// newPodLogWatchers creates a watcher for every target pod
+func newPodLogWatchers(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+	built := make([]Watcher, 0, len(env.Targets))
+	for _, target := range env.Targets {
+		built = append(built, &podLogWatcher{env: env, target: target})
+	}
+	return built, nil
+}
+
+func (w *podLogWatcher) Name() string {
+	return fmt.Sprintf("%s %s/%s", SourcePodLogs, w.target.Name, w.target.Container)
+}
+
+func (w *podLogWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	localPath := filepath.Join(w.env.OutputDir, "podlogs", w.target.Name, w.target.Container+".log")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create pod log directory: %w", err)
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create pod log file: %w", err)
+	}
+
+	w.loop.start(ctx, func(ctx context.Context) {
+		w.watch(ctx, file, emit)
+
+		if err := file.Close(); err != nil {
+			w.env.Logger.Warn("Failed to save pod log", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		artifact, err := newArtifact("podlog", w.target, "container log", localPath)
+		if err != nil {
+			w.env.Logger.Warn("Failed to record pod log", zap.String("path", localPath), zap.Error(err))
+			return
+		}
+		w.mu.Lock()
+		w.artifacts = append(w.artifacts, artifact)
+		w.mu.Unlock()
+	})
+	return nil
+}
+
+func (w *podLogWatcher) Stop(ctx context.Context) error {
+	return w.loop.stop(ctx)
+}
+
+func (w *podLogWatcher) Artifacts() []Artifact {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Artifact(nil), w.artifacts...)
+}
+
+// watch follows the log until ctx ends, reopening the stream when it drops
+// (for example after a container restart)
+func (w *podLogWatcher) watch(ctx context.Context, copyTo io.Writer, emit EmitFunc) {
+	since := w.env.StartTime
+	for {
+		err := w.follow(ctx, since, copyTo, emit)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.env.Logger.Warn("Pod log stream interrupted, retrying",
+				zap.String("pod", w.target.Name),
+				zap.String("container", w.target.Container),
+				zap.Error(err),
+			)
+		}
+		since = time.Now()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.env.interval()):
+		}
+	}
+}
+
+// follow streams the log from since until the stream ends
+func (w *podLogWatcher) follow(ctx context.Context, since time.Time, copyTo io.Writer, emit EmitFunc) error {
+	stream, err := w.env.Client.StreamPodLogs(ctx, w.env.Namespace, w.target.Name, &corev1.PodLogOptions{
+		Container: w.target.Container,
+		Follow:    true,
+		SinceTime: &metav1.Time{Time: since},
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	grouper := w.env.lineGrouper("container log", func(event ErrorEvent) {
+		event.Pod = w.target.Name
+		event.Container = w.target.Container
+		emit(event)
+	})
+	defer grouper.close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(copyTo, line)
+		grouper.add(line)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,346 @@
+package symptom
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+	"go.uber.org/zap"
+)
+
+// PybotStatus is the outcome of a pybot run
+type PybotStatus string
+
+// Pybot run outcomes
+const (
+	PybotPassed    PybotStatus = "passed"
+	PybotFailed    PybotStatus = "failed"
+	PybotError     PybotStatus = "error"
+	PybotTimeout   PybotStatus = "timeout"
+	PybotCancelled PybotStatus = "cancelled"
+	// PybotSkipped means no suites were configured, so nothing was run
+	PybotSkipped PybotStatus = "skipped"
+)
+
+// ErrCollectionTimeout is the cancellation cause once Symptom.CollectionTimeout
+// expires, and is wrapped by the error of a collection whose pybot run it stopped
+var ErrCollectionTimeout = errors.New("collection timeout expired")
+
+// pybotArtifacts are the files Robot Framework writes to its output directory
+var pybotArtifacts = []string{"output.xml", "log.html", "report.html"}
+
+// pybotOutputLines is how many trailing output lines are kept in PybotResult
+const pybotOutputLines = 200
+
+// pybotFetchTimeout bounds copying the pybot output, which also runs after
+// the collection was interrupted
+const pybotFetchTimeout = 2 * time.Minute
+
+// pybotPIDFile records the PID of the pybot process on the testclient pod
+const pybotPIDFile = "/tmp/symptom-pybot.pid"
+
+// runPybotScript writes its PID to "$1" and replaces itself with the command
+// that follows, so that the PID is pybot's
+const runPybotScript = `echo $$ > "$1" && shift && exec "$@"`
+
+// stopPybotScript stops the process whose PID is in "$1". Robot Framework
+// stops gracefully on SIGTERM and writes its output; a pybot that is still
+// running after stopPybotGrace seconds is killed.
+const stopPybotScript = `pid=$(cat "$1" 2>/dev/null) || exit 0
+rm -f "$1"
+kill -TERM "$pid" 2>/dev/null || exit 0
+i=0
+while kill -0 "$pid" 2>/dev/null && [ "$i" -lt "$2" ]; do sleep 1; i=$((i + 1)); done
+kill -KILL "$pid" 2>/dev/null
+true`
+
+// Stopping pybot once the collection ends before it finished
+const (
+	stopPybotGrace   = 30
+	stopPybotTimeout = stopPybotGrace*time.Second + 30*time.Second
+)
+
+// PybotResult describes a pybot run on the testclient pod
+type PybotResult struct {
+	Namespace string
+	Pod       string
+	Container string
+	Command   []string
+	StartTime time.Time
+	EndTime   time.Time
+	Status    PybotStatus
+	ExitCode  int
+	// FailedTests is the number of failed tests reported by the exit code;
+	// Robot Framework caps it at 250
+	FailedTests int
+	// Output holds the last lines pybot printed
+	Output []string
+	Error  string
+	// Artifacts are the local copies of output.xml, log.html and report.html
+	Artifacts []string
+	// Robot is the parsed output.xml, or nil if it could not be fetched
+	Robot *robot.Result
+}
+
+// Duration returns how long the run took
+func (r *PybotResult) Duration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// pybotStatus maps a Robot Framework exit code onto a status and failed test count
+func pybotStatus(exitCode int) (PybotStatus, int) {
+	switch {
+	case exitCode == 0:
+		return PybotPassed, 0
+	case exitCode >= 1 && exitCode <= 250:
+		return PybotFailed, exitCode
+	case exitCode == 253:
+		return PybotCancelled, 0
+	default:
+		// 251 help, 252 invalid data or options, 255 internal error
+		return PybotError, 0
+	}
+}
+
+// buildPybotCommand turns the pybot config into the command run inside the pod.
+// A work directory is entered through sh so the command itself is not quoted.
+func buildPybotCommand(cfg config.PybotConfig) []string {
+	command := append([]string{}, cfg.Command...)
+	if len(command) == 0 {
+		command = []string{"pybot"}
+	}
+	if cfg.OutputDir != "" {
+		command = append(command, "--outputdir", cfg.OutputDir)
+	}
+	for _, test := range cfg.Tests {
+		command = append(command, "--test", test)
+	}
+	for _, variable := range cfg.Variables {
+		command = append(command, "--variable", variable)
+	}
+	command = append(command, cfg.ExtraArgs...)
+	command = append(command, cfg.Suites...)
+
+	if cfg.WorkDir != "" {
+		command = append([]string{"sh", "-c", `cd "$1" && shift && exec "$@"`, "sh", cfg.WorkDir}, command...)
+	}
+	return command
+}
+
+// pybotNamespace returns the namespace of the testclient pod
+func (c *Collector) pybotNamespace(namespace string) string {
+	if c.config.Pybot.Namespace != "" {
+		return c.config.Pybot.Namespace
+	}
+	return namespace
+}
+
+// resolvePybotPod finds the running testclient pod container pybot is run in.
+// It returns nil when no suites are configured.
+func (c *Collector) resolvePybotPod(namespace string) (*TargetPod, error) {
+	cfg := c.config.Pybot
+	if len(cfg.Suites) == 0 {
+		return nil, nil
+	}
+	name := cfg.Pod
+	if name == "" {
+		name = "testclient"
+	}
+
+	// The target match mode, e.g. -m regex, does not apply to the testclient
+	mode, err := kubernetes.ParseMatchMode(cfg.MatchMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pybot match mode: %w", err)
+	}
+	targets, err := c.k8sClient.ResolveTargets(namespace, []string{name}, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find testclient pod: %w", err)
+	}
+
+	target := targets[0]
+	pod := target.Pods[0]
+	container := cfg.Container
+	if container == "" && len(pod.Containers) > 0 {
+		container = pod.Containers[0]
+	}
+	return &TargetPod{
+		Target:     target.Name,
+		Deployment: target.Deployment.Name,
+		Name:       pod.Name,
+		Container:  container,
+	}, nil
+}
+
+// executePybot runs pybot on the testclient pod, streaming its output to the log,
+// and returns once it exits or ctx ends. Ending ctx only closes the exec stream,
+// so pybot is then stopped on the pod as well. When no suites are configured
+// there is nothing to run and it only waits for ctx.
+func (c *Collector) executePybot(ctx context.Context, namespace string, pod *TargetPod) *PybotResult {
+	result := &PybotResult{
+		Namespace: namespace,
+		StartTime: time.Now(),
+	}
+
+	if pod == nil {
+		c.logger.Info("No pybot suites configured, collecting until the collection timeout")
+		<-ctx.Done()
+		result.Status = PybotSkipped
+		result.EndTime = time.Now()
+		return result
+	}
+
+	result.Pod = pod.Name
+	result.Container = pod.Container
+	result.Command = buildPybotCommand(c.config.Pybot)
+	c.logger.Info("Executing pybot command on testclient pod",
+		zap.String("pod", pod.Name),
+		zap.Strings("command", result.Command),
+	)
+
+	reader, writer := io.Pipe()
+	execDone := make(chan struct{})
+	var execResult *kubernetes.ExecResult
+	var execErr error
+	go func() {
+		defer close(execDone)
+		command := append([]string{"sh", "-c", runPybotScript, "sh", pybotPIDFile}, result.Command...)
+		execResult, execErr = c.k8sClient.Exec(ctx, kubernetes.ExecOptions{
+			Namespace: namespace,
+			Pod:       pod.Name,
+			Container: pod.Container,
+			Command:   command,
+			Stdout:    writer,
+			Stderr:    writer,
+		})
+		writer.CloseWithError(execErr)
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		c.logger.Info("Pybot output", zap.String("pod", pod.Name), zap.String("line", line))
+		result.Output = append(result.Output, line)
+		if len(result.Output) > pybotOutputLines {
+			result.Output = result.Output[1:]
+		}
+	}
+	reader.Close()
+	<-execDone
+	if ctx.Err() != nil {
+		c.stopPybot(ctx, namespace, pod)
+	}
+
+	switch {
+	case errors.Is(context.Cause(ctx), ErrCollectionTimeout):
+		result.Status = PybotTimeout
+		result.Error = "collection timeout expired before pybot finished"
+	case ctx.Err() != nil:
+		result.Status = PybotCancelled
+		result.Error = ctx.Err().Error()
+	case execErr != nil:
+		result.Status = PybotError
+		result.Error = execErr.Error()
+	default:
+		result.ExitCode = execResult.ExitCode
+		result.Status, result.FailedTests = pybotStatus(execResult.ExitCode)
+	}
+	result.EndTime = time.Now()
+
+	c.logger.Info("Pybot command completed",
+		zap.String("status", string(result.Status)),
+		zap.Int("exit_code", result.ExitCode),
+		zap.Int("failed_tests", result.FailedTests),
+		zap.Duration("duration", result.Duration()),
+	)
+	return result
+}
+
+// stopPybot stops the pybot process left running on the testclient pod after
+// ctx ended, so that it does not clash with the next run
+func (c *Collector) stopPybot(ctx context.Context, namespace string, pod *TargetPod) {
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopPybotTimeout)
+	defer cancel()
+
+	c.logger.Info("Stopping pybot on testclient pod", zap.String("pod", pod.Name))
+	result, err := c.k8sClient.ExecOutput(stopCtx, kubernetes.ExecOptions{
+		Namespace: namespace,
+		Pod:       pod.Name,
+		Container: pod.Container,
+		Command:   []string{"sh", "-c", stopPybotScript, "sh", pybotPIDFile, strconv.Itoa(stopPybotGrace)},
+	})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	if err != nil {
+		c.logger.Warn("Failed to stop pybot on testclient pod", zap.String("pod", pod.Name), zap.Error(err))
+	}
+}
+
+// fetchPybotOutput copies the Robot Framework output files from the testclient pod
+// into localDir and parses output.xml into result.Robot
+func (c *Collector) fetchPybotOutput(ctx context.Context, pod *TargetPod, result *PybotResult, localDir string) error {
+	remoteDir := c.config.Pybot.OutputDir
+	if remoteDir == "" {
+		return fmt.Errorf("pybot output directory is not configured")
+	}
+	if !path.IsAbs(remoteDir) && c.config.Pybot.WorkDir != "" {
+		remoteDir = path.Join(c.config.Pybot.WorkDir, remoteDir)
+	}
+
+	paths := make([]string, 0, len(pybotArtifacts))
+	for _, name := range pybotArtifacts {
+		paths = append(paths, path.Join(remoteDir, name))
+	}
+
+	copied, err := c.k8sClient.CopyFromPod(ctx, kubernetes.CopyFromPodOptions{
+		Namespace: result.Namespace,
+		Pod:       pod.Name,
+		Container: pod.Container,
+		Paths:     paths,
+		LocalDir:  localDir,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy pybot output: %w", err)
+	}
+
+	outputXML := ""
+	for _, file := range copied.Files {
+		result.Artifacts = append(result.Artifacts, file.LocalPath)
+		if filepath.Base(file.LocalPath) == "output.xml" {
+			outputXML = file.LocalPath
+		}
+	}
+	if outputXML == "" {
+		return fmt.Errorf("output.xml not found in %s", remoteDir)
+	}
+
+	loc, err := time.LoadLocation(c.config.Pybot.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid pybot timezone: %w", err)
+	}
+	parsed, err := robot.ParseFile(outputXML, loc)
+	if err != nil {
+		return err
+	}
+	result.Robot = parsed
+
+	passed, failed, skipped := parsed.Totals()
+	c.logger.Info("Parsed pybot results",
+		zap.String("path", outputXML),
+		zap.Int("passed", passed),
+		zap.Int("failed", failed),
+		zap.Int("skipped", skipped),
+	)
+	return nil
+}
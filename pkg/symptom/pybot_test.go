@@ -0,0 +1,267 @@
+package symptom
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testOutputXML = `<?xml version="1.0" encoding="UTF-8"?>
+<robot generator="Robot 6.1.1" generated="20240301 12:00:00.000">
+<suite id="s1" name="Uecm">
+<test id="s1-t1" name="TC_UECM_Register_06">
+<status status="PASS" starttime="20240301 12:00:00.000" endtime="20240301 12:00:05.000"/>
+</test>
+<test id="s1-t2" name="TC_UECM_Register_07">
+<status status="FAIL" starttime="20240301 12:00:05.000" endtime="20240301 12:00:10.000">HTTP 500 != 201</status>
+</test>
+<status status="FAIL" starttime="20240301 12:00:00.000" endtime="20240301 12:00:10.000"/>
+</suite>
+</robot>
+`
+
+// pybotCommand is the start of the command that runs pybot with its PID file
+const pybotCommand = "sh -c " + runPybotScript + " sh " + pybotPIDFile
+
+// tarReply returns a handler that streams a tar archive of files, like tar cf -
+func tarReply(t *testing.T, files map[string]string) fake.Handler {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, body := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("Failed to write tar body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return fake.Reply(buf.String(), 0)
+}
+
+func TestBuildPybotCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.PybotConfig
+		want []string
+	}{
+		{
+			name: "defaults",
+			cfg:  config.PybotConfig{Suites: []string{"suites/uecm"}},
+			want: []string{"pybot", "suites/uecm"},
+		},
+		{
+			name: "tests variables and output dir",
+			cfg: config.PybotConfig{
+				Command:   []string{"robot", "--loglevel", "DEBUG"},
+				OutputDir: "/tmp/out",
+				Suites:    []string{"suites/uecm", "suites/nim"},
+				Tests:     []string{"TC_UECM_Register_07"},
+				Variables: []string{"SUT:miniudm"},
+				ExtraArgs: []string{"--exitonfailure"},
+			},
+			want: []string{
+				"robot", "--loglevel", "DEBUG",
+				"--outputdir", "/tmp/out",
+				"--test", "TC_UECM_Register_07",
+				"--variable", "SUT:miniudm",
+				"--exitonfailure",
+				"suites/uecm", "suites/nim",
+			},
+		},
+		{
+			name: "work dir",
+			cfg:  config.PybotConfig{WorkDir: "/opt/tests", Suites: []string{"uecm.robot"}},
+			want: []string{"sh", "-c", `cd "$1" && shift && exec "$@"`, "sh", "/opt/tests", "pybot", "uecm.robot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildPybotCommand(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildPybotCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPybotStatus(t *testing.T) {
+	tests := []struct {
+		exitCode   int
+		wantStatus PybotStatus
+		wantFailed int
+	}{
+		{exitCode: 0, wantStatus: PybotPassed},
+		{exitCode: 3, wantStatus: PybotFailed, wantFailed: 3},
+		{exitCode: 250, wantStatus: PybotFailed, wantFailed: 250},
+		{exitCode: 252, wantStatus: PybotError},
+		{exitCode: 253, wantStatus: PybotCancelled},
+		{exitCode: 255, wantStatus: PybotError},
+	}
+
+	for _, tt := range tests {
+		status, failed := pybotStatus(tt.exitCode)
+		if status != tt.wantStatus || failed != tt.wantFailed {
+			t.Errorf("pybotStatus(%d) = %s, %d, want %s, %d", tt.exitCode, status, failed, tt.wantStatus, tt.wantFailed)
+		}
+	}
+}
+
+func TestResolvePybotPod(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchMode string
+		wantPod   string
+		wantErr   bool
+	}{
+		{name: "default prefix prefers the exact deployment", wantPod: "testclient-a"},
+		{name: "regex matches both deployments", matchMode: "regex", wantErr: true},
+		{name: "unknown mode", matchMode: "glob", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, _, _ := newTestCollector(t,
+				newDeployment("miniudm", "testclient", 1, 1),
+				newPod("miniudm", "testclient-a", "testclient"),
+				newDeployment("miniudm", "testclient-legacy", 1, 1),
+				newPod("miniudm", "testclient-legacy-a", "testclient-legacy"),
+			)
+			// The targets are matched as regexps, which must not affect the testclient
+			collector.config.Kubernetes.MatchMode = "regex"
+			collector.config.Pybot = config.PybotConfig{Pod: "testclient", MatchMode: tt.matchMode, Suites: []string{"suites/uecm"}}
+
+			pod, err := collector.resolvePybotPod("miniudm")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePybotPod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && pod.Name != tt.wantPod {
+				t.Errorf("resolvePybotPod() = %s, want %s", pod.Name, tt.wantPod)
+			}
+		})
+	}
+}
+
+func TestCollectPybot(t *testing.T) {
+	objects := []runtime.Object{
+		newNamespace("miniudm"),
+		newDeployment("miniudm", "uecm", 1, 1),
+		newPod("miniudm", "uecm-a", "uecm"),
+		newDeployment("miniudm", "testclient", 1, 1),
+		newPod("miniudm", "testclient-a", "testclient"),
+	}
+
+	tests := []struct {
+		name        string
+		pybot       fake.Handler
+		timeout     time.Duration
+		wantErr     bool
+		wantStatus  PybotStatus
+		wantFailed  int
+		wantOutput  string
+		wantTests   int
+		wantStopped bool
+		maxDuration time.Duration
+	}{
+		{
+			name:        "cleanup starts when pybot finishes",
+			pybot:       fake.Reply("TC_UECM_Register_07 | FAIL |\n", 2),
+			wantStatus:  PybotFailed,
+			wantFailed:  2,
+			wantOutput:  "TC_UECM_Register_07 | FAIL |",
+			wantTests:   2,
+			maxDuration: 3 * time.Second,
+		},
+		{
+			name:        "collection timeout stops pybot",
+			pybot:       fake.Stream("Suite Uecm"),
+			timeout:     300 * time.Millisecond,
+			wantErr:     true,
+			wantStatus:  PybotTimeout,
+			wantOutput:  "Suite Uecm",
+			wantTests:   2,
+			wantStopped: true,
+			maxDuration: 3 * time.Second,
+		},
+		{
+			name:        "pybot cannot be started",
+			wantErr:     true,
+			wantStatus:  PybotError,
+			maxDuration: 3 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, executor, _ := newTestCollector(t, objects...)
+			collector.config.Pybot = config.PybotConfig{
+				Pod:       "testclient",
+				OutputDir: "/tmp/pybot",
+				Suites:    []string{"suites/uecm"},
+				Timezone:  "UTC",
+			}
+			collector.config.Symptom.CollectionTimeout = tt.timeout
+			executor.On("uecm-a", tailCommand, tailStream())
+			if tt.pybot != nil {
+				executor.On("testclient-a", pybotCommand+" pybot", tt.pybot)
+			}
+			executor.On("testclient-a", "sh -c "+stopPybotScript, fake.Reply("", 0))
+			executor.On("testclient-a", "sh -c tar cf -", tarReply(t, map[string]string{
+				"tmp/pybot/output.xml": testOutputXML,
+				"tmp/pybot/log.html":   "<html></html>",
+			}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			result, err := collector.StartCollection(ctx, "miniudm", []string{"uecm"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartCollection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantStatus == PybotTimeout && !errors.Is(err, ErrCollectionTimeout) {
+				t.Errorf("StartCollection() error = %v, want the collection timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxDuration {
+				t.Errorf("StartCollection() took %v, want at most %v", elapsed, tt.maxDuration)
+			}
+			if result.Pybot.Status != tt.wantStatus || result.Pybot.FailedTests != tt.wantFailed {
+				t.Errorf("StartCollection() pybot = %s/%d, want %s/%d", result.Pybot.Status, result.Pybot.FailedTests, tt.wantStatus, tt.wantFailed)
+			}
+			if tt.wantOutput != "" && (len(result.Pybot.Output) == 0 || result.Pybot.Output[0] != tt.wantOutput) {
+				t.Errorf("StartCollection() pybot output = %q, want %q", result.Pybot.Output, tt.wantOutput)
+			}
+			gotTests := 0
+			if result.Pybot.Robot != nil {
+				gotTests = len(result.Pybot.Robot.Tests())
+			}
+			if gotTests != tt.wantTests {
+				t.Errorf("StartCollection() parsed %d pybot tests, want %d", gotTests, tt.wantTests)
+			}
+			if result.Pybot.Pod != "testclient-a" {
+				t.Errorf("StartCollection() pybot pod = %s, want testclient-a", result.Pybot.Pod)
+			}
+			// A pybot the collection stopped waiting for is stopped on the pod too
+			stopped := false
+			for _, call := range executor.Calls() {
+				stopped = stopped || strings.Join(call.Command, " ") == "sh -c "+stopPybotScript+" sh "+pybotPIDFile+" 30"
+			}
+			if stopped != tt.wantStopped {
+				t.Errorf("StartCollection() stopped pybot = %v, want %v", stopped, tt.wantStopped)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package symptom
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Report files written into the run directory and so into the bundle
+const (
+	reportHTMLName     = "report.html"
+	reportMarkdownName = "report.md"
+	// maxSnippetLines bounds the message shown for each error group
+	maxSnippetLines = 20
+)
+
+// Report is the readable summary of a collection run: the configuration, pods,
+// grouped errors with snippets, cores, packet captures, the pybot outcome and
+// the timeline of every event
+type Report struct {
+	Manifest *Manifest
+	Analysis *Analysis
+}
+
+// NewReport builds the report of a run from its manifest and the analysis of its events
+func NewReport(manifest *Manifest, analysis *Analysis) *Report {
+	return &Report{Manifest: manifest, Analysis: analysis}
+}
+
+// reportGroup is an error group with the message of its earliest event
+type reportGroup struct {
+	EventGroup
+	Snippet string
+}
+
+// reportCore is a core with the bundle file it was copied to
+type reportCore struct {
+	ErrorEvent
+	Core *CoreInfo
+	// File is the core's path in the bundle, empty if it was not copied
+	File string
+}
+
+// reportView is the data the report templates render
+type reportView struct {
+	Manifest *Manifest
+	Analysis *Analysis
+	Duration time.Duration
+	Distinct int
+	Total    int
+	Groups   []reportGroup
+	Cores    []reportCore
+	Captures []Artifact
+	// FailedCaptures are the pods whose packet capture failed to start or stop
+	FailedCaptures []Capture
+	Settings       [][2]string
+	ConfigJSON     string
+	Reclassified   bool
+}
+
+func (r *Report) view() *reportView {
+	manifest, analysis := r.Manifest, r.Analysis
+	view := &reportView{
+		Manifest:     manifest,
+		Analysis:     analysis,
+		Duration:     manifest.EndTime.Sub(manifest.StartTime).Round(time.Second),
+		Distinct:     len(analysis.Timeline),
+		Reclassified: analysis.Reclassified,
+	}
+	for _, event := range analysis.Timeline {
+		view.Total += max(event.Count, 1)
+	}
+
+	for _, group := range analysis.Groups {
+		view.Groups = append(view.Groups, reportGroup{EventGroup: group, Snippet: groupSnippet(analysis.Timeline, group)})
+	}
+
+	for _, event := range analysis.Cores {
+		core := reportCore{ErrorEvent: event, Core: event.Core}
+		if core.Core == nil {
+			core.Core = &CoreInfo{Pod: event.Pod, Path: event.Source}
+		}
+		for _, artifact := range manifest.Artifacts {
+			if artifact.Kind == "core" && artifact.Pod == event.Pod && artifact.Source == core.Core.Path {
+				core.File = artifact.Path
+			}
+		}
+		view.Cores = append(view.Cores, core)
+	}
+
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Kind == "pcap" {
+			view.Captures = append(view.Captures, artifact)
+		}
+	}
+	for _, capture := range manifest.Captures {
+		if capture.Error != "" {
+			view.FailedCaptures = append(view.FailedCaptures, capture)
+		}
+	}
+
+	if cfg := manifest.Config; cfg != nil {
+		var watched []string
+		for _, source := range sources(cfg) {
+			if len(source.Paths) == 0 {
+				watched = append(watched, source.Type)
+				continue
+			}
+			watched = append(watched, source.Type+" "+strings.Join(source.Paths, ", "))
+		}
+		var rules []string
+		for _, rule := range cfg.Symptom.Rules {
+			rules = append(rules, rule.Name)
+		}
+		if len(rules) == 0 && len(cfg.Symptom.ErrorKeywords) > 0 {
+			rules = []string{"error keywords " + strings.Join(cfg.Symptom.ErrorKeywords, ", ")}
+		}
+		if len(rules) == 0 {
+			rules = []string{"default"}
+		}
+		view.Settings = [][2]string{
+			{"Sources", strings.Join(watched, "; ")},
+			{"Rules", strings.Join(rules, ", ")},
+			{"Container", cfg.Symptom.Container},
+			{"Pybot command", strings.Join(cfg.Pybot.Command, " ")},
+		}
+		if data, err := json.MarshalIndent(cfg, "", "  "); err == nil {
+			view.ConfigJSON = string(data)
+		}
+	}
+	return view
+}
+
+// groupSnippet returns the message of the earliest event of a group, at most
+// maxSnippetLines long
+func groupSnippet(timeline []ErrorEvent, group EventGroup) string {
+	for _, event := range timeline {
+		if eventRule(event) != group.Rule || event.Pod != group.Pod {
+			continue
+		}
+		lines := strings.Split(event.Message, "\n")
+		if len(lines) > maxSnippetLines {
+			lines = append(lines[:maxSnippetLines], fmt.Sprintf("[%d more lines]", len(lines)-maxSnippetLines))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return group.Sample
+}
+
+// reportFuncs are shared by the HTML and Markdown templates
+var reportFuncs = map[string]any{
+	"datetime": func(ts time.Time) string { return ts.Format(time.DateTime) },
+	"clock":    func(ts time.Time) string { return ts.Format(time.TimeOnly) },
+	"lower":    func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+	"upper":    func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+	"firstline": func(s string) string {
+		line, _, _ := strings.Cut(s, "\n")
+		return line
+	},
+	"cell": func(v any) string {
+		// Markdown table cells are one line and must not contain pipes
+		s := strings.ReplaceAll(fmt.Sprint(v), "\n", " ")
+		return strings.ReplaceAll(s, "|", `\|`)
+	},
+	"fence": func(s string) string {
+		if strings.Contains(s, "```") {
+			return "~~~\n" + s + "\n~~~"
+		}
+		return "```\n" + s + "\n```"
+	},
+	"count": func(event ErrorEvent) int { return max(event.Count, 1) },
+}
+
+var (
+	reportHTML     = htmltemplate.Must(htmltemplate.New(reportHTMLName).Funcs(reportFuncs).Parse(reportHTMLTemplate))
+	reportMarkdown = template.Must(template.New(reportMarkdownName).Funcs(reportFuncs).Parse(reportMarkdownTemplate))
+)
+
+// WriteHTML writes the report as a self-contained HTML page
+func (r *Report) WriteHTML(w io.Writer) error {
+	if err := reportHTML.Execute(w, r.view()); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return nil
+}
+
+// WriteMarkdown writes the report as a Markdown summary to paste into a ticket.
+// The timeline is left out; it is in the HTML report.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	if err := reportMarkdown.Execute(w, r.view()); err != nil {
+		return fmt.Errorf("failed to render Markdown report: %w", err)
+	}
+	return nil
+}
+
+// writeReports writes report.html and report.md into dir and returns their paths
+func (r *Report) writeReports(dir string) ([]string, error) {
+	var written []string
+	for _, file := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{reportHTMLName, r.WriteHTML},
+		{reportMarkdownName, r.WriteMarkdown},
+	} {
+		path := filepath.Join(dir, file.name)
+		out, err := os.Create(path)
+		if err != nil {
+			return written, fmt.Errorf("failed to create report: %w", err)
+		}
+		if err := file.write(out); err != nil {
+			out.Close()
+			return written, err
+		}
+		if err := out.Close(); err != nil {
+			return written, fmt.Errorf("failed to write report: %w", err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+const reportHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Symptom report: {{.Manifest.Namespace}} {{datetime .Manifest.StartTime}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; } h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+pre { background: #f8f8f8; padding: 6px; margin: 0; white-space: pre-wrap; font-size: 0.9em; }
+.sev { font-weight: bold; }
+.critical { color: #a00; } .error { color: #d40; } .warning { color: #b80; } .info { color: #07a; }
+.PASS, .pybot-passed { color: #080; } .FAIL, .pybot-failed, .pybot-error, .pybot-timeout { color: #a00; } .SKIP { color: #777; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>Symptom report: {{.Manifest.Namespace}}</h1>
+<table>
+<tr><th>Cluster</th><td>{{.Manifest.Cluster.Context}} {{with .Manifest.Cluster.Host}}<span class="muted">{{.}}</span>{{end}}</td></tr>
+<tr><th>Collected</th><td>{{datetime .Manifest.StartTime}} to {{datetime .Manifest.EndTime}} ({{.Duration}})</td></tr>
+<tr><th>Pods</th><td>{{range $i, $t := .Manifest.Targets}}{{if $i}}, {{end}}{{$t.Name}}/{{$t.Container}}{{end}}</td></tr>
+<tr><th>Events</th><td>{{.Distinct}} distinct, {{.Total}} total{{if .Reclassified}} (logs reclassified){{end}}</td></tr>
+{{- with .Manifest.Pybot}}
+<tr><th>Pybot</th><td class="pybot-{{.Status}}">{{.Status}}, exit code {{.ExitCode}}, {{.FailedTests}} failed tests{{with .Error}}: {{.}}{{end}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Errors by rule and pod</h2>
+{{- if .Groups}}
+<table>
+<tr><th>Severity</th><th>Rule</th><th>Pod</th><th>Events</th><th>Count</th><th>First seen</th><th>Last seen</th><th>Snippet</th></tr>
+{{- range .Groups}}
+<tr><td class="sev {{lower .Severity}}">{{upper .Severity}}</td><td>{{.Rule}}</td><td>{{.Pod}}</td><td>{{.Events}}</td><td>{{.Count}}</td><td>{{clock .FirstSeen}}</td><td>{{clock .LastSeen}}</td><td><pre>{{.Snippet}}</pre></td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No errors were detected.</p>
+{{- end}}
+
+<h2>Cores</h2>
+{{- if .Cores}}
+<table>
+<tr><th>Time</th><th>Pod</th><th>Process</th><th>PID</th><th>Signal</th><th>Size</th><th>Path</th><th>Bundle file</th><th>Test case</th></tr>
+{{- range .Cores}}
+<tr><td>{{datetime .Timestamp}}</td><td>{{.Pod}}</td><td>{{.Core.Process}}</td><td>{{.Core.PID}}</td><td>{{.Core.SignalName}}</td><td>{{.Core.Size}}</td><td>{{.Core.Path}}</td><td>{{with .File}}<a href="{{.}}">{{.}}</a>{{else}}<span class="muted">not copied</span>{{end}}</td><td>{{.TestCase}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No cores were dumped.</p>
+{{- end}}
+
+<h2>Packet captures</h2>
+{{- if .Captures}}
+<table>
+<tr><th>Pod</th><th>Source</th><th>File</th><th>Size</th></tr>
+{{- range .Captures}}
+<tr><td>{{.Pod}}</td><td>{{.Source}}</td><td><a href="{{.Path}}">{{.Path}}</a></td><td>{{.Size}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No packets were captured.</p>
+{{- end}}
+{{- range .FailedCaptures}}
+<p class="FAIL">Capture in {{.Pod}}{{with .Mode}} ({{.}}){{end}} failed: {{.Error}}</p>
+{{- end}}
+
+{{- with .Analysis.Tests}}
+
+<h2>Test cases</h2>
+<table>
+<tr><th>Test</th><th>Status</th><th>Start</th><th>End</th><th>Events</th><th>Message</th></tr>
+{{- range .}}
+<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{clock .StartTime}}</td><td>{{clock .EndTime}}</td><td>{{.Events}}{{range $rule, $count := .Rules}}<br><span class="muted">{{$rule}} x{{$count}}</span>{{end}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2>Timeline</h2>
+{{- if .Analysis.Timeline}}
+<table>
+<tr><th>Time</th><th>Severity</th><th>Pod</th><th>Source</th><th>Rule</th><th>Count</th><th>Test case</th><th>Message</th></tr>
+{{- range .Analysis.Timeline}}
+<tr><td>{{clock .Timestamp}}</td><td class="sev {{lower .Severity}}">{{upper .Severity}}</td><td>{{.Pod}}</td><td>{{.Source}}</td><td>{{.Rule}}</td><td>{{count .}}</td><td>{{.TestCase}}</td><td><pre>{{.Message}}</pre></td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No events.</p>
+{{- end}}
+
+<h2>Configuration</h2>
+<table>
+{{- range .Settings}}
+<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+{{- with .ConfigJSON}}
+<details><summary>Effective configuration</summary><pre>{{.}}</pre></details>
+{{- end}}
+</body>
+</html>
+`
+
+const reportMarkdownTemplate = `## Symptom report: {{.Manifest.Namespace}}
+
+- **Cluster:** {{.Manifest.Cluster.Context}}{{with .Manifest.Cluster.Host}} ({{.}}){{end}}
+- **Collected:** {{datetime .Manifest.StartTime}} to {{datetime .Manifest.EndTime}} ({{.Duration}})
+- **Pods:** {{range $i, $t := .Manifest.Targets}}{{if $i}}, {{end}}{{$t.Name}}/{{$t.Container}}{{end}}
+- **Events:** {{.Distinct}} distinct, {{.Total}} total{{if .Reclassified}} (logs reclassified){{end}}
+{{- with .Manifest.Pybot}}
+- **Pybot:** {{.Status}}, exit code {{.ExitCode}}, {{.FailedTests}} failed tests{{with .Error}}: {{.}}{{end}}
+{{- end}}
+{{- range .Settings}}{{if index . 1}}
+- **{{index . 0}}:** {{index . 1}}
+{{- end}}{{end}}
+
+### Errors by rule and pod
+{{if .Groups}}
+| Severity | Rule | Pod | Events | Count | First seen | Last seen |
+|---|---|---|---|---|---|---|
+{{- range .Groups}}
+| {{upper .Severity}} | {{cell .Rule}} | {{cell .Pod}} | {{.Events}} | {{.Count}} | {{clock .FirstSeen}} | {{clock .LastSeen}} |
+{{- end}}
+{{range .Groups}}
+**{{.Rule}}** in {{.Pod}}:
+
+{{fence .Snippet}}
+{{end}}
+{{- else}}
+No errors were detected.
+{{end}}
+### Cores
+{{if .Cores}}
+| Time | Pod | Process | PID | Signal | Path | Bundle file | Test case |
+|---|---|---|---|---|---|---|---|
+{{- range .Cores}}
+| {{datetime .Timestamp}} | {{cell .Pod}} | {{cell .Core.Process}} | {{.Core.PID}} | {{.Core.SignalName}} | {{cell .Core.Path}} | {{cell .File}} | {{cell .TestCase}} |
+{{- end}}
+{{else}}
+No cores were dumped.
+{{end}}
+{{- if or .Captures .FailedCaptures}}
+### Packet captures
+{{with .Captures}}
+| Pod | Source | File | Size |
+|---|---|---|---|
+{{- range .}}
+| {{cell .Pod}} | {{cell .Source}} | {{cell .Path}} | {{.Size}} |
+{{- end}}
+{{end}}
+{{- range .FailedCaptures}}
+- Capture in {{.Pod}}{{with .Mode}} ({{.}}){{end}} failed: {{.Error}}
+{{end}}
+{{- end}}
+{{- with .Analysis.Tests}}
+### Test cases
+
+| Test | Status | Events | Message |
+|---|---|---|---|
+{{- range .}}
+| {{cell .Name}} | {{.Status}} | {{.Events}} | {{cell (firstline .Message)}} |
+{{- end}}
+{{end}}`
@@ -0,0 +1,129 @@
+package symptom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// testReport builds the report of the test bundle with a configuration, a
+// copied core and a packet capture
+func testReport(t *testing.T) *Report {
+	t.Helper()
+	_, runDir := writeTestBundle(t)
+	bundle, err := OpenBundle(runDir)
+	if err != nil {
+		t.Fatalf("OpenBundle() error = %v", err)
+	}
+
+	manifest := bundle.Manifest
+	manifest.Targets = []TargetPod{{Name: "uecm-a", Container: "mcc"}}
+	manifest.Config = &config.Config{Symptom: config.SymptomConfig{
+		Rules: []config.RuleConfig{{Name: "error", Pattern: "ERROR"}},
+	}}
+	manifest.Pybot.Status = PybotFailed
+	manifest.Pybot.FailedTests = 1
+	manifest.Events[0].Message += "\n<script>alert(1)</script> | piped"
+	manifest.Artifacts = append(manifest.Artifacts,
+		Artifact{Kind: "core", Pod: "uecm-a", Source: "/logstore/TspCore/core.uecm.42", Path: "cores/uecm-a/core.uecm.42"},
+		Artifact{Kind: "pcap", Pod: "uecm-a", Source: "eth0", Path: "pcap/uecm-a/eth0.pcap", Size: 2048},
+	)
+	manifest.Captures = []Capture{
+		{Pod: "uecm-a", Mode: PcapExec, Interface: "eth0", Files: 1, Bytes: 2048},
+		{Pod: "uecm-b", Mode: PcapEphemeral, Interface: "eth0", Error: "tcpdump did not start"},
+	}
+
+	analysis, err := bundle.Analyze(AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	return NewReport(manifest, analysis)
+}
+
+func TestReportHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := testReport(t).WriteHTML(&out); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	html := out.String()
+
+	for _, want := range []string{
+		"<td>uecm-a/mcc</td>",
+		`class="pybot-failed">failed, exit code 0, 1 failed tests`,
+		`<td class="sev critical">CRITICAL</td><td>core-dump</td>`,
+		`<a href="cores/uecm-a/core.uecm.42">`,
+		`<a href="pcap/uecm-a/eth0.pcap">`,
+		`<p class="FAIL">Capture in uecm-b (ephemeral) failed: tcpdump did not start</p>`,
+		`<td class="FAIL">FAIL</td>`,
+		"&lt;script&gt;",
+		"<tr><th>Rules</th><td>error</td></tr>",
+		"(2m0s)",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("WriteHTML() does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("WriteHTML() did not escape event messages")
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	var out bytes.Buffer
+	if err := testReport(t).WriteMarkdown(&out); err != nil {
+		t.Fatalf("WriteMarkdown() error = %v", err)
+	}
+	markdown := out.String()
+
+	for _, want := range []string{
+		"## Symptom report: miniudm",
+		"- **Pybot:** failed, exit code 0, 1 failed tests",
+		"| CRITICAL | core-dump | uecm-a | 1 | 1 | 12:01:15 | 12:01:15 |",
+		"**error** in uecm-a:\n\n```\n2024-03-01 12:00:10 ERROR upstream reset id=1\n<script>alert(1)</script> | piped\n```",
+		"| uecm-a | uecm | 42 | SIGSEGV | /logstore/TspCore/core.uecm.42 | cores/uecm-a/core.uecm.42 | Suite.TC_02 |",
+		"| uecm-a | eth0 | pcap/uecm-a/eth0.pcap | 2048 |\n\n- Capture in uecm-b (ephemeral) failed: tcpdump did not start\n\n### Test cases",
+		"| Suite.TC_02 | FAIL | 1 |  |",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("WriteMarkdown() = %s\nwant it to contain %q", markdown, want)
+		}
+	}
+	if strings.Contains(markdown, "Timeline") {
+		t.Errorf("WriteMarkdown() contains the timeline, want it only in the HTML report")
+	}
+}
+
+func TestGroupSnippet(t *testing.T) {
+	long := strings.Repeat("frame\n", maxSnippetLines+5) + "last"
+	timeline := []ErrorEvent{
+		{Kind: EventLog, Pod: "uecm-b", Rule: "panic", Message: "other pod"},
+		{Kind: EventLog, Pod: "uecm-a", Rule: "panic", Message: long},
+	}
+
+	got := groupSnippet(timeline, EventGroup{Rule: "panic", Pod: "uecm-a"})
+	lines := strings.Split(got, "\n")
+	if len(lines) != maxSnippetLines+1 || lines[maxSnippetLines] != "[6 more lines]" {
+		t.Errorf("groupSnippet() = %d lines ending %q, want %d lines ending [6 more lines]", len(lines), lines[len(lines)-1], maxSnippetLines+1)
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	dir := t.TempDir()
+	written, err := testReport(t).writeReports(dir)
+	if err != nil {
+		t.Fatalf("writeReports() error = %v", err)
+	}
+	want := []string{filepath.Join(dir, reportHTMLName), filepath.Join(dir, reportMarkdownName)}
+	if strings.Join(written, ",") != strings.Join(want, ",") {
+		t.Fatalf("writeReports() = %v, want %v", written, want)
+	}
+	for _, path := range written {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("writeReports() did not write %s", path)
+		}
+	}
+}
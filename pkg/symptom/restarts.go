@@ -0,0 +1,114 @@
+package symptom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+)
+
+// restartWatcher polls the target pods and reports container restarts and
+// deleted pods. It collects no artifacts.
+type restartWatcher struct {
+	env  *WatcherEnv
+	loop loop
+}
+
+// newRestartWatchers creates a single watcher for all target pods
+func newRestartWatchers(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+	return []Watcher{&restartWatcher{env: env}}, nil
+}
+
+func (w *restartWatcher) Name() string {
+	return fmt.Sprintf("%s %s", SourceRestarts, w.env.Namespace)
+}
+
+func (w *restartWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	// Restarts before the collection are reported by validation, not here
+	restarts := make(map[string]int32)
+	for _, target := range w.env.Targets {
+		health, err := w.env.Client.GetPodHealth(w.env.Namespace, target.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read restart counts: %w", err)
+		}
+		for _, container := range health.Containers {
+			restarts[target.Name+"/"+container.Name] = container.RestartCount
+		}
+	}
+
+	w.loop.start(ctx, func(ctx context.Context) {
+		w.watch(ctx, restarts, emit)
+	})
+	return nil
+}
+
+func (w *restartWatcher) Stop(ctx context.Context) error {
+	return w.loop.stop(ctx)
+}
+
+func (w *restartWatcher) Artifacts() []Artifact {
+	return nil
+}
+
+// watch polls the pods until ctx ends
+func (w *restartWatcher) watch(ctx context.Context, restarts map[string]int32, emit EmitFunc) {
+	deleted := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.env.interval()):
+		}
+
+		for _, target := range w.env.Targets {
+			if deleted[target.Name] {
+				continue
+			}
+			health, err := w.env.Client.GetPodHealth(w.env.Namespace, target.Name)
+			if errors.Is(err, kubernetes.ErrNotFound) {
+				deleted[target.Name] = true
+				emit(ErrorEvent{
+					Timestamp: time.Now(),
+					Kind:      EventRestart,
+					Pod:       target.Name,
+					Source:    "restarts",
+					Message:   fmt.Sprintf("pod %s was deleted", target.Name),
+					Rule:      RulePodDeleted,
+					Severity:  SeverityCritical,
+				})
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				w.env.Logger.Warn("Failed to check pod restarts", zap.String("pod", target.Name), zap.Error(err))
+				continue
+			}
+
+			for _, container := range health.Containers {
+				key := target.Name + "/" + container.Name
+				if container.RestartCount <= restarts[key] {
+					continue
+				}
+				restarts[key] = container.RestartCount
+				emit(ErrorEvent{
+					Timestamp: time.Now(),
+					Kind:      EventRestart,
+					Pod:       target.Name,
+					Container: container.Name,
+					Source:    "restarts",
+					Message: fmt.Sprintf("container %s in pod %s restarted (%d restarts, last termination: %s)",
+						container.Name, target.Name, container.RestartCount, container.LastTerminationReason),
+					Rule:     RuleRestart,
+					Severity: SeverityError,
+					Groups:   map[string]string{"reason": container.LastTerminationReason},
+				})
+			}
+		}
+	}
+}
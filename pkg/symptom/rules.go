@@ -0,0 +1,250 @@
+package symptom
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// Severity ranks how serious a detected error is
+type Severity string
+
+// Severities from least to most serious
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Rule names of events that are not matched from log lines
+const (
+	RuleCoreDump   = "core-dump"
+	RuleRestart    = "container-restart"
+	RulePodDeleted = "pod-deleted"
+	RuleK8sWarning = "k8s-warning"
+	// RuleLogGap reports log lines that may have been missed while a log
+	// file was not followed, e.g. when it was rotated during a tail restart
+	RuleLogGap = "log-gap"
+	// RuleHTTP2Error and RuleDiameterError report failed responses in packet captures
+	RuleHTTP2Error    = "http2-error"
+	RuleDiameterError = "diameter-error"
+)
+
+// defaultRules are used when neither symptom.rules nor symptom.error_keywords are set.
+// Word boundaries keep identifiers such as error_count=0 from matching.
+var defaultRules = []config.RuleConfig{
+	{Name: "fatal", Pattern: `(?i)\b(?:fatal|panic)\b`, Severity: string(SeverityCritical)},
+	{Name: "exception", Pattern: `(?i)\bexception\b`, Severity: string(SeverityError)},
+	{Name: "error", Pattern: `(?i)\berrors?\b`, Exclude: `(?i)\b(?:no|0) errors?\b|\berrors?\s*[=:]\s*0\b`, Severity: string(SeverityError)},
+}
+
+// Level orders severities; unknown severities rank lowest
+func (s Severity) Level() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	case SeverityCritical:
+		return 4
+	}
+	return 0
+}
+
+// ParseSeverity parses a configured severity; empty means error
+func ParseSeverity(value string) (Severity, error) {
+	if value == "" {
+		return SeverityError, nil
+	}
+	severity := Severity(strings.ToLower(value))
+	if severity.Level() == 0 {
+		return "", fmt.Errorf("unknown severity %q (want info, warning, error or critical)", value)
+	}
+	return severity, nil
+}
+
+// Rule is a compiled error rule
+type Rule struct {
+	Name     string
+	Severity Severity
+	// Source is a glob on the source path; empty matches every source
+	Source string
+	Tags   []string
+
+	pattern *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// RuleSet holds compiled rules in evaluation order
+type RuleSet struct {
+	rules []*Rule
+}
+
+// Match is the result of a rule matching a line
+type Match struct {
+	Rule *Rule
+	// Groups holds the capture groups by name, or by index for unnamed groups
+	Groups map[string]string
+}
+
+// CompileRules compiles rule configurations in order
+func CompileRules(configs []config.RuleConfig) (*RuleSet, error) {
+	set := &RuleSet{}
+	for i, cfg := range configs {
+		rule, err := compileRule(cfg)
+		if err != nil {
+			name := cfg.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+func compileRule(cfg config.RuleConfig) (*Rule, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if cfg.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	var exclude *regexp.Regexp
+	if cfg.Exclude != "" {
+		if exclude, err = regexp.Compile(cfg.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+	}
+	if _, err := path.Match(cfg.Source, ""); err != nil {
+		return nil, fmt.Errorf("invalid source glob: %w", err)
+	}
+	severity, err := ParseSeverity(cfg.Severity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rule{
+		Name:     cfg.Name,
+		Severity: severity,
+		Source:   cfg.Source,
+		Tags:     cfg.Tags,
+		pattern:  pattern,
+		exclude:  exclude,
+	}, nil
+}
+
+// rulesFor compiles the configured rules. Without rules the legacy error
+// keywords are matched as case-insensitive words, or the default rules are used.
+func rulesFor(cfg *config.Config) (*RuleSet, error) {
+	if len(cfg.Symptom.Rules) > 0 {
+		return CompileRules(cfg.Symptom.Rules)
+	}
+	if len(cfg.Symptom.ErrorKeywords) > 0 {
+		return CompileRules(keywordRules(cfg.Symptom.ErrorKeywords))
+	}
+	return CompileRules(defaultRules)
+}
+
+// keywordRules converts error keywords into rules, merging keywords that only differ in case
+func keywordRules(keywords []string) []config.RuleConfig {
+	var rules []config.RuleConfig
+	seen := make(map[string]bool)
+	for _, keyword := range keywords {
+		name := strings.ToLower(keyword)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		pattern := regexp.QuoteMeta(keyword)
+		if isWordByte(keyword[0]) {
+			pattern = `\b` + pattern
+		}
+		if isWordByte(keyword[len(keyword)-1]) {
+			pattern += `\b`
+		}
+		rules = append(rules, config.RuleConfig{Name: name, Pattern: "(?i)" + pattern})
+	}
+	return rules
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// Rules returns the rules in evaluation order
+func (s *RuleSet) Rules() []*Rule {
+	return s.rules
+}
+
+// Match returns the first rule matching a line read from source, or nil
+func (s *RuleSet) Match(source, line string) *Match {
+	for _, rule := range s.rules {
+		if rule.Source != "" {
+			if ok, _ := path.Match(rule.Source, source); !ok {
+				continue
+			}
+		}
+		groups := rule.pattern.FindStringSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		if rule.exclude != nil && rule.exclude.MatchString(line) {
+			continue
+		}
+		return &Match{Rule: rule, Groups: captureGroups(rule.pattern, groups)}
+	}
+	return nil
+}
+
+// classify returns the log event for a block read from source at ts, or nil
+// if no rule matches it
+func (s *RuleSet) classify(source string, ts time.Time, block string) *ErrorEvent {
+	match := s.Match(source, block)
+	if match == nil {
+		return nil
+	}
+	event := &ErrorEvent{Timestamp: ts, Kind: EventLog, Source: source, Message: block}
+	match.apply(event)
+	return event
+}
+
+// captureGroups names the non-empty capture groups of a match
+func captureGroups(pattern *regexp.Regexp, match []string) map[string]string {
+	var groups map[string]string
+	for i, name := range pattern.SubexpNames() {
+		if i == 0 || match[i] == "" {
+			continue
+		}
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if groups == nil {
+			groups = make(map[string]string)
+		}
+		groups[name] = match[i]
+	}
+	return groups
+}
+
+// apply records the match on an event
+func (m *Match) apply(event *ErrorEvent) {
+	event.Rule = m.Rule.Name
+	event.Severity = m.Rule.Severity
+	event.Groups = m.Groups
+	event.Tags = m.Rule.Tags
+}
@@ -0,0 +1,131 @@
+package symptom
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+func TestCompileRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []config.RuleConfig
+		wantErr bool
+	}{
+		{name: "valid rule", rules: []config.RuleConfig{{Name: "envoy", Pattern: `upstream reset`, Severity: "Warning", Source: "/Envoy*"}}},
+		{name: "missing name", rules: []config.RuleConfig{{Pattern: `error`}}, wantErr: true},
+		{name: "missing pattern", rules: []config.RuleConfig{{Name: "empty"}}, wantErr: true},
+		{name: "invalid pattern", rules: []config.RuleConfig{{Name: "bad", Pattern: `(`}}, wantErr: true},
+		{name: "invalid exclude", rules: []config.RuleConfig{{Name: "bad", Pattern: `error`, Exclude: `[`}}, wantErr: true},
+		{name: "invalid source glob", rules: []config.RuleConfig{{Name: "bad", Pattern: `error`, Source: `[`}}, wantErr: true},
+		{name: "unknown severity", rules: []config.RuleConfig{{Name: "bad", Pattern: `error`, Severity: "urgent"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileRules(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleSetMatch(t *testing.T) {
+	rules, err := CompileRules([]config.RuleConfig{
+		{
+			Name:     "diameter-result",
+			Pattern:  `Diameter answer (?P<code>\d+) for (?P<imsi>imsi-\d+)`,
+			Exclude:  `answer 2001\b`,
+			Severity: "warning",
+			Source:   "/RTPTrace*",
+			Tags:     []string{"diameter"},
+		},
+		{Name: "timeout", Pattern: `timed out after (\d+)ms`, Severity: "critical"},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		source     string
+		line       string
+		wantRule   string
+		wantGroups map[string]string
+	}{
+		{
+			name:       "named groups",
+			source:     "/RTPTraceError",
+			line:       "Diameter answer 5001 for imsi-001",
+			wantRule:   "diameter-result",
+			wantGroups: map[string]string{"code": "5001", "imsi": "imsi-001"},
+		},
+		{name: "excluded line", source: "/RTPTraceError", line: "Diameter answer 2001 for imsi-001"},
+		{name: "source not matching glob", source: "/Envoy", line: "Diameter answer 5001 for imsi-001"},
+		{
+			name:       "unnamed groups",
+			source:     "/Envoy",
+			line:       "request timed out after 500ms",
+			wantRule:   "timeout",
+			wantGroups: map[string]string{"1": "500"},
+		},
+		{name: "no rule", source: "/Envoy", line: "all good"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := rules.Match(tt.source, tt.line)
+			if tt.wantRule == "" {
+				if match != nil {
+					t.Errorf("Match() = %s, want no match", match.Rule.Name)
+				}
+				return
+			}
+			if match == nil || match.Rule.Name != tt.wantRule {
+				t.Fatalf("Match() = %+v, want rule %s", match, tt.wantRule)
+			}
+			if !reflect.DeepEqual(match.Groups, tt.wantGroups) {
+				t.Errorf("Match() groups = %v, want %v", match.Groups, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		keywords     []string
+		line         string
+		wantRule     string
+		wantSeverity Severity
+	}{
+		{name: "default error rule", line: "2024-03-01 ERROR registration failed", wantRule: "error", wantSeverity: SeverityError},
+		{name: "default fatal rule", line: "panic: runtime error", wantRule: "fatal", wantSeverity: SeverityCritical},
+		{name: "identifier is not an error", line: "stats error_count=0 retries=0"},
+		{name: "zero errors", line: "sync finished with 0 errors"},
+		{name: "keywords match words in any case", keywords: []string{"error", "ERROR"}, line: "Error: timeout", wantRule: "error", wantSeverity: SeverityError},
+		{name: "keywords skip identifiers", keywords: []string{"error", "ERROR"}, line: "error_count=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := rulesFor(&config.Config{Symptom: config.SymptomConfig{ErrorKeywords: tt.keywords}})
+			if err != nil {
+				t.Fatalf("rulesFor() error = %v", err)
+			}
+			if n := len(tt.keywords); n > 0 && len(rules.Rules()) != 1 {
+				t.Errorf("rulesFor() built %d rules from %v, want keywords merged by case", len(rules.Rules()), tt.keywords)
+			}
+
+			match := rules.Match("/Envoy", tt.line)
+			switch {
+			case tt.wantRule == "" && match != nil:
+				t.Errorf("Match(%q) = %s, want no match", tt.line, match.Rule.Name)
+			case tt.wantRule != "" && (match == nil || match.Rule.Name != tt.wantRule || match.Rule.Severity != tt.wantSeverity):
+				t.Errorf("Match(%q) = %+v, want %s/%s", tt.line, match, tt.wantRule, tt.wantSeverity)
+			}
+		})
+	}
+}
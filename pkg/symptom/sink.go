@@ -0,0 +1,352 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"go.uber.org/zap"
+)
+
+// Built-in sink types, used as symptom.sinks[].type
+const (
+	SinkJSONL   = "jsonl"
+	SinkStdout  = "stdout"
+	SinkWebhook = "webhook"
+)
+
+// Sink delivery defaults, used when the configuration leaves them unset
+const (
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = 1 * time.Second
+	defaultSinkQueueSize     = 10000
+	defaultSinkMaxRetries    = 3
+	defaultSinkRetryBackoff  = 500 * time.Millisecond
+	sinkCloseTimeout         = 30 * time.Second
+)
+
+// EventSink receives the events detected during a collection. Write is called
+// from a single goroutine with batches of events in detection order; a failed
+// Write is retried with the same batch. Close is called once after the last Write.
+type EventSink interface {
+	// Name identifies the sink in logs, e.g. "jsonl events.jsonl"
+	Name() string
+	Write(ctx context.Context, events []ErrorEvent) error
+	Close(ctx context.Context) error
+}
+
+// SinkStats counts the events delivered to a sink
+type SinkStats struct {
+	Name string `json:"name"`
+	// Written events were accepted by the sink
+	Written int `json:"written"`
+	// Failed events were in batches the sink rejected after every retry
+	Failed int `json:"failed"`
+	// Dropped events arrived while the sink's queue was full
+	Dropped int `json:"dropped"`
+}
+
+// SinkEnv is what a SinkFactory needs to build a sink for a collection run
+type SinkEnv struct {
+	Config *config.Config
+	Logger *zap.Logger
+	// OutputDir is the run directory
+	OutputDir string
+}
+
+// SinkFactory builds a sink for one configured entry
+type SinkFactory func(env *SinkEnv, cfg config.SinkConfig) (EventSink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = make(map[string]SinkFactory)
+)
+
+// RegisterSink makes a sink type available to symptom.sinks. Registering an
+// existing type replaces it.
+func RegisterSink(sinkType string, factory SinkFactory) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks[sinkType] = factory
+}
+
+// RegisteredSinks returns the registered sink types
+func RegisteredSinks() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	types := make([]string, 0, len(sinks))
+	for sinkType := range sinks {
+		types = append(types, sinkType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func init() {
+	RegisterSink(SinkJSONL, newJSONLSink)
+	RegisterSink(SinkStdout, newStdoutSink)
+	RegisterSink(SinkWebhook, newWebhookSink)
+}
+
+// sinkConfigs returns the configured sinks; without any, events are written to
+// events.jsonl in the run directory
+func sinkConfigs(cfg *config.Config) []config.SinkConfig {
+	if len(cfg.Symptom.Sinks) > 0 {
+		return cfg.Symptom.Sinks
+	}
+	return []config.SinkConfig{{Type: SinkJSONL}}
+}
+
+// buildSinks creates the configured sinks
+func buildSinks(env *SinkEnv) ([]sinkWithConfig, error) {
+	var built []sinkWithConfig
+	for _, cfg := range sinkConfigs(env.Config) {
+		sinksMu.RLock()
+		factory, ok := sinks[cfg.Type]
+		sinksMu.RUnlock()
+		if !ok {
+			closeSinks(built)
+			return nil, fmt.Errorf("unknown event sink type %q (registered: %s)",
+				cfg.Type, strings.Join(RegisteredSinks(), ", "))
+		}
+
+		sink, err := factory(env, cfg)
+		if err != nil {
+			closeSinks(built)
+			return nil, fmt.Errorf("failed to create %s sink: %w", cfg.Type, err)
+		}
+		built = append(built, sinkWithConfig{sink: sink, cfg: cfg})
+	}
+	return built, nil
+}
+
+// sinkWithConfig is a sink and the delivery settings it was configured with
+type sinkWithConfig struct {
+	sink EventSink
+	cfg  config.SinkConfig
+}
+
+// closeSinks closes sinks that were built but never started
+func closeSinks(built []sinkWithConfig) {
+	for _, s := range built {
+		s.sink.Close(context.Background())
+	}
+}
+
+// fanout delivers every published event to each sink through its own queue,
+// so a slow or failing sink delays neither the others nor the watchers
+type fanout struct {
+	workers []*sinkWorker
+}
+
+func newFanout(logger *zap.Logger, built []sinkWithConfig) *fanout {
+	f := &fanout{}
+	for _, s := range built {
+		f.workers = append(f.workers, newSinkWorker(logger, s.sink, s.cfg))
+	}
+	return f
+}
+
+// start runs the sink workers until close
+func (f *fanout) start(ctx context.Context) {
+	for _, worker := range f.workers {
+		worker.start(ctx)
+	}
+}
+
+// publish queues an event for every sink without blocking
+func (f *fanout) publish(event ErrorEvent) {
+	for _, worker := range f.workers {
+		worker.publish(event)
+	}
+}
+
+// close flushes the queued events, closes the sinks and returns their counters.
+// It must not be called concurrently with publish.
+func (f *fanout) close(ctx context.Context) []SinkStats {
+	var wg sync.WaitGroup
+	for _, worker := range f.workers {
+		wg.Add(1)
+		worker := worker // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			worker.close(ctx)
+		}()
+	}
+	wg.Wait()
+
+	stats := make([]SinkStats, 0, len(f.workers))
+	for _, worker := range f.workers {
+		stats = append(stats, worker.snapshot())
+	}
+	return stats
+}
+
+// sinkWorker batches the events of one sink and retries failed batches
+type sinkWorker struct {
+	logger        *zap.Logger
+	sink          EventSink
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+
+	queue  chan ErrorEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	stats SinkStats
+}
+
+func newSinkWorker(logger *zap.Logger, sink EventSink, cfg config.SinkConfig) *sinkWorker {
+	w := &sinkWorker{
+		logger:        logger,
+		sink:          sink,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		maxRetries:    cfg.MaxRetries,
+		retryBackoff:  cfg.RetryBackoff,
+		done:          make(chan struct{}),
+		stats:         SinkStats{Name: sink.Name()},
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = defaultSinkBatchSize
+	}
+	if w.flushInterval <= 0 {
+		w.flushInterval = defaultSinkFlushInterval
+	}
+	if w.maxRetries < 0 {
+		w.maxRetries = 0
+	} else if w.maxRetries == 0 {
+		w.maxRetries = defaultSinkMaxRetries
+	}
+	if w.retryBackoff <= 0 {
+		w.retryBackoff = defaultSinkRetryBackoff
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultSinkQueueSize
+	}
+	w.queue = make(chan ErrorEvent, queueSize)
+	return w
+}
+
+func (w *sinkWorker) publish(event ErrorEvent) {
+	select {
+	case w.queue <- event:
+	default:
+		w.mu.Lock()
+		w.stats.Dropped++
+		w.mu.Unlock()
+	}
+}
+
+// start runs the worker in the background
+func (w *sinkWorker) start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.run(ctx)
+}
+
+// run writes batches until the queue is closed and drained
+func (w *sinkWorker) run(ctx context.Context) {
+	defer close(w.done)
+	defer w.cancel()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]ErrorEvent, 0, w.batchSize)
+	for {
+		select {
+		case event, ok := <-w.queue:
+			if !ok {
+				w.flush(ctx, batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= w.batchSize {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes a batch, retrying with exponential backoff
+func (w *sinkWorker) flush(ctx context.Context, batch []ErrorEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	backoff := w.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.sink.Write(ctx, batch)
+		if err == nil {
+			w.mu.Lock()
+			w.stats.Written += len(batch)
+			w.mu.Unlock()
+			return
+		}
+		w.logger.Debug("Event sink write failed",
+			zap.String("sink", w.sink.Name()),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+		if attempt > w.maxRetries || !sleep(ctx, backoff) {
+			w.fail(batch, err)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// fail counts a batch the sink did not accept
+func (w *sinkWorker) fail(batch []ErrorEvent, err error) {
+	w.logger.Warn("Event sink rejected events",
+		zap.String("sink", w.sink.Name()),
+		zap.Int("events", len(batch)),
+		zap.Error(err),
+	)
+	w.mu.Lock()
+	w.stats.Failed += len(batch)
+	w.mu.Unlock()
+}
+
+// close drains the queue and closes the sink. If ctx ends first the pending
+// writes are cancelled.
+func (w *sinkWorker) close(ctx context.Context) {
+	close(w.queue)
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+	}
+	if err := w.sink.Close(ctx); err != nil {
+		w.logger.Warn("Failed to close event sink", zap.String("sink", w.sink.Name()), zap.Error(err))
+	}
+}
+
+func (w *sinkWorker) snapshot() SinkStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// sleep waits for d and reports false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
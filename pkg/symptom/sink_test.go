@@ -0,0 +1,250 @@
+package symptom
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"go.uber.org/zap"
+)
+
+// testEvents returns n distinct log events
+func testEvents(n int) []ErrorEvent {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]ErrorEvent, n)
+	for i := range events {
+		events[i] = ErrorEvent{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Kind:      EventLog,
+			Pod:       "uecm-a",
+			Source:    "/Envoy",
+			Message:   "ERROR upstream reset " + string(rune('a'+i)),
+			Rule:      "error",
+			Severity:  SeverityError,
+			Count:     1,
+		}
+	}
+	return events
+}
+
+// deliver runs events through a fanout of the given sinks and returns the sink counters
+func deliver(t *testing.T, events []ErrorEvent, built ...sinkWithConfig) []SinkStats {
+	t.Helper()
+	sinks := newFanout(zap.NewNop(), built)
+	sinks.start(context.Background())
+	for _, event := range events {
+		sinks.publish(event)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sinks.close(ctx)
+}
+
+func TestBuildSinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		sinks     []config.SinkConfig
+		wantNames []string
+		wantErr   bool
+	}{
+		{name: "default jsonl", wantNames: []string{"jsonl events.jsonl"}},
+		{
+			name:      "configured sinks",
+			sinks:     []config.SinkConfig{{Type: SinkStdout}, {Type: SinkWebhook, URL: "http://ci.example/events"}},
+			wantNames: []string{"stdout", "webhook http://ci.example/events"},
+		},
+		{name: "webhook without url", sinks: []config.SinkConfig{{Type: SinkWebhook}}, wantErr: true},
+		{name: "unknown sink type", sinks: []config.SinkConfig{{Type: "kafka"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &config.Config{Symptom: config.SymptomConfig{Sinks: tt.sinks}}
+			built, err := buildSinks(&SinkEnv{Config: cfg, Logger: zap.NewNop(), OutputDir: dir})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			defer closeSinks(built)
+
+			var names []string
+			for _, s := range built {
+				names = append(names, strings.Replace(s.sink.Name(), dir+"/", "", 1))
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("buildSinks() = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := newJSONLSink(&SinkEnv{OutputDir: dir}, config.SinkConfig{})
+	if err != nil {
+		t.Fatalf("newJSONLSink() error = %v", err)
+	}
+
+	events := testEvents(3)
+	events[1].Groups = map[string]string{"imsi": "imsi-001"}
+	stats := deliver(t, events, sinkWithConfig{sink: sink, cfg: config.SinkConfig{BatchSize: 2}})
+	if stats[0].Written != 3 {
+		t.Errorf("jsonl sink stats = %+v, want 3 written", stats[0])
+	}
+
+	got, err := ReadEvents(filepath.Join(dir, defaultJSONLPath))
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(got) != 3 || got[1].Groups["imsi"] != "imsi-001" || !got[2].Timestamp.Equal(events[2].Timestamp) {
+		t.Errorf("ReadEvents() = %+v, want the delivered events", got)
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	var out strings.Builder
+	sink := &stdoutSink{out: &out}
+
+	event := testEvents(1)[0]
+	event.Message = "ERROR SIGSEGV in uecm\n  #0 Diameter::decode()"
+	event.TestCase = "UECM.TC_Register_07"
+	if err := sink.Write(context.Background(), []ErrorEvent{event}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "12:00:00 ERROR    uecm-a /Envoy [error] ERROR SIGSEGV in uecm\n" +
+		"      #0 Diameter::decode()\n" +
+		"    test case: UECM.TC_Register_07\n"
+	if out.String() != want {
+		t.Errorf("Write() printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		maxRetries  int
+		wantWritten int
+		wantFailed  int
+	}{
+		{name: "delivered in batches", wantWritten: 5},
+		{name: "retried after errors", failures: 2, maxRetries: 3, wantWritten: 5},
+		{name: "rejected after retries", failures: 100, maxRetries: 1, wantFailed: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []ErrorEvent
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests++
+				if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if requests <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				var payload webhookPayload
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || len(payload.Events) > 2 {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				received = append(received, payload.Events...)
+			}))
+			defer server.Close()
+
+			cfg := config.SinkConfig{
+				Type:         SinkWebhook,
+				URL:          server.URL,
+				Headers:      map[string]string{"Authorization": "Bearer token"},
+				BatchSize:    2,
+				MaxRetries:   tt.maxRetries,
+				RetryBackoff: time.Millisecond,
+			}
+			sink, err := newWebhookSink(&SinkEnv{}, cfg)
+			if err != nil {
+				t.Fatalf("newWebhookSink() error = %v", err)
+			}
+
+			stats := deliver(t, testEvents(5), sinkWithConfig{sink: sink, cfg: cfg})
+			if stats[0].Written != tt.wantWritten || stats[0].Failed != tt.wantFailed {
+				t.Errorf("webhook sink stats = %+v, want %d written and %d failed", stats[0], tt.wantWritten, tt.wantFailed)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != tt.wantWritten {
+				t.Errorf("webhook received %d events, want %d", len(received), tt.wantWritten)
+			}
+		})
+	}
+}
+
+// blockingSink accepts writes only once released
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Name() string { return "blocking" }
+
+func (s *blockingSink) Write(ctx context.Context, events []ErrorEvent) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingSink) Close(ctx context.Context) error { return nil }
+
+func TestFanoutSlowSink(t *testing.T) {
+	dir := t.TempDir()
+	jsonl, err := newJSONLSink(&SinkEnv{OutputDir: dir}, config.SinkConfig{})
+	if err != nil {
+		t.Fatalf("newJSONLSink() error = %v", err)
+	}
+	slow := &blockingSink{release: make(chan struct{})}
+
+	sinks := newFanout(zap.NewNop(), []sinkWithConfig{
+		{sink: jsonl},
+		{sink: slow, cfg: config.SinkConfig{BatchSize: 1, QueueSize: 2}},
+	})
+	sinks.start(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, event := range testEvents(10) {
+			sinks.publish(event)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish() blocked on a slow sink")
+	}
+	close(slow.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stats := sinks.close(ctx)
+	if stats[0].Written != 10 {
+		t.Errorf("jsonl sink stats = %+v, want every event written", stats[0])
+	}
+	if stats[1].Dropped == 0 || stats[1].Written+stats[1].Dropped != 10 {
+		t.Errorf("slow sink stats = %+v, want the overflow dropped", stats[1])
+	}
+}
@@ -0,0 +1,137 @@
+package symptom
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RoutineStatus is the outcome of a collection routine
+type RoutineStatus string
+
+// Routine outcomes
+const (
+	RoutineOK      RoutineStatus = "ok"
+	RoutineFailed  RoutineStatus = "failed"
+	RoutineSkipped RoutineStatus = "skipped"
+)
+
+// Collection routines, as named in CollectionResult.Routines
+const (
+	RoutineTraces   = "traces"
+	RoutinePcap     = "pcap"
+	RoutinePybot    = "pybot"
+	RoutineWatchers = "watchers"
+	RoutineSinks    = "sinks"
+	RoutineBundle   = "bundle"
+)
+
+// RoutineResult is the outcome of one collection routine. A routine fails when
+// any part of it failed, e.g. the capture of one pod; the others may still
+// have produced results.
+type RoutineResult struct {
+	Name   string        `json:"name"`
+	Status RoutineStatus `json:"status"`
+	// Errors describe every failure of the routine
+	Errors []string `json:"errors,omitempty"`
+}
+
+// newRoutineResult builds the result of a routine from its failures
+func newRoutineResult(name string, skipped bool, errs []error) RoutineResult {
+	result := RoutineResult{Name: name, Status: RoutineOK}
+	for _, err := range errs {
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+	switch {
+	case len(result.Errors) > 0:
+		result.Status = RoutineFailed
+	case skipped:
+		result.Status = RoutineSkipped
+	}
+	return result
+}
+
+// EventTotals counts the events of a collection, including their repeats
+type EventTotals struct {
+	Total      int               `json:"total"`
+	BySeverity map[Severity]int  `json:"by_severity"`
+	ByKind     map[EventKind]int `json:"by_kind"`
+	BySource   map[string]int    `json:"by_source"`
+}
+
+// totalEvents counts events by severity, kind and source
+func totalEvents(events []ErrorEvent) EventTotals {
+	totals := EventTotals{
+		BySeverity: make(map[Severity]int),
+		ByKind:     make(map[EventKind]int),
+		BySource:   make(map[string]int),
+	}
+	for _, event := range events {
+		n := max(event.Count, 1)
+		totals.Total += n
+		totals.BySeverity[event.Severity] += n
+		totals.ByKind[event.Kind] += n
+		totals.BySource[event.Source] += n
+	}
+	return totals
+}
+
+// AtLeast returns the number of events of at least severity
+func (t EventTotals) AtLeast(severity Severity) int {
+	total := 0
+	for s, n := range t.BySeverity {
+		if s.Level() >= severity.Level() {
+			total += n
+		}
+	}
+	return total
+}
+
+// FailedRoutines returns the routines that failed
+func (r *CollectionResult) FailedRoutines() []RoutineResult {
+	var failed []RoutineResult
+	for _, routine := range r.Routines {
+		if routine.Status == RoutineFailed {
+			failed = append(failed, routine)
+		}
+	}
+	return failed
+}
+
+// ExitPolicy decides whether a finished collection counts as failed, e.g. for
+// the exit status of symptom-collection
+type ExitPolicy struct {
+	// FailOn fails the collection on any event of at least this severity;
+	// empty ignores events
+	FailOn Severity
+	// Routines fails the collection when a routine failed
+	Routines bool
+	// Tests fails the collection when pybot reported failed tests
+	Tests bool
+}
+
+// Violations returns why result fails the policy, empty when it passes
+func (p ExitPolicy) Violations(result *CollectionResult) []string {
+	var violations []string
+	if p.FailOn != "" {
+		if n := result.Totals.AtLeast(p.FailOn); n > 0 {
+			violations = append(violations, fmt.Sprintf("%d events of severity %s or higher", n, p.FailOn))
+		}
+	}
+	if p.Routines {
+		failed := result.FailedRoutines()
+		names := make([]string, 0, len(failed))
+		for _, routine := range failed {
+			names = append(names, routine.Name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			violations = append(violations, fmt.Sprintf("routine %s failed", name))
+		}
+	}
+	if p.Tests && result.Pybot != nil && result.Pybot.Status == PybotFailed {
+		violations = append(violations, fmt.Sprintf("pybot reported %d failed tests", result.Pybot.FailedTests))
+	}
+	return violations
+}
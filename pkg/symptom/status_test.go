@@ -0,0 +1,91 @@
+package symptom
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewRoutineResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		skipped bool
+		errs    []error
+		want    RoutineResult
+	}{
+		{name: "ok", errs: []error{nil}, want: RoutineResult{Name: "pcap", Status: RoutineOK}},
+		{name: "skipped", skipped: true, want: RoutineResult{Name: "pcap", Status: RoutineSkipped}},
+		{
+			name:    "failed",
+			skipped: true,
+			errs:    []error{nil, errors.New("uecm-a: tcpdump not found")},
+			want:    RoutineResult{Name: "pcap", Status: RoutineFailed, Errors: []string{"uecm-a: tcpdump not found"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRoutineResult("pcap", tt.skipped, tt.errs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRoutineResult() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalEvents(t *testing.T) {
+	totals := totalEvents([]ErrorEvent{
+		{Kind: EventLog, Source: "/var/log/uecm.log", Severity: SeverityError, Count: 3},
+		{Kind: EventLog, Source: "/var/log/uecm.log", Severity: SeverityWarning},
+		{Kind: EventPacket, Source: "diameter", Severity: SeverityCritical, Count: 1},
+	})
+
+	if totals.Total != 5 {
+		t.Errorf("totalEvents() total = %d, want 5", totals.Total)
+	}
+	wantSeverity := map[Severity]int{SeverityError: 3, SeverityWarning: 1, SeverityCritical: 1}
+	if !reflect.DeepEqual(totals.BySeverity, wantSeverity) {
+		t.Errorf("totalEvents() by severity = %v, want %v", totals.BySeverity, wantSeverity)
+	}
+	wantSource := map[string]int{"/var/log/uecm.log": 4, "diameter": 1}
+	if !reflect.DeepEqual(totals.BySource, wantSource) {
+		t.Errorf("totalEvents() by source = %v, want %v", totals.BySource, wantSource)
+	}
+	if got := totals.AtLeast(SeverityError); got != 4 {
+		t.Errorf("AtLeast(error) = %d, want 4", got)
+	}
+	if got := totals.AtLeast(SeverityCritical); got != 1 {
+		t.Errorf("AtLeast(critical) = %d, want 1", got)
+	}
+}
+
+func TestExitPolicy(t *testing.T) {
+	result := &CollectionResult{
+		Pybot:  &PybotResult{Status: PybotFailed, FailedTests: 2},
+		Totals: totalEvents([]ErrorEvent{{Kind: EventLog, Severity: SeverityError, Count: 2}}),
+		Routines: []RoutineResult{
+			{Name: RoutineWatchers, Status: RoutineFailed, Errors: []string{"failed to start watcher k8s-events: forbidden"}},
+			{Name: RoutinePcap, Status: RoutineSkipped},
+			{Name: RoutineBundle, Status: RoutineOK},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		policy ExitPolicy
+		want   []string
+	}{
+		{name: "default", policy: ExitPolicy{}},
+		{name: "critical", policy: ExitPolicy{FailOn: SeverityCritical}},
+		{name: "error", policy: ExitPolicy{FailOn: SeverityError}, want: []string{"2 events of severity error or higher"}},
+		{name: "routines", policy: ExitPolicy{Routines: true}, want: []string{"routine watchers failed"}},
+		{name: "tests", policy: ExitPolicy{Tests: true}, want: []string{"pybot reported 2 failed tests"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Violations(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Violations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
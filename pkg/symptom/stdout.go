@@ -0,0 +1,70 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// stdoutSink prints events for a person watching the collection, e.g.
+//
+//	12:00:07 ERROR    uecm-a /Envoy [error] upstream reset for imsi-001
+//
+// Continuation lines of multi-line events are indented.
+type stdoutSink struct {
+	out io.Writer
+}
+
+func newStdoutSink(env *SinkEnv, cfg config.SinkConfig) (EventSink, error) {
+	return &stdoutSink{out: os.Stdout}, nil
+}
+
+func (s *stdoutSink) Name() string {
+	return SinkStdout
+}
+
+func (s *stdoutSink) Write(ctx context.Context, events []ErrorEvent) error {
+	var b strings.Builder
+	for _, event := range events {
+		formatEvent(&b, event)
+	}
+	_, err := io.WriteString(s.out, b.String())
+	return err
+}
+
+func (s *stdoutSink) Close(ctx context.Context) error {
+	return nil
+}
+
+// formatEvent writes the human-readable form of an event
+func formatEvent(b *strings.Builder, event ErrorEvent) {
+	severity := event.Severity
+	if severity == "" {
+		severity = SeverityError
+	}
+	where := event.Source
+	if event.Pod != "" {
+		where = event.Pod + " " + where
+	}
+	rule := ""
+	if event.Rule != "" {
+		rule = "[" + event.Rule + "] "
+	}
+
+	lines := strings.Split(event.Message, "\n")
+	fmt.Fprintf(b, "%s %-8s %s %s%s\n",
+		event.Timestamp.Format("15:04:05"), strings.ToUpper(string(severity)), where, rule, lines[0])
+	for _, line := range lines[1:] {
+		fmt.Fprintf(b, "    %s\n", line)
+	}
+	if event.TestCase != "" {
+		fmt.Fprintf(b, "    test case: %s\n", event.TestCase)
+	}
+	if event.Summary {
+		fmt.Fprintf(b, "    repeated %d times, last at %s\n", event.Count, event.LastSeen.Format("15:04:05"))
+	}
+}
@@ -0,0 +1,180 @@
+package symptom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// teardown holds the undo actions registered by the enable steps of a
+// collection. They run once, last registered first, when the collection ends,
+// is cancelled (SIGINT/SIGTERM in symptom-collection), times out or panics.
+type teardown struct {
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	steps []teardownStep
+	ran   bool
+	// running serialises the steps, also those added after run
+	running sync.Mutex
+	// failures holds the steps that panicked or timed out, by step name
+	failures map[string][]error
+}
+
+// teardownStep is an undo action bounded by its own timeout. Undo must return
+// once its context is done.
+type teardownStep struct {
+	name    string
+	timeout time.Duration
+	undo    func(ctx context.Context)
+}
+
+func newTeardown(logger *zap.Logger) *teardown {
+	return &teardown{logger: logger}
+}
+
+// add registers an undo action. After run it is run at once, so nothing
+// enabled late is left behind.
+func (t *teardown) add(name string, timeout time.Duration, undo func(ctx context.Context)) {
+	step := teardownStep{name: name, timeout: timeout, undo: undo}
+	t.mu.Lock()
+	if !t.ran {
+		t.steps = append(t.steps, step)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	t.running.Lock()
+	defer t.running.Unlock()
+	t.runStep(context.Background(), step)
+}
+
+// run runs the registered undo actions in reverse order. Later calls do
+// nothing, so every exit path may call it. The steps still run when ctx is
+// cancelled; each is bounded by its timeout and a panic in one does not stop
+// the others.
+func (t *teardown) run(ctx context.Context) {
+	t.mu.Lock()
+	if t.ran {
+		t.mu.Unlock()
+		return
+	}
+	t.ran = true
+	steps := t.steps
+	t.steps = nil
+	t.mu.Unlock()
+
+	t.running.Lock()
+	defer t.running.Unlock()
+	for i := len(steps) - 1; i >= 0; i-- {
+		t.runStep(ctx, steps[i])
+	}
+}
+
+func (t *teardown) runStep(ctx context.Context, step teardownStep) {
+	stepCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), step.timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := recoverPanic(t.logger, "teardown step "+step.name, func() { step.undo(stepCtx) }); err != nil {
+		t.fail(step.name, err)
+		return
+	}
+	if stepCtx.Err() != nil {
+		t.logger.Warn("Teardown step timed out", zap.String("step", step.name), zap.Duration("timeout", step.timeout))
+		t.fail(step.name, fmt.Errorf("teardown step %s timed out after %s", step.name, step.timeout))
+		return
+	}
+	t.logger.Info("Teardown step completed", zap.String("step", step.name), zap.Duration("duration", time.Since(start)))
+}
+
+func (t *teardown) fail(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures == nil {
+		t.failures = make(map[string][]error)
+	}
+	t.failures[name] = append(t.failures[name], err)
+}
+
+// errors returns the failures of the steps named name
+func (t *teardown) errors(name string) []error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failures[name]
+}
+
+// routineGroup runs the routines of a collection. A panicking routine is
+// recorded and cancels the collection, so the teardown starts at once.
+type routineGroup struct {
+	logger *zap.Logger
+	cancel context.CancelCauseFunc
+
+	wg sync.WaitGroup
+	mu sync.Mutex
+	// panics holds the panic of each routine that panicked, by name
+	panics map[string]error
+}
+
+func newRoutineGroup(logger *zap.Logger, cancel context.CancelCauseFunc) *routineGroup {
+	return &routineGroup{logger: logger, cancel: cancel}
+}
+
+// Go runs fn in a new goroutine
+func (g *routineGroup) Go(name string, fn func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := recoverPanic(g.logger, name, fn); err != nil {
+			g.mu.Lock()
+			if g.panics == nil {
+				g.panics = make(map[string]error)
+			}
+			g.panics[name] = err
+			g.mu.Unlock()
+			g.cancel(err)
+		}
+	}()
+}
+
+// Wait waits for every routine and returns their panics
+func (g *routineGroup) Wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.panics))
+	for name := range g.panics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, g.panics[name])
+	}
+	return errors.Join(errs...)
+}
+
+// panicOf returns the panic of the routine named name, nil if it did not panic
+func (g *routineGroup) panicOf(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.panics[name]
+}
+
+// recoverPanic runs fn and returns its panic as an error, logging the stack
+func recoverPanic(logger *zap.Logger, name string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", name, r)
+			logger.Error("Recovered from panic", zap.String("routine", name), zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,169 @@
+package symptom
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	"go.uber.org/zap"
+)
+
+func TestTeardown(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	step := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	teardown := newTeardown(zap.NewNop())
+	teardown.add("traces", time.Second, step("traces"))
+	teardown.add("pcap", time.Second, func(ctx context.Context) {
+		step("pcap")(ctx)
+		panic("capture gone")
+	})
+	teardown.add("watchers", 50*time.Millisecond, func(ctx context.Context) {
+		// Steps get a live context of their own even when the collection was cancelled
+		select {
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Errorf("teardown step context error = %v, want its own deadline", ctx.Err())
+			}
+		case <-time.After(5 * time.Second):
+		}
+		step("watchers")(ctx)
+	})
+
+	teardown.run(ctx)
+	teardown.run(ctx)
+	teardown.add("late", time.Second, step("late"))
+
+	want := []string{"watchers", "pcap", "traces", "late"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("teardown ran %v, want %v", ran, want)
+	}
+}
+
+func TestTeardownStartHangs(t *testing.T) {
+	collector, _, _ := newTestCollector(t)
+	// Neither session finishes starting, as with an exec stuck on an unreachable pod
+	traces := newTraceSession(newFakeTraceDriver())
+	captures := newPcapSession()
+
+	teardown := newTeardown(zap.NewNop())
+	teardown.add(RoutineTraces, 50*time.Millisecond, func(ctx context.Context) {
+		if _, err := collector.disableTraces(ctx, traces); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("disableTraces() error = %v, want the step deadline", err)
+		}
+	})
+	teardown.add(RoutinePcap, 50*time.Millisecond, func(ctx context.Context) {
+		if _, _, err := collector.disablePcap(ctx, captures, t.TempDir()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("disablePcap() error = %v, want the step deadline", err)
+		}
+	})
+
+	start := time.Now()
+	teardown.run(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("teardown took %v, want it bounded by the step timeouts", elapsed)
+	}
+	for _, name := range []string{RoutineTraces, RoutinePcap} {
+		if errs := teardown.errors(name); len(errs) != 1 || !strings.Contains(errs[0].Error(), "timed out") {
+			t.Errorf("teardown step %s errors = %v, want it timed out", name, errs)
+		}
+	}
+}
+
+func TestRoutineGroup(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	routines := newRoutineGroup(zap.NewNop(), cancel)
+	routines.Go("pybot", func() {})
+	routines.Go("traces", func() { panic("nil map") })
+
+	err := routines.Wait()
+	if err == nil || !strings.Contains(err.Error(), "traces panicked: nil map") {
+		t.Fatalf("Wait() error = %v, want the traces panic", err)
+	}
+	if cause := context.Cause(ctx); cause == nil || cause.Error() != err.Error() {
+		t.Errorf("collection cancelled with %v, want the panic", cause)
+	}
+}
+
+func TestCollectInterrupted(t *testing.T) {
+	collector, executor, _ := newTestCollector(t,
+		newNamespace("miniudm"),
+		newDeployment("miniudm", "uecm", 1, 1),
+		newPod("miniudm", "uecm-a", "uecm"),
+		newDeployment("miniudm", "testclient", 1, 1),
+		newPod("miniudm", "testclient-a", "testclient"),
+	)
+	collector.config.Pybot = config.PybotConfig{Pod: "testclient", OutputDir: "/tmp/pybot", Suites: []string{"suites/uecm"}}
+	collector.config.Symptom.Trace = config.TraceConfig{Enabled: true, Driver: t.Name(), Processes: []config.TraceProcessConfig{uecmTrace}}
+	driver := newFakeTraceDriver()
+	driver.SetProcesses("uecm-a", Process{PID: 42, Command: "/opt/mcc/bin/uecm"})
+	RegisterTraceDriver(t.Name(), func(env *TraceEnv) (TraceDriver, error) { return driver, nil })
+	executor.On("uecm-a", tailCommand, tailStream())
+	executor.On("testclient-a", pybotCommand, fake.Stream("Suite Uecm"))
+	executor.On("testclient-a", "sh -c "+stopPybotScript, fake.Reply("", 0))
+	executor.On("testclient-a", "sh -c tar cf -", tarReply(t, map[string]string{"tmp/pybot/output.xml": testOutputXML}))
+	collector.config.Symptom.Pcap = config.PcapConfig{Enabled: true, Mode: string(PcapExec), Interface: "eth0"}
+	executor.On("uecm-a", "sh -c command -v tcpdump", fake.Reply("/usr/sbin/tcpdump\n", 0))
+	executor.On("uecm-a", "sh -c "+startPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c "+stopPcapScript, fake.Reply("", 0))
+	executor.On("uecm-a", "sh -c tar cf -", tarReply(t, map[string]string{"tmp/symptom-pcap/capture.pcap": "pcap"}))
+	executor.On("uecm-a", `sh -c rm -rf "$1" sh /tmp/symptom-pcap`, fake.Reply("", 0))
+
+	// The interrupt arrives while pybot runs, as a Ctrl-C would. The client is
+	// created from the same context, as symptom-collection does.
+	ctx, cancel := context.WithCancel(context.Background())
+	collector.k8sClient.(*kubernetes.Client).Context = ctx
+	time.AfterFunc(300*time.Millisecond, cancel)
+
+	result, err := collector.StartCollection(ctx, "miniudm", []string{"uecm"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("StartCollection() error = %v, want the interrupt", err)
+	}
+	if result.Pybot.Status != PybotCancelled {
+		t.Errorf("StartCollection() pybot status = %s, want cancelled", result.Pybot.Status)
+	}
+	if len(result.Traces) != 1 || !result.Traces[0].Enabled || !result.Traces[0].Disabled {
+		t.Errorf("StartCollection() traces = %+v, want uecm-a 42 enabled and disabled", result.Traces)
+	}
+	if enabled := driver.Enabled(); len(enabled) != 0 {
+		t.Errorf("tracing still enabled for %v", enabled)
+	}
+	if len(result.Captures) != 1 || result.Captures[0].Files != 1 {
+		t.Errorf("StartCollection() captures = %+v, want the uecm-a capture copied", result.Captures)
+	}
+	if result.Pybot.Robot == nil {
+		t.Errorf("StartCollection() did not fetch the pybot output: %s", result.Pybot.Error)
+	}
+	stopped := false
+	for _, call := range executor.Calls() {
+		stopped = stopped || strings.HasPrefix(strings.Join(call.Command, " "), "sh -c "+stopPybotScript)
+	}
+	if !stopped {
+		t.Error("StartCollection() left pybot running on the testclient pod")
+	}
+	if _, err := os.Stat(result.BundlePath); err != nil {
+		t.Errorf("StartCollection() did not write the partial bundle: %v", err)
+	}
+}
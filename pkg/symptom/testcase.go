@@ -0,0 +1,113 @@
+package symptom
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+	"go.uber.org/zap"
+)
+
+// TestSummary pairs a pybot test case with the errors detected while it ran
+type TestSummary struct {
+	Test   *robot.Test
+	Events []ErrorEvent
+}
+
+// String describes the test outcome and its errors, e.g.
+// "TC_UECM_Register_07 failed; 3 Envoy errors and 1 core occurred during it"
+func (s TestSummary) String() string {
+	outcome := strings.ToLower(string(s.Test.Status))
+	switch s.Test.Status {
+	case robot.StatusPass:
+		outcome = "passed"
+	case robot.StatusFail:
+		outcome = "failed"
+	case robot.StatusSkip:
+		outcome = "skipped"
+	}
+
+	summary := fmt.Sprintf("%s %s", s.Test.Name, outcome)
+	if len(s.Events) == 0 {
+		return summary
+	}
+
+	// Cores are counted on their own, log errors per source file
+	counts := make(map[string]int)
+	for _, event := range s.Events {
+		label := path.Base(event.Source) + " error"
+		if event.Kind == EventCore {
+			label = "core"
+		}
+		counts[label] += max(event.Count, 1)
+	}
+	labels := make([]string, 0, len(counts))
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		count := counts[label]
+		if count != 1 {
+			label += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", count, label))
+	}
+
+	list := parts[0]
+	if len(parts) > 1 {
+		list = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+	return fmt.Sprintf("%s; %s occurred during it", summary, list)
+}
+
+// TestSummaries returns every pybot test case with the errors detected while it
+// ran, in execution order. It is empty when no pybot results were collected.
+func (r *CollectionResult) TestSummaries() []TestSummary {
+	if r.Pybot == nil || r.Pybot.Robot == nil {
+		return nil
+	}
+
+	tests := r.Pybot.Robot.Tests()
+	summaries := make([]TestSummary, 0, len(tests))
+	for _, test := range tests {
+		summary := TestSummary{Test: test}
+		for _, event := range r.Events {
+			if event.TestCase == test.LongName() {
+				summary.Events = append(summary.Events, event)
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// correlateTestCases attaches each event to the pybot test running at its
+// timestamp and logs the tests that failed or saw errors
+func (c *Collector) correlateTestCases(result *CollectionResult) {
+	if result.Pybot == nil || result.Pybot.Robot == nil {
+		return
+	}
+
+	for i := range result.Events {
+		if test := result.Pybot.Robot.TestAt(result.Events[i].Timestamp); test != nil {
+			result.Events[i].TestCase = test.LongName()
+		}
+	}
+
+	for _, summary := range result.TestSummaries() {
+		if summary.Test.Status != robot.StatusFail && len(summary.Events) == 0 {
+			continue
+		}
+		c.logger.Info(summary.String(),
+			zap.String("test", summary.Test.LongName()),
+			zap.String("status", string(summary.Test.Status)),
+			zap.String("message", summary.Test.Message),
+			zap.Int("errors", len(summary.Events)),
+		)
+	}
+}
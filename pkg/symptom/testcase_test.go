@@ -0,0 +1,59 @@
+package symptom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/robot"
+	"go.uber.org/zap"
+)
+
+func TestCorrelateTestCases(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	register06 := &robot.Test{Name: "TC_UECM_Register_06", Suite: "Uecm", Status: robot.StatusPass,
+		Start: start, End: start.Add(5 * time.Second)}
+	register07 := &robot.Test{Name: "TC_UECM_Register_07", Suite: "Uecm", Status: robot.StatusFail,
+		Start: start.Add(5 * time.Second), End: start.Add(10 * time.Second)}
+
+	result := &CollectionResult{
+		Pybot: &PybotResult{Robot: &robot.Result{Suite: &robot.Suite{
+			Name:  "Uecm",
+			Tests: []*robot.Test{register06, register07},
+		}}},
+		Events: []ErrorEvent{
+			{Timestamp: start.Add(6 * time.Second), Source: "/Envoy", Message: "upstream reset"},
+			{Timestamp: start.Add(7 * time.Second), Source: "/Envoy", Message: "upstream reset"},
+			{Timestamp: start.Add(8 * time.Second), Source: "/dumplog", Message: "SIGSEGV"},
+			{Timestamp: start.Add(8 * time.Second), Kind: EventCore, Source: "/logstore/TspCore", Core: &CoreInfo{Process: "uecm"}},
+			{Timestamp: start.Add(9 * time.Second), Source: "/Envoy", Message: "upstream reset"},
+			{Timestamp: start.Add(time.Minute), Source: "/Envoy", Message: "after the run"},
+		},
+	}
+
+	collector := &Collector{logger: zap.NewNop()}
+	collector.correlateTestCases(result)
+
+	if got := result.Events[0].TestCase; got != "Uecm.TC_UECM_Register_07" {
+		t.Errorf("correlateTestCases() TestCase = %q, want Uecm.TC_UECM_Register_07", got)
+	}
+	if got := result.Events[5].TestCase; got != "" {
+		t.Errorf("correlateTestCases() TestCase = %q for event outside the run, want none", got)
+	}
+
+	summaries := result.TestSummaries()
+	if len(summaries) != 2 {
+		t.Fatalf("TestSummaries() returned %d summaries, want 2", len(summaries))
+	}
+	tests := []struct {
+		summary TestSummary
+		want    string
+	}{
+		{summary: summaries[0], want: "TC_UECM_Register_06 passed"},
+		{summary: summaries[1], want: "TC_UECM_Register_07 failed; 3 Envoy errors, 1 core and 1 dumplog error occurred during it"},
+	}
+	for _, tt := range tests {
+		if got := tt.summary.String(); got != tt.want {
+			t.Errorf("TestSummary.String() = %q, want %q", got, tt.want)
+		}
+	}
+}
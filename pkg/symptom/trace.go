@@ -0,0 +1,437 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"go.uber.org/zap"
+)
+
+// TraceShell is the built-in trace driver, used as symptom.trace.driver
+const TraceShell = "shell"
+
+const (
+	// traceEnableTimeout bounds discovering the processes and enabling their tracing
+	traceEnableTimeout = 2 * time.Minute
+	// traceDisableTimeout bounds disabling the tracing of every process
+	traceDisableTimeout = 2 * time.Minute
+)
+
+// listProcessesScript prints the pid and command line of every process in the
+// container, one per line, leaving out its own shell
+const listProcessesScript = `for d in /proc/[0-9]*; do
+	pid=${d#/proc/}
+	[ "$pid" = "$$" ] && continue
+	cmd=$(tr '\0' ' ' <"$d/cmdline" 2>/dev/null)
+	[ -n "$cmd" ] && printf '%s %s\n' "$pid" "$cmd"
+done
+exit 0`
+
+// Process is a process running in a target container
+type Process struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+// TraceTarget is a process whose tracing a driver switches
+type TraceTarget struct {
+	Namespace string
+	Pod       TargetPod
+	Process   Process
+	// Config is the process type the process matched
+	Config config.TraceProcessConfig
+}
+
+// TraceDriver discovers the processes of target containers and switches their
+// tracing. Disable is called for every target Enable was called for, also when
+// Enable failed, as tracing may then be partly enabled.
+type TraceDriver interface {
+	// Name identifies the driver in logs
+	Name() string
+	// Processes lists the processes running in the container of pod
+	Processes(ctx context.Context, namespace string, pod TargetPod) ([]Process, error)
+	Enable(ctx context.Context, target TraceTarget) error
+	Disable(ctx context.Context, target TraceTarget) error
+}
+
+// TraceEnv is what a TraceDriverFactory needs to build a driver for a collection run
+type TraceEnv struct {
+	Client kubernetes.ClusterClient
+	Config *config.Config
+	Logger *zap.Logger
+}
+
+// TraceDriverFactory builds the configured trace driver
+type TraceDriverFactory func(env *TraceEnv) (TraceDriver, error)
+
+var (
+	traceDriversMu sync.RWMutex
+	traceDrivers   = make(map[string]TraceDriverFactory)
+)
+
+// RegisterTraceDriver makes a trace driver available to symptom.trace.driver.
+// Registering an existing driver replaces it.
+func RegisterTraceDriver(driver string, factory TraceDriverFactory) {
+	traceDriversMu.Lock()
+	defer traceDriversMu.Unlock()
+	traceDrivers[driver] = factory
+}
+
+// RegisteredTraceDrivers returns the registered trace drivers
+func RegisteredTraceDrivers() []string {
+	traceDriversMu.RLock()
+	defer traceDriversMu.RUnlock()
+	drivers := make([]string, 0, len(traceDrivers))
+	for driver := range traceDrivers {
+		drivers = append(drivers, driver)
+	}
+	sort.Strings(drivers)
+	return drivers
+}
+
+func init() {
+	RegisterTraceDriver(TraceShell, newShellTraceDriver)
+}
+
+// buildTraceDriver creates the configured trace driver, nil when tracing is disabled
+func buildTraceDriver(env *TraceEnv) (TraceDriver, error) {
+	cfg := env.Config.Symptom.Trace
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	name := cfg.Driver
+	if name == "" {
+		name = TraceShell
+	}
+	traceDriversMu.RLock()
+	factory, ok := traceDrivers[name]
+	traceDriversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown trace driver %q (registered: %s)", name, strings.Join(RegisteredTraceDrivers(), ", "))
+	}
+	driver, err := factory(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace driver: %w", name, err)
+	}
+	return driver, nil
+}
+
+// traceProcess is a configured process type with its compiled pattern
+type traceProcess struct {
+	cfg   config.TraceProcessConfig
+	match *regexp.Regexp
+}
+
+// compileTraceProcesses validates and compiles the configured process types
+func compileTraceProcesses(cfg config.TraceConfig) ([]traceProcess, error) {
+	processes := make([]traceProcess, 0, len(cfg.Processes))
+	for i, process := range cfg.Processes {
+		if process.Name == "" {
+			return nil, fmt.Errorf("trace process %d has no name", i)
+		}
+		if process.Match == "" {
+			return nil, fmt.Errorf("trace process %s has no match pattern", process.Name)
+		}
+		match, err := regexp.Compile(process.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern of trace process %s: %w", process.Name, err)
+		}
+		processes = append(processes, traceProcess{cfg: process, match: match})
+	}
+	return processes, nil
+}
+
+// Trace is the tracing of one process in a target container. A process type
+// without matching processes, or a container whose processes could not be
+// listed, is reported as a Trace without PID.
+type Trace struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	// Process is the process type
+	Process string `json:"process"`
+	PID     int    `json:"pid,omitempty"`
+	Command string `json:"command,omitempty"`
+	// Enabled is set once tracing was enabled and Disabled once it was disabled again
+	Enabled  bool `json:"enabled"`
+	Disabled bool `json:"disabled"`
+	// Error is why tracing failed to be enabled or disabled; empty on success
+	Error string `json:"error,omitempty"`
+}
+
+// traceSession holds the traced processes of a collection run from
+// enableTraces until disableTraces
+type traceSession struct {
+	// started is closed once tracing was enabled in every target or failed to
+	started chan struct{}
+	driver  TraceDriver
+	traces  []*processTrace
+}
+
+func newTraceSession(driver TraceDriver) *traceSession {
+	return &traceSession{started: make(chan struct{}), driver: driver}
+}
+
+// processTrace is the tracing of one process, or a failure to find processes
+type processTrace struct {
+	target TraceTarget
+	// attempted is set once Enable was called, so Disable must be too
+	attempted bool
+	result    Trace
+}
+
+// results returns the outcome of every trace of the session
+func (s *traceSession) results() []Trace {
+	traces := make([]Trace, 0, len(s.traces))
+	for _, trace := range s.traces {
+		traces = append(traces, trace.result)
+	}
+	return traces
+}
+
+// enableTraces discovers the configured processes in every target container
+// and enables their tracing in parallel. Failures are reported per process
+// and the others keep tracing.
+func (c *Collector) enableTraces(ctx context.Context, session *traceSession, namespace string, pods []TargetPod) {
+	defer close(session.started)
+
+	if session.driver == nil {
+		c.logger.Info("Process tracing is disabled")
+		return
+	}
+	processes, err := compileTraceProcesses(c.config.Symptom.Trace)
+	if err != nil {
+		c.logger.Error("Process tracing not started", zap.Error(err))
+		return
+	}
+
+	enableCtx, cancel := context.WithTimeout(ctx, traceEnableTimeout)
+	defer cancel()
+
+	// Each pod fills its own slot, so the traces keep the order of the pods
+	perPod := make([][]*processTrace, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		i, pod := i, pod // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			perPod[i] = c.enablePodTraces(enableCtx, session.driver, processes, namespace, pod)
+		}()
+	}
+	wg.Wait()
+
+	for _, traces := range perPod {
+		session.traces = append(session.traces, traces...)
+	}
+}
+
+// enablePodTraces enables the tracing of the configured processes of one pod
+func (c *Collector) enablePodTraces(ctx context.Context, driver TraceDriver, processes []traceProcess, namespace string, pod TargetPod) []*processTrace {
+	running, err := driver.Processes(ctx, namespace, pod)
+	if err != nil {
+		c.logger.Warn("Failed to list processes", zap.String("pod", pod.Name), zap.String("container", pod.Container), zap.Error(err))
+		traces := make([]*processTrace, 0, len(processes))
+		for _, process := range processes {
+			traces = append(traces, &processTrace{result: Trace{
+				Pod:       pod.Name,
+				Container: pod.Container,
+				Process:   process.cfg.Name,
+				Error:     fmt.Sprintf("failed to list processes: %v", err),
+			}})
+		}
+		return traces
+	}
+
+	var traces []*processTrace
+	for _, process := range processes {
+		matched := 0
+		for _, proc := range running {
+			if !process.match.MatchString(proc.Command) {
+				continue
+			}
+			matched++
+			traces = append(traces, &processTrace{
+				target: TraceTarget{Namespace: namespace, Pod: pod, Process: proc, Config: process.cfg},
+				result: Trace{Pod: pod.Name, Container: pod.Container, Process: process.cfg.Name, PID: proc.PID, Command: proc.Command},
+			})
+		}
+		c.logger.Info("Found processes to trace",
+			zap.String("pod", pod.Name),
+			zap.String("process", process.cfg.Name),
+			zap.Int("count", matched),
+		)
+		if matched == 0 {
+			traces = append(traces, &processTrace{result: Trace{
+				Pod:       pod.Name,
+				Container: pod.Container,
+				Process:   process.cfg.Name,
+				Error:     fmt.Sprintf("no process matches %q", process.cfg.Match),
+			}})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, trace := range traces {
+		if trace.result.PID == 0 {
+			continue
+		}
+		wg.Add(1)
+		trace := trace // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			trace.attempted = true
+			if err := driver.Enable(ctx, trace.target); err != nil {
+				trace.result.Error = fmt.Sprintf("failed to enable tracing: %v", err)
+				c.logger.Warn("Failed to enable tracing",
+					zap.String("pod", pod.Name),
+					zap.String("process", trace.result.Process),
+					zap.Int("pid", trace.result.PID),
+					zap.Error(err),
+				)
+				return
+			}
+			trace.result.Enabled = true
+			c.logger.Info("Enabled tracing",
+				zap.String("pod", pod.Name),
+				zap.String("process", trace.result.Process),
+				zap.Int("pid", trace.result.PID),
+			)
+		}()
+	}
+	wg.Wait()
+	return traces
+}
+
+// disableTraces disables in parallel the tracing of every process it was
+// enabled for, once enableTraces has finished. It gives up when ctx ends
+// before that, leaving whatever enableTraces still enables.
+func (c *Collector) disableTraces(ctx context.Context, session *traceSession) ([]Trace, error) {
+	select {
+	case <-session.started:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("tracing still being enabled: %w", ctx.Err())
+	}
+
+	var wg sync.WaitGroup
+	for _, trace := range session.traces {
+		if !trace.attempted {
+			continue
+		}
+		wg.Add(1)
+		trace := trace // Capture for goroutine
+		go func() {
+			defer wg.Done()
+			if err := session.driver.Disable(ctx, trace.target); err != nil {
+				// A failed enable already explains the trace
+				if trace.result.Error == "" {
+					trace.result.Error = fmt.Sprintf("failed to disable tracing: %v", err)
+				}
+				c.logger.Warn("Failed to disable tracing",
+					zap.String("pod", trace.result.Pod),
+					zap.String("process", trace.result.Process),
+					zap.Int("pid", trace.result.PID),
+					zap.Error(err),
+				)
+				return
+			}
+			trace.result.Disabled = true
+			c.logger.Info("Disabled tracing",
+				zap.String("pod", trace.result.Pod),
+				zap.String("process", trace.result.Process),
+				zap.Int("pid", trace.result.PID),
+			)
+		}()
+	}
+	wg.Wait()
+	return session.results(), nil
+}
+
+// shellTraceDriver lists processes from /proc and runs the configured enable
+// and disable commands with sh in the target container
+type shellTraceDriver struct {
+	client kubernetes.ClusterClient
+}
+
+func newShellTraceDriver(env *TraceEnv) (TraceDriver, error) {
+	for _, process := range env.Config.Symptom.Trace.Processes {
+		if process.Enable == "" || process.Disable == "" {
+			return nil, fmt.Errorf("trace process %s needs enable and disable commands", process.Name)
+		}
+	}
+	return &shellTraceDriver{client: env.Client}, nil
+}
+
+func (d *shellTraceDriver) Name() string {
+	return TraceShell
+}
+
+func (d *shellTraceDriver) Processes(ctx context.Context, namespace string, pod TargetPod) ([]Process, error) {
+	stdout, err := d.run(ctx, namespace, pod, listProcessesScript)
+	if err != nil {
+		return nil, err
+	}
+	return parseProcesses(stdout), nil
+}
+
+func (d *shellTraceDriver) Enable(ctx context.Context, target TraceTarget) error {
+	_, err := d.run(ctx, target.Namespace, target.Pod, expandTraceCommand(target.Config.Enable, target))
+	return err
+}
+
+func (d *shellTraceDriver) Disable(ctx context.Context, target TraceTarget) error {
+	_, err := d.run(ctx, target.Namespace, target.Pod, expandTraceCommand(target.Config.Disable, target))
+	return err
+}
+
+// run runs a shell command in the target container and fails on a non-zero exit
+func (d *shellTraceDriver) run(ctx context.Context, namespace string, pod TargetPod, command string) (string, error) {
+	result, err := d.client.ExecOutput(ctx, kubernetes.ExecOptions{
+		Namespace: namespace,
+		Pod:       pod.Name,
+		Container: pod.Container,
+		Command:   []string{"sh", "-c", command},
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return result.Stdout, nil
+}
+
+// expandTraceCommand replaces the placeholders of an enable or disable command
+func expandTraceCommand(command string, target TraceTarget) string {
+	return strings.NewReplacer(
+		"{pid}", strconv.Itoa(target.Process.PID),
+		"{process}", target.Config.Name,
+		"{pod}", target.Pod.Name,
+		"{container}", target.Pod.Container,
+		"{namespace}", target.Namespace,
+	).Replace(command)
+}
+
+// parseProcesses parses the output of listProcessesScript
+func parseProcesses(output string) []Process {
+	var processes []Process
+	for _, line := range strings.Split(output, "\n") {
+		pid, command, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(pid)
+		if err != nil || n <= 0 {
+			continue
+		}
+		processes = append(processes, Process{PID: n, Command: strings.TrimSpace(command)})
+	}
+	return processes
+}
@@ -0,0 +1,104 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// fakeTraceDriver is an in-memory TraceDriver for tests. It serves the
+// processes set per pod, fails the calls set with FailOn and records every
+// call as "processes <pod>", "enable <pod> <pid>" or "disable <pod> <pid>".
+type fakeTraceDriver struct {
+	mu        sync.Mutex
+	processes map[string][]Process
+	failures  map[string]error
+	calls     []string
+	// enabled holds the targets enabled and not yet disabled
+	enabled map[string]bool
+}
+
+// newFakeTraceDriver creates a fakeTraceDriver without processes
+func newFakeTraceDriver() *fakeTraceDriver {
+	return &fakeTraceDriver{
+		processes: make(map[string][]Process),
+		failures:  make(map[string]error),
+		enabled:   make(map[string]bool),
+	}
+}
+
+// SetProcesses sets the processes listed for a pod
+func (d *fakeTraceDriver) SetProcesses(pod string, processes ...Process) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.processes[pod] = processes
+}
+
+// FailOn makes a call, e.g. "enable uecm-a 42", fail with err
+func (d *fakeTraceDriver) FailOn(call string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures[call] = err
+}
+
+// Calls returns the calls made so far
+func (d *fakeTraceDriver) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+// Enabled returns the targets, as "<pod> <pid>", whose tracing is still enabled
+func (d *fakeTraceDriver) Enabled() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	enabled := make([]string, 0, len(d.enabled))
+	for target := range d.enabled {
+		enabled = append(enabled, target)
+	}
+	sort.Strings(enabled)
+	return enabled
+}
+
+func (d *fakeTraceDriver) Name() string {
+	return "fake"
+}
+
+func (d *fakeTraceDriver) Processes(ctx context.Context, namespace string, pod TargetPod) ([]Process, error) {
+	if err := d.call("processes " + pod.Name); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Process(nil), d.processes[pod.Name]...), nil
+}
+
+// Enable marks the target enabled even when it fails, as a real command may
+// fail after partly enabling tracing
+func (d *fakeTraceDriver) Enable(ctx context.Context, target TraceTarget) error {
+	key := fmt.Sprintf("%s %d", target.Pod.Name, target.Process.PID)
+	d.mu.Lock()
+	d.enabled[key] = true
+	d.mu.Unlock()
+	return d.call("enable " + key)
+}
+
+func (d *fakeTraceDriver) Disable(ctx context.Context, target TraceTarget) error {
+	key := fmt.Sprintf("%s %d", target.Pod.Name, target.Process.PID)
+	if err := d.call("disable " + key); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.enabled, key)
+	return nil
+}
+
+// call records a call and returns its configured failure
+func (d *fakeTraceDriver) call(call string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+	return d.failures[call]
+}
@@ -0,0 +1,164 @@
+package symptom
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+)
+
+// uecmTrace is the configured uecm process type of the trace tests
+var uecmTrace = config.TraceProcessConfig{
+	Name:    "uecm",
+	Match:   `/bin/uecm\b`,
+	Enable:  "mcc-trace --pid {pid} --name {process}.{pod} on",
+	Disable: "mcc-trace --pid {pid} off",
+}
+
+func TestParseProcesses(t *testing.T) {
+	output := "1 /sbin/tini -- /opt/mcc/bin/start\n42 /opt/mcc/bin/uecm --config /etc/uecm.yaml \n\nnot a process\n0 kernel\n"
+	want := []Process{
+		{PID: 1, Command: "/sbin/tini -- /opt/mcc/bin/start"},
+		{PID: 42, Command: "/opt/mcc/bin/uecm --config /etc/uecm.yaml"},
+	}
+	if got := parseProcesses(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseProcesses() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildTraceDriver(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.TraceConfig
+		wantNil  bool
+		wantName string
+		wantErr  bool
+	}{
+		{name: "disabled", cfg: config.TraceConfig{Driver: "unknown"}, wantNil: true},
+		{name: "default shell", cfg: config.TraceConfig{Enabled: true, Processes: []config.TraceProcessConfig{uecmTrace}}, wantName: TraceShell},
+		{name: "shell without disable command", cfg: config.TraceConfig{Enabled: true, Processes: []config.TraceProcessConfig{{Name: "uecm", Match: "uecm", Enable: "on"}}}, wantErr: true},
+		{name: "unknown driver", cfg: config.TraceConfig{Enabled: true, Driver: "unknown"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, err := buildTraceDriver(&TraceEnv{Config: &config.Config{Symptom: config.SymptomConfig{Trace: tt.cfg}}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTraceDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (driver == nil) != tt.wantNil || (driver != nil && driver.Name() != tt.wantName) {
+				t.Errorf("buildTraceDriver() = %v, want %q", driver, tt.wantName)
+			}
+		})
+	}
+
+	if _, err := compileTraceProcesses(config.TraceConfig{Processes: []config.TraceProcessConfig{{Name: "uecm", Match: "("}}}); err == nil {
+		t.Error("compileTraceProcesses() with an invalid pattern error = nil")
+	}
+}
+
+// traceTargets are the target pods of the trace tests
+var traceTargets = []TargetPod{{Name: "uecm-a", Container: "mcc"}, {Name: "uecm-b", Container: "mcc"}}
+
+// collectTraces enables and disables tracing of the target pods with driver
+func collectTraces(t *testing.T, collector *Collector, driver TraceDriver) []Trace {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collector.config.Symptom.Trace = config.TraceConfig{Enabled: true, Processes: []config.TraceProcessConfig{uecmTrace}}
+	session := newTraceSession(driver)
+	collector.enableTraces(ctx, session, "miniudm", traceTargets)
+	traces, err := collector.disableTraces(ctx, session)
+	if err != nil {
+		t.Fatalf("disableTraces() error = %v", err)
+	}
+	return traces
+}
+
+func TestTraceFakeDriver(t *testing.T) {
+	collector, _, _ := newTestCollector(t)
+	driver := newFakeTraceDriver()
+	driver.SetProcesses("uecm-a",
+		Process{PID: 1, Command: "/sbin/tini -- /opt/mcc/bin/start"},
+		Process{PID: 42, Command: "/opt/mcc/bin/uecm --worker 1"},
+		Process{PID: 43, Command: "/opt/mcc/bin/uecm --worker 2"},
+		Process{PID: 50, Command: "/opt/mcc/bin/uecmd"},
+	)
+	driver.FailOn("enable uecm-a 43", errors.New("trace buffer full"))
+	driver.FailOn("processes uecm-b", errors.New("container not running"))
+
+	traces := collectTraces(t, collector, driver)
+
+	want := []Trace{
+		{Pod: "uecm-a", Container: "mcc", Process: "uecm", PID: 42, Command: "/opt/mcc/bin/uecm --worker 1", Enabled: true, Disabled: true},
+		{Pod: "uecm-a", Container: "mcc", Process: "uecm", PID: 43, Command: "/opt/mcc/bin/uecm --worker 2", Disabled: true,
+			Error: "failed to enable tracing: trace buffer full"},
+		{Pod: "uecm-b", Container: "mcc", Process: "uecm", Error: "failed to list processes: container not running"},
+	}
+	if !reflect.DeepEqual(traces, want) {
+		t.Errorf("disableTraces() = %+v, want %+v", traces, want)
+	}
+	// Tracing that failed to enable may be partly on, so it is disabled too
+	if enabled := driver.Enabled(); len(enabled) != 0 {
+		t.Errorf("tracing still enabled for %v", enabled)
+	}
+}
+
+func TestTraceNoMatchingProcess(t *testing.T) {
+	collector, _, _ := newTestCollector(t)
+	driver := newFakeTraceDriver()
+	driver.SetProcesses("uecm-a", Process{PID: 1, Command: "/sbin/tini"})
+	driver.SetProcesses("uecm-b", Process{PID: 1, Command: "/sbin/tini"})
+
+	traces := collectTraces(t, collector, driver)
+	if len(traces) != 2 || traces[0].PID != 0 || !strings.Contains(traces[0].Error, "no process matches") {
+		t.Errorf("disableTraces() = %+v, want no matching process in each pod", traces)
+	}
+	for _, call := range driver.Calls() {
+		if !strings.HasPrefix(call, "processes ") {
+			t.Errorf("driver call %q, want only process listings", call)
+		}
+	}
+}
+
+func TestShellTraceDriver(t *testing.T) {
+	collector, executor, _ := newTestCollector(t)
+	for _, pod := range []string{"uecm-a", "uecm-b"} {
+		executor.On(pod, "sh -c "+listProcessesScript, fake.Reply("1 /sbin/tini\n42 /opt/mcc/bin/uecm\n", 0))
+	}
+	executor.On("uecm-a", "sh -c mcc-trace --pid 42 --name uecm.uecm-a on", fake.Reply("", 0))
+	executor.On("uecm-b", "sh -c mcc-trace --pid 42 --name uecm.uecm-b on", fake.Reply("", 3))
+	executor.On("", "sh -c mcc-trace --pid 42 off", fake.Reply("", 0))
+
+	collector.config.Symptom.Trace = config.TraceConfig{Enabled: true, Processes: []config.TraceProcessConfig{uecmTrace}}
+	driver, err := buildTraceDriver(&TraceEnv{Client: collector.k8sClient, Config: collector.config})
+	if err != nil {
+		t.Fatalf("buildTraceDriver() error = %v", err)
+	}
+	traces := collectTraces(t, collector, driver)
+
+	if len(traces) != 2 || !traces[0].Enabled || !traces[0].Disabled || traces[1].Enabled || !strings.Contains(traces[1].Error, "exited with code 3") {
+		t.Fatalf("disableTraces() = %+v, want uecm-a traced and uecm-b failed", traces)
+	}
+	var disabled []string
+	for _, call := range executor.Calls() {
+		if call.Container != "mcc" {
+			t.Errorf("trace command ran in container %q, want mcc", call.Container)
+		}
+		if strings.Join(call.Command, " ") == "sh -c mcc-trace --pid 42 off" {
+			disabled = append(disabled, call.Pod)
+		}
+	}
+	if len(disabled) != 2 {
+		t.Errorf("disable ran in %v, want both pods", disabled)
+	}
+}
@@ -0,0 +1,244 @@
+package symptom
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Built-in watcher types, used as symptom.sources[].type
+const (
+	SourceLogFile  = "logfile"
+	SourceCores    = "cores"
+	SourceEvents   = "events"
+	SourceRestarts = "restarts"
+	SourcePodLogs  = "podlogs"
+)
+
+// EmitFunc reports an event detected by a watcher. It is safe for concurrent use.
+type EmitFunc func(event ErrorEvent)
+
+// Watcher is a source of symptoms, such as a tailed log file or a core directory.
+// Start begins watching in the background and returns once the watcher runs;
+// Stop ends the watch and waits for it to finish writing its artifacts.
+type Watcher interface {
+	// Name identifies the watcher in logs, e.g. "logfile uecm-0:/Envoy"
+	Name() string
+	Start(ctx context.Context, emit EmitFunc) error
+	Stop(ctx context.Context) error
+	// Artifacts lists the files the watcher collected; it is complete after Stop
+	Artifacts() []Artifact
+}
+
+// Artifact is a file collected into the run directory
+type Artifact struct {
+	// Kind is the kind of file, e.g. "log", "core" or "events"
+	Kind      string `json:"kind"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	// Source is the path or stream the file was collected from
+	Source string `json:"source,omitempty"`
+	// Path is the local file; in a bundle manifest it is relative to the bundle root
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// WatcherEnv is what a WatcherFactory needs to build watchers for a collection run
+type WatcherEnv struct {
+	Client    kubernetes.ClusterClient
+	Config    *config.Config
+	Logger    *zap.Logger
+	Namespace string
+	Targets   []TargetPod
+	// OutputDir is the run directory artifacts are written below
+	OutputDir string
+	// StartTime is when the collection started
+	StartTime time.Time
+	// Rules classify log lines; lines matching no rule are not reported
+	Rules *RuleSet
+	// Multiline groups multi-line records before they are matched
+	Multiline []*Multiline
+}
+
+// WatcherFactory builds the watchers for one configured source
+type WatcherFactory func(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error)
+
+var (
+	watchersMu sync.RWMutex
+	watchers   = make(map[string]WatcherFactory)
+)
+
+// RegisterWatcher makes a watcher type available to symptom.sources. Registering
+// an existing type replaces it, so the built-in watchers can be overridden.
+func RegisterWatcher(sourceType string, factory WatcherFactory) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	watchers[sourceType] = factory
+}
+
+// RegisteredWatchers returns the registered watcher types
+func RegisteredWatchers() []string {
+	watchersMu.RLock()
+	defer watchersMu.RUnlock()
+	types := make([]string, 0, len(watchers))
+	for sourceType := range watchers {
+		types = append(types, sourceType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func init() {
+	RegisterWatcher(SourceLogFile, newLogFileWatchers)
+	RegisterWatcher(SourceCores, newCoreWatchers)
+	RegisterWatcher(SourceEvents, newEventWatchers)
+	RegisterWatcher(SourceRestarts, newRestartWatchers)
+	RegisterWatcher(SourcePodLogs, newPodLogWatchers)
+}
+
+// sources returns the configured symptom sources. Without explicit sources the
+// log paths are tailed, core directories polled and events and restarts watched.
+func sources(cfg *config.Config) []config.SourceConfig {
+	if len(cfg.Symptom.Sources) > 0 {
+		return cfg.Symptom.Sources
+	}
+
+	coreDirs := cfg.Symptom.CoreDirs
+	if len(coreDirs) == 0 {
+		coreDirs = []string{"/logstore/TspCore"}
+	}
+	logPaths := cfg.Paths.LogPaths
+	if len(logPaths) == 0 {
+		logPaths = []string{
+			"/cmconfig.log",
+			"/RTPTraceError",
+			"/Envoy",
+			"/dumplog",
+		}
+	}
+	var files []string
+	for _, logPath := range logPaths {
+		// Core directories hold binary dumps, not log lines
+		if !containsPath(coreDirs, logPath) {
+			files = append(files, logPath)
+		}
+	}
+
+	return []config.SourceConfig{
+		{Type: SourceLogFile, Paths: files},
+		{Type: SourceCores, Paths: coreDirs},
+		{Type: SourceEvents},
+		{Type: SourceRestarts},
+	}
+}
+
+// buildWatchers creates the watchers for every configured source
+func buildWatchers(env *WatcherEnv) ([]Watcher, error) {
+	var built []Watcher
+	for _, source := range sources(env.Config) {
+		watchersMu.RLock()
+		factory, ok := watchers[source.Type]
+		watchersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown symptom source type %q (registered: %s)",
+				source.Type, strings.Join(RegisteredWatchers(), ", "))
+		}
+
+		sourceWatchers, err := factory(env, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s watchers: %w", source.Type, err)
+		}
+		built = append(built, sourceWatchers...)
+	}
+	return built, nil
+}
+
+// interval returns how often polling watchers check their source
+func (env *WatcherEnv) interval() time.Duration {
+	if env.Config.Symptom.CheckInterval > 0 {
+		return env.Config.Symptom.CheckInterval
+	}
+	return 1 * time.Second
+}
+
+// lineGrouper returns a grouper for lines read from source that reports every
+// block matching a rule through report
+func (env *WatcherEnv) lineGrouper(source string, report func(event ErrorEvent)) *lineGrouper {
+	return newLineGrouper(multilineFor(env.Multiline, source), func(ts time.Time, block string) {
+		if event := env.Rules.classify(source, ts, block); event != nil {
+			report(*event)
+		}
+	})
+}
+
+// artifactPath returns a local path below the run directory for a remote path,
+// e.g. logs/uecm-0/Envoy.log for /Envoy in pod uecm-0
+func (env *WatcherEnv) artifactPath(kind, pod, remotePath, ext string) string {
+	name := strings.Trim(strings.ReplaceAll(remotePath, "/", "_"), "_")
+	if name == "" {
+		name = kind
+	}
+	return filepath.Join(env.OutputDir, kind, pod, name+ext)
+}
+
+// newArtifact describes a collected local file, hashing its content
+func newArtifact(kind string, target TargetPod, source, path string) (Artifact, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to stat artifact: %w", err)
+	}
+	hash, err := utils.CalculateSHA256(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	return Artifact{
+		Kind:      kind,
+		Pod:       target.Name,
+		Container: target.Container,
+		Source:    source,
+		Path:      path,
+		Size:      info.Size(),
+		SHA256:    hash,
+	}, nil
+}
+
+// loop runs a watcher's work in the background between Start and Stop
+type loop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start runs fn until the context is cancelled or stop is called
+func (l *loop) start(ctx context.Context, fn func(ctx context.Context)) {
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		fn(ctx)
+	}()
+}
+
+// stop cancels the work and waits for it to return, or for ctx to end
+func (l *loop) stop(ctx context.Context) error {
+	if l.cancel == nil {
+		return nil
+	}
+	l.cancel()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("watcher did not stop: %w", ctx.Err())
+	}
+}
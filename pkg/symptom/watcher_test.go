@@ -0,0 +1,383 @@
+package symptom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes"
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/kubernetes/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestEnv returns a watcher environment using the collector's client and config
+func newTestEnv(c *Collector, targets ...TargetPod) *WatcherEnv {
+	return &WatcherEnv{
+		Client:    c.k8sClient,
+		Config:    c.config,
+		Logger:    c.logger,
+		Namespace: "miniudm",
+		Targets:   targets,
+		OutputDir: c.config.Symptom.OutputDir,
+		StartTime: time.Now(),
+		Rules:     mustRules(c.config),
+	}
+}
+
+// tailCommand is the start of the command that follows a log file
+const tailCommand = "sh -c " + tailLogScript
+
+// tailStream answers a log tail like fake.Stream, after the size and offset
+// header of a file that was empty when the tail started
+func tailStream(lines ...string) fake.Handler {
+	return fake.Stream(append([]string{"0 0"}, lines...)...)
+}
+
+// mustRules compiles the rules of a test configuration
+func mustRules(cfg *config.Config) *RuleSet {
+	rules, err := rulesFor(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// staticWatcher emits a fixed event on start and reports a fixed artifact
+type staticWatcher struct {
+	event    ErrorEvent
+	artifact Artifact
+}
+
+func (w *staticWatcher) Name() string { return "static" }
+
+func (w *staticWatcher) Start(ctx context.Context, emit EmitFunc) error {
+	emit(w.event)
+	return nil
+}
+
+func (w *staticWatcher) Stop(ctx context.Context) error { return nil }
+
+func (w *staticWatcher) Artifacts() []Artifact { return []Artifact{w.artifact} }
+
+func TestBuildWatchers(t *testing.T) {
+	tests := []struct {
+		name      string
+		sources   []config.SourceConfig
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "default sources",
+			wantNames: []string{
+				"logfile uecm-a:/cmconfig.log",
+				"cores uecm-a:/logstore/TspCore",
+				"events miniudm",
+				"restarts miniudm",
+			},
+		},
+		{
+			name: "configured sources",
+			sources: []config.SourceConfig{
+				{Type: SourceLogFile, Paths: []string{"/Envoy", "/dumplog"}},
+				{Type: SourcePodLogs},
+			},
+			wantNames: []string{
+				"logfile uecm-a:/Envoy",
+				"logfile uecm-a:/dumplog",
+				"podlogs uecm-a/mcc",
+			},
+		},
+		{
+			name:    "unknown source type",
+			sources: []config.SourceConfig{{Type: "syslog"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, _, _ := newTestCollector(t)
+			collector.config.Symptom.Sources = tt.sources
+
+			watchers, err := buildWatchers(newTestEnv(collector, TargetPod{Name: "uecm-a", Container: "mcc"}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildWatchers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, watcher := range watchers {
+				names = append(names, watcher.Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("buildWatchers() = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestRegisterWatcher(t *testing.T) {
+	RegisterWatcher("static", func(env *WatcherEnv, source config.SourceConfig) ([]Watcher, error) {
+		return []Watcher{&staticWatcher{
+			event:    ErrorEvent{Timestamp: time.Now(), Kind: EventLog, Source: source.Options["name"], Message: "custom symptom"},
+			artifact: Artifact{Kind: "custom", Path: filepath.Join(env.OutputDir, "custom.txt")},
+		}}, nil
+	})
+
+	collector, _, _ := newTestCollector(t,
+		newNamespace("miniudm"),
+		newDeployment("miniudm", "uecm", 1, 1),
+		newPod("miniudm", "uecm-a", "uecm"),
+	)
+	collector.config.Symptom.Sources = []config.SourceConfig{{Type: "static", Options: map[string]string{"name": "probe"}}}
+	collector.config.Symptom.CollectionTimeout = 500 * time.Millisecond
+
+	result, err := collector.StartCollection(context.Background(), "miniudm", []string{"uecm"})
+	if err != nil {
+		t.Fatalf("StartCollection() error = %v", err)
+	}
+	if len(result.Events) != 1 || result.Events[0].Source != "probe" {
+		t.Errorf("StartCollection() events = %+v, want the custom watcher's event", result.Events)
+	}
+	if len(result.Artifacts) != 1 || result.Artifacts[0].Kind != "custom" {
+		t.Errorf("StartCollection() artifacts = %+v, want the custom watcher's artifact", result.Artifacts)
+	}
+
+	streamed, err := ReadEvents(filepath.Join(result.OutputDir, defaultJSONLPath))
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(streamed) != 1 || streamed[0].Message != "custom symptom" {
+		t.Errorf("events.jsonl = %+v, want the custom watcher's event", streamed)
+	}
+	if len(result.Sinks) != 1 || result.Sinks[0].Written != 1 {
+		t.Errorf("StartCollection() sink stats = %+v, want 1 event written", result.Sinks)
+	}
+}
+
+func TestLogFileWatcher(t *testing.T) {
+	collector, executor, _ := newTestCollector(t)
+	executor.On("uecm-a", tailCommand, tailStream(
+		"2024-03-01 INFO registration ok",
+		"2024-03-01 ERROR registration failed for imsi-001",
+	))
+
+	target := TargetPod{Name: "uecm-a", Container: "mcc"}
+	watcher := &logFileWatcher{env: newTestEnv(collector, target), target: target, path: "/Envoy"}
+	events := make(chan ErrorEvent, 10)
+	if err := watcher.Start(context.Background(), func(event ErrorEvent) { events <- event }); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Kind != EventLog || event.Source != "/Envoy" || !strings.Contains(event.Message, "imsi-001") ||
+			event.Rule != "error" || event.Severity != SeverityError {
+			t.Errorf("logFileWatcher event = %+v, want the ERROR line", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("logFileWatcher reported no error")
+	}
+	if err := watcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	artifacts := watcher.Artifacts()
+	if len(artifacts) != 1 {
+		t.Fatalf("Artifacts() = %+v, want the log copy", artifacts)
+	}
+	data, err := os.ReadFile(artifacts[0].Path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 || artifacts[0].SHA256 == "" {
+		t.Errorf("log copy has %d lines (hash %q), want 2 hashed lines", lines, artifacts[0].SHA256)
+	}
+}
+
+func TestLogFileTail(t *testing.T) {
+	collector, executor, _ := newTestCollector(t)
+	// The first exec stream drops after one line. The watcher resumes after
+	// it, but by then the file was rotated, so the new file is read from its start.
+	dropped := "2024-03-01 ERROR Diameter peer hss1 closed"
+	var mu sync.Mutex
+	sessions := 0
+	executor.On("uecm-a", tailCommand, func(ctx context.Context, opts kubernetes.ExecOptions) (*kubernetes.ExecResult, error) {
+		mu.Lock()
+		sessions++
+		session := sessions
+		mu.Unlock()
+		if session == 1 {
+			return fake.Reply("120 120\n"+dropped+"\n", 137)(ctx, opts)
+		}
+		return fake.Stream("64 0", "2024-03-01 INFO peer hss1 open", "2024-03-01 FATAL registration aborted")(ctx, opts)
+	})
+
+	target := TargetPod{Name: "uecm-a", Container: "mcc"}
+	watcher := &logFileWatcher{env: newTestEnv(collector, target), target: target, path: "/cmconfig.log"}
+	events := make(chan ErrorEvent, 10)
+	if err := watcher.Start(context.Background(), func(event ErrorEvent) { events <- event }); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer watcher.Stop(context.Background())
+
+	var got []ErrorEvent
+	for len(got) < 3 {
+		select {
+		case event := <-events:
+			if event.Pod != "uecm-a" || event.Container != "mcc" || event.Source != "/cmconfig.log" {
+				t.Errorf("logFileWatcher event = %+v, want one of uecm-a mcc /cmconfig.log", event)
+			}
+			got = append(got, event)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("logFileWatcher reported %+v, want the errors of both tail sessions and the gap", got)
+		}
+	}
+	if !strings.Contains(got[0].Message, "peer hss1 closed") || got[1].Rule != RuleLogGap || !strings.Contains(got[2].Message, "registration aborted") {
+		t.Errorf("logFileWatcher reported %+v, want the ERROR line, the gap and the FATAL line", got)
+	}
+
+	calls := executor.Calls()
+	if len(calls) < 2 {
+		t.Fatalf("executed %d commands, want tail restarted after the stream dropped", len(calls))
+	}
+	if call := calls[0]; len(call.Command) != 5 || call.Command[4] != "/cmconfig.log" || call.Namespace != "miniudm" || call.Container != "mcc" {
+		t.Errorf("tail ran as %q in %s/%s, want the tail of /cmconfig.log from its end in miniudm/mcc", call.Command, call.Namespace, call.Container)
+	}
+	// The second session resumes after the line the first one read
+	want := strconv.Itoa(120 + len(dropped) + 1)
+	if call := calls[1]; len(call.Command) != 6 || call.Command[5] != want {
+		t.Errorf("tail restarted as %q, want it to resume from byte %s", call.Command, want)
+	}
+}
+
+func TestEventWatcher(t *testing.T) {
+	pod := newPod("miniudm", "uecm-a", "uecm")
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "uecm-5d4f8", Controller: &controller}}
+	collector, _, _ := newTestCollector(t, pod)
+	client := collector.k8sClient.(*kubernetes.Client)
+	env := newTestEnv(collector, TargetPod{Name: "uecm-a", Container: "mcc", Deployment: "uecm"})
+
+	newEvent := func(name, eventType, kind, object, reason string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "miniudm", UID: types.UID("uid-" + name)},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object},
+			Type:           eventType,
+			Reason:         reason,
+			Message:        reason + " happened",
+			Count:          1,
+			LastTimestamp:  metav1.Now(),
+		}
+	}
+
+	watcher := &eventWatcher{env: env}
+	events := make(chan ErrorEvent, 10)
+	if err := watcher.Start(context.Background(), func(event ErrorEvent) { events <- event }); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for _, event := range []*corev1.Event{
+		newEvent("a", corev1.EventTypeNormal, "Pod", "uecm-a", "Pulled"),
+		newEvent("b", corev1.EventTypeWarning, "Pod", "other-a", "BackOff"),
+		// uecm-proxy shares the uecm prefix but is another deployment
+		newEvent("c", corev1.EventTypeWarning, "ReplicaSet", "uecm-proxy-7c9b6", "FailedCreate"),
+		newEvent("d", corev1.EventTypeNormal, "ReplicaSet", "uecm-5d4f8", "SuccessfulCreate"),
+		newEvent("e", corev1.EventTypeWarning, "Pod", "uecm-a", "Unhealthy"),
+	} {
+		if _, err := client.Clientset.CoreV1().Events("miniudm").Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	select {
+	case event := <-events:
+		if event.Kind != EventK8s || event.Pod != "uecm-a" || !strings.HasPrefix(event.Message, "Unhealthy") {
+			t.Errorf("eventWatcher event = %+v, want the Unhealthy warning", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("eventWatcher reported no warning")
+	}
+	if err := watcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	select {
+	case extra := <-events:
+		t.Errorf("eventWatcher reported unexpected event %s", extra.Message)
+	default:
+	}
+	artifacts := watcher.Artifacts()
+	if len(artifacts) != 1 {
+		t.Fatalf("Artifacts() = %+v, want the events log", artifacts)
+	}
+	data, _ := os.ReadFile(artifacts[0].Path)
+	if !strings.Contains(string(data), "Pulled") || !strings.Contains(string(data), "SuccessfulCreate") ||
+		strings.Contains(string(data), "BackOff") || strings.Contains(string(data), "FailedCreate") {
+		t.Errorf("events log = %q, want only the events of the target pod and its replica set", data)
+	}
+
+	// The server filters the events; the namespace is never listed whole
+	selectors := make(map[string]bool)
+	for _, action := range client.Clientset.(*k8sfake.Clientset).Actions() {
+		if list, ok := action.(k8stesting.ListAction); ok && action.GetResource().Resource == "events" {
+			selectors[list.GetListRestrictions().Fields.String()] = true
+		}
+	}
+	want := map[string]bool{"involvedObject.name=uecm-a": true, "involvedObject.name=uecm": true, "involvedObject.name=uecm-5d4f8": true}
+	if !reflect.DeepEqual(selectors, want) {
+		t.Errorf("events listed with field selectors %v, want %v", selectors, want)
+	}
+}
+
+func TestRestartWatcher(t *testing.T) {
+	pod := newPod("miniudm", "uecm-a", "uecm")
+	collector, _, _ := newTestCollector(t, []runtime.Object{pod}...)
+	client := collector.k8sClient.(*kubernetes.Client)
+
+	watcher := &restartWatcher{env: newTestEnv(collector, TargetPod{Name: "uecm-a", Container: "mcc"})}
+	events := make(chan ErrorEvent, 10)
+	if err := watcher.Start(context.Background(), func(event ErrorEvent) { events <- event }); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer watcher.Stop(context.Background())
+
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	restarted.Status.ContainerStatuses[0].LastTerminationState = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"},
+	}
+	if _, err := client.Clientset.CoreV1().Pods("miniudm").UpdateStatus(context.Background(), restarted, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Kind != EventRestart || event.Container != "mcc" || !strings.Contains(event.Message, "OOMKilled") {
+			t.Errorf("restartWatcher event = %+v, want an OOMKilled restart of mcc", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("restartWatcher reported no restart")
+	}
+
+	if err := client.Clientset.CoreV1().Pods("miniudm").Delete(context.Background(), "uecm-a", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	select {
+	case event := <-events:
+		if !strings.Contains(event.Message, "deleted") {
+			t.Errorf("restartWatcher event = %+v, want the pod deletion", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("restartWatcher did not report the deleted pod")
+	}
+}
@@ -0,0 +1,81 @@
+package symptom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Ricky512227/MiniUdmAsyncErrorTracing/pkg/config"
+)
+
+// defaultWebhookTimeout bounds a single webhook request
+const defaultWebhookTimeout = 10 * time.Second
+
+// webhookPayload is the body posted for every batch
+type webhookPayload struct {
+	Events []ErrorEvent `json:"events"`
+}
+
+// webhookSink posts batches of events as JSON to an HTTP endpoint. Any
+// response other than 2xx fails the batch, which is then retried.
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(env *SinkEnv, cfg config.SinkConfig) (EventSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook sink requires a url")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhookSink{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *webhookSink) Name() string {
+	return fmt.Sprintf("%s %s", SinkWebhook, s.url)
+}
+
+func (s *webhookSink) Write(ctx context.Context, events []ErrorEvent) error {
+	body, err := json.Marshal(webhookPayload{Events: events})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post events: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close(ctx context.Context) error {
+	s.client.CloseIdleConnections()
+	return nil
+}